@@ -0,0 +1,82 @@
+package dorm
+
+import (
+	"context"
+	"sync"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// unprocessedClient wraps a DynamoDBAPI and leaves half of each batch request unprocessed,
+// as DynamoDB does when it is throttled.
+type unprocessedClient struct {
+	DynamoDBAPI
+
+	mu sync.Mutex
+	// failures is the number of calls that leave requests unprocessed. If it is negative, every call does.
+	failures int
+	calls    int
+}
+
+func (c *unprocessedClient) fail() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.calls++
+	return c.failures < 0 || c.calls <= c.failures
+}
+
+func (c *unprocessedClient) BatchWriteItem(ctx context.Context, params *dynamodb.BatchWriteItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.BatchWriteItemOutput, error) {
+	if !c.fail() {
+		return c.DynamoDBAPI.BatchWriteItem(ctx, params, optFns...)
+	}
+
+	processed := map[string][]types.WriteRequest{}
+	unprocessed := map[string][]types.WriteRequest{}
+	for table, reqs := range params.RequestItems {
+		half := len(reqs) / 2
+		if half > 0 {
+			processed[table] = reqs[:half]
+		}
+		unprocessed[table] = reqs[half:]
+	}
+
+	if len(processed) > 0 {
+		if _, err := c.DynamoDBAPI.BatchWriteItem(ctx, &dynamodb.BatchWriteItemInput{RequestItems: processed}, optFns...); err != nil {
+			return nil, err
+		}
+	}
+
+	return &dynamodb.BatchWriteItemOutput{UnprocessedItems: unprocessed}, nil
+}
+
+func (c *unprocessedClient) BatchGetItem(ctx context.Context, params *dynamodb.BatchGetItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.BatchGetItemOutput, error) {
+	if !c.fail() {
+		return c.DynamoDBAPI.BatchGetItem(ctx, params, optFns...)
+	}
+
+	processed := map[string]types.KeysAndAttributes{}
+	unprocessed := map[string]types.KeysAndAttributes{}
+	for table, req := range params.RequestItems {
+		half := len(req.Keys) / 2
+		if half > 0 {
+			p := req
+			p.Keys = req.Keys[:half]
+			processed[table] = p
+		}
+		u := req
+		u.Keys = req.Keys[half:]
+		unprocessed[table] = u
+	}
+
+	output := &dynamodb.BatchGetItemOutput{UnprocessedKeys: unprocessed}
+	if len(processed) > 0 {
+		res, err := c.DynamoDBAPI.BatchGetItem(ctx, &dynamodb.BatchGetItemInput{RequestItems: processed}, optFns...)
+		if err != nil {
+			return nil, err
+		}
+		output.Responses = res.Responses
+	}
+
+	return output, nil
+}
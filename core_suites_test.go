@@ -14,6 +14,16 @@ func TestBatchGetItems(t *testing.T) {
 	t.Run("testItem", testtestItemBatchGetItems)
 }
 
+func TestBatchGetItemsOrdered(t *testing.T) {
+	t.Parallel()
+	t.Run("testItem", testtestItemBatchGetItemsOrdered)
+}
+
+func TestBatchGetItemsMap(t *testing.T) {
+	t.Parallel()
+	t.Run("testItem", testtestItemBatchGetItemsMap)
+}
+
 func TestPutItem(t *testing.T) {
 	t.Parallel()
 	t.Run("testItem", testtestItemPutItem)
@@ -46,6 +56,76 @@ func TestBatchDeleteItem(t *testing.T) {
 	t.Run("testItem", testtestItemBatchDeleteItem)
 }
 
+func TestTransactWriteItems(t *testing.T) {
+	t.Parallel()
+	t.Run("testItem", testtestItemTransactWriteItems)
+}
+
+func TestTransactGetItems(t *testing.T) {
+	t.Parallel()
+	t.Run("testItem", testtestItemTransactGetItems)
+}
+
+func TestOperationErrorWithDB(t *testing.T) {
+	t.Parallel()
+	t.Run("testItem", testtestItemOperationError)
+}
+
+func TestConditionFailedError(t *testing.T) {
+	t.Parallel()
+	t.Run("testItem", testtestItemConditionFailedError)
+}
+
+func TestOptimisticLocking(t *testing.T) {
+	t.Parallel()
+	t.Run("testVersionedItem", testtestVersionedItemOptimisticLocking)
+}
+
+func TestTableLifecycle(t *testing.T) {
+	t.Parallel()
+	t.Run("tableTestItem", testTableLifecycle)
+}
+
+func TestCheckTable(t *testing.T) {
+	t.Parallel()
+	t.Run("driftTestItem", testCheckTable)
+}
+
+func TestMigrateTable(t *testing.T) {
+	t.Parallel()
+	t.Run("migrateTestItem", testMigrateTable)
+}
+
+func TestTimeToLive(t *testing.T) {
+	t.Parallel()
+	t.Run("ttlTestItem", testTimeToLive)
+}
+
+func TestEntities(t *testing.T) {
+	t.Parallel()
+	t.Run("entityTestUser", testEntities)
+}
+
+func TestKeyTemplates(t *testing.T) {
+	t.Parallel()
+	t.Run("templateTestOrder", testKeyTemplates)
+}
+
+func TestKeyMismatch(t *testing.T) {
+	t.Parallel()
+	t.Run("testItem", testtestItemKeyMismatch)
+}
+
+func TestDiff(t *testing.T) {
+	t.Parallel()
+	t.Run("testItem", testtestItemDiff)
+}
+
+// Timestamps Test Should not run in parallel. It replaces the clock.
+func TestTimestamps(t *testing.T) {
+	t.Run("testTimestampedItem", testtestTimestampedItemTimestamps)
+}
+
 // Scan Test Should not run in parallel. It will cause conflict.
 func TestScan(t *testing.T) {
 	t.Run("testItem", testtestItemScan)
@@ -13,6 +13,8 @@ type createTableFunc func(*dynamodb.Client) error
 
 var funcs = []createTableFunc{
 	createtestItemTable,
+	createtestVersionedItemTable,
+	createtestTimestampedItemTable,
 }
 
 func (d *ddbTester) createTestDB(db *dynamodb.Client) error {
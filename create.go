@@ -3,17 +3,45 @@ package dorm
 import (
 	"context"
 
-	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
 	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/expression"
 	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
 	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/cockroachdb/errors"
 )
 
 const maxBatchPutItemSize = 25
 
+// PutItemOptions PutItem options for PutItem function
+type PutItemOptions struct {
+	// ReturnValues is NONE or ALL_OLD. If it is ALL_OLD, PutItem returns the item before it was replaced.
+	ReturnValues types.ReturnValue
+	// ReturnValuesOnConditionCheckFailure is set to ALL_OLD to get the current item from ConditionFailedError.
+	ReturnValuesOnConditionCheckFailure types.ReturnValuesOnConditionCheckFailure
+}
+
+// PutOptionFunc PutItem option function
+type PutOptionFunc func(*PutItemOptions)
+
+// WithPutReturnValues sets the ReturnValues for PutItemOptions.
+func WithPutReturnValues(rv types.ReturnValue) PutOptionFunc {
+	return func(opts *PutItemOptions) {
+		opts.ReturnValues = rv
+	}
+}
+
+// WithPutReturnValuesOnConditionCheckFailure sets the ReturnValuesOnConditionCheckFailure for PutItemOptions.
+func WithPutReturnValuesOnConditionCheckFailure(rv types.ReturnValuesOnConditionCheckFailure) PutOptionFunc {
+	return func(opts *PutItemOptions) {
+		opts.ReturnValuesOnConditionCheckFailure = rv
+	}
+}
+
 // BatchPutItemOptions BatchPutItem options for BatchPutItem function
 type BatchPutItemOptions struct {
 	Concurrency int
+	// MaxAttempts is the maximum number of BatchWriteItem calls per batch while unprocessed items remain.
+	// If it is 0 or less, 5 is used.
+	MaxAttempts int
 }
 
 // BatchPutOptionFunc BatchPutItem option function
@@ -26,37 +54,76 @@ func WithBatchPutConcurrency(concurrency int) BatchPutOptionFunc {
 	}
 }
 
+// WithBatchPutMaxAttempts sets the MaxAttempts for BatchPutItemOptions.
+func WithBatchPutMaxAttempts(attempts int) BatchPutOptionFunc {
+	return func(opts *BatchPutItemOptions) {
+		opts.MaxAttempts = attempts
+	}
+}
+
 // PutItem adds an item if it doesn't exist, or replaces it if it does.
 //
 // Be careful, as it will overwrite with zero values if set.
 // Use UpdateItem if you want to update.
 //
+// It returns the replaced item if ReturnValues is ALL_OLD and the item existed, and nil otherwise.
+//
+// If V has a field tagged with `dorm:"version"`, the item is written only if it doesn't exist or its stored version
+// equals the version of item, and the stored version is incremented. Otherwise, ErrVersionConflict is returned.
+// The fields tagged with `dorm:"created_at"` and `dorm:"updated_at"` are filled with the current time,
+// but created_at is kept if it is already set.
+//
 // https://pkg.go.dev/github.com/aws/aws-sdk-go-v2/service/dynamodb#Client.PutItem
 // https://docs.aws.amazon.com/en_us/amazondynamodb/latest/APIReference/API_PutItem.html
-func PutItem[V ItemType](ctx context.Context, db *dynamodb.Client, item V, expr expression.Expression) error {
+func PutItem[V ItemType](ctx context.Context, db DynamoDBAPI, item V, expr expression.Expression, opts ...PutOptionFunc) (*V, error) {
+
+	o := PutItemOptions{
+		ReturnValues: types.ReturnValueNone,
+	}
+
+	for _, f := range opts {
+		f(&o)
+	}
+
+	item, err := stamped(item)
+	if err != nil {
+		return nil, err
+	}
 
-	av, err := attributevalue.MarshalMap(item)
+	av, err := marshalItem(item)
 
 	if err != nil {
-		return err
+		return nil, err
+	}
+
+	key, err := itemKeyOf[V](av)
+	if err != nil {
+		return nil, err
+	}
+
+	parts, check, err := versionedPut(item, av, partsOf(expr))
+	if err != nil {
+		return nil, err
 	}
 
 	input := &dynamodb.PutItemInput{
 		Item:                      av,
 		TableName:                 getFullTableName[V](),
-		ConditionExpression:       expr.Condition(),
-		ExpressionAttributeNames:  expr.Names(),
-		ExpressionAttributeValues: expr.Values(),
-		ReturnValues:              types.ReturnValueNone,
+		ConditionExpression:       parts.Condition,
+		ExpressionAttributeNames:  parts.Names,
+		ExpressionAttributeValues: parts.Values,
+		ReturnValues:              o.ReturnValues,
+
+		ReturnValuesOnConditionCheckFailure: returnValuesOnConditionCheckFailure(o.ReturnValuesOnConditionCheckFailure, check),
 	}
 
-	_, err = db.PutItem(ctx, input)
+	output, err := db.PutItem(ctx, input)
 
 	if err != nil {
-		return err
+		return nil, newOperationError("PutItem", input.TableName, key, conditionFailed[V](err, check))
 	}
 
-	return nil
+	return unmarshalAttributes[V](output.Attributes)
 
 }
 
@@ -64,9 +131,12 @@ func PutItem[V ItemType](ctx context.Context, db *dynamodb.Client, item V, expr
 //
 // It is limited to a single table, although AWS allows accessing multiple tables.
 // Also, it can perform a mix of deletion and creation, but here it is restricted to a single operation.
+// Unprocessed items are resubmitted with exponential backoff up to MaxAttempts times.
+// If some items are still unprocessed, an *UnprocessedItemsError[V] listing them is returned.
+// V must not have a field tagged with `dorm:"version"`, since BatchWriteItem can't check the version.
 // https://pkg.go.dev/github.com/aws/aws-sdk-go-v2/service/dynamodb#Client.BatchWriteItem
 // https://docs.aws.amazon.com/en_us/amazondynamodb/latest/APIReference/API_BatchWriteItem.html
-func BatchPutItem[V ItemType](ctx context.Context, db *dynamodb.Client, items []V, opts ...BatchPutOptionFunc) error {
+func BatchPutItem[V ItemType](ctx context.Context, db DynamoDBAPI, items []V, opts ...BatchPutOptionFunc) error {
 
 	o := BatchPutItemOptions{}
 
@@ -74,31 +144,53 @@ func BatchPutItem[V ItemType](ctx context.Context, db *dynamodb.Client, items []
 		f(&o)
 	}
 
+	if err := unversioned[V]("BatchPutItem"); err != nil {
+		return err
+	}
+
 	if len(items) == 0 {
 		return nil
 	}
 	// The number of operations that can be performed in a single batch is up to 25
-	err := splitThread(ctx, db, NopExpression, maxBatchPutItemSize, o.Concurrency, batchPutItem[V], items)
+	unprocessed, err := splitThreadWithReturnValue(ctx, db, NopExpression, maxBatchPutItemSize, o.Concurrency,
+		func(ctx context.Context, db DynamoDBAPI, _ expression.Expression, items []V) ([]V, error) {
+			return batchPutItem(ctx, db, o.MaxAttempts, items)
+		}, items)
 
 	if err != nil {
 		return err
 	}
 
+	if len(unprocessed) > 0 {
+		return &UnprocessedItemsError[V]{TableName: *getFullTableName[V](), Items: unprocessed}
+	}
+
 	return nil
 
 }
 
-func batchPutItem[V ItemType](ctx context.Context, db *dynamodb.Client, expr expression.Expression, items []V) error {
+// batchPutItem writes a single batch and returns the items that could not be written.
+func batchPutItem[V ItemType](ctx context.Context, db DynamoDBAPI, maxAttempts int, items []V) ([]V, error) {
 
 	if len(items) == 0 {
-		return nil
+		return nil, nil
 	}
 
 	writeReqs := make([]types.WriteRequest, len(items))
+	// Unprocessed requests are matched with the original items by their content
+	byContent := make(map[string]V, len(items))
 	for i, item := range items {
-		av, err := attributevalue.MarshalMap(item)
+		item, err := stamped(item)
+		if err != nil {
+			return nil, err
+		}
+
+		av, err := marshalItem(item)
 		if err != nil {
-			return err
+			return nil, err
+		}
+		if err := checkItemKey[V](av); err != nil {
+			return nil, err
 		}
 
 		writeReqs[i] = types.WriteRequest{
@@ -106,19 +198,28 @@ func batchPutItem[V ItemType](ctx context.Context, db *dynamodb.Client, expr exp
 				Item: av,
 			},
 		}
+		byContent[attributeMapKey(av)] = item
 
 	}
 
-	_, err := db.BatchWriteItem(ctx, &dynamodb.BatchWriteItemInput{
-		RequestItems: map[string][]types.WriteRequest{
-			*getFullTableName[V](): writeReqs,
-		},
-	})
+	unprocessed, err := batchWriteItem(ctx, db, *getFullTableName[V](), writeReqs, maxAttempts)
 
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	return nil
+	res := make([]V, 0, len(unprocessed))
+	for _, req := range unprocessed {
+		if req.PutRequest == nil {
+			continue
+		}
+		item, ok := byContent[attributeMapKey(req.PutRequest.Item)]
+		if !ok {
+			return nil, errors.New("unprocessed request doesn't match any item of the batch")
+		}
+		res = append(res, item)
+	}
+
+	return res, nil
 
 }
@@ -4,8 +4,11 @@ import (
 	"context"
 	"testing"
 
+	"github.com/cockroachdb/errors"
+
 	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/expression"
 	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
 	"github.com/google/go-cmp/cmp"
 	"github.com/google/go-cmp/cmp/cmpopts"
 	"github.com/stretchr/testify/assert"
@@ -18,6 +21,10 @@ func testtestItemPutItem(t *testing.T) {
 		db   *dynamodb.Client
 		item testItem
 		expr expression.Expression
+		opts []PutOptionFunc
+
+		// want is the item PutItem returns.
+		want *testItem
 	}
 	tests := map[string]struct {
 		args args
@@ -44,7 +51,7 @@ func testtestItemPutItem(t *testing.T) {
 				assert.NoError(t, err)
 
 				// put item
-				err = PutItem(args.ctx, args.db, o, expression.Expression{})
+				_, err = PutItem(args.ctx, args.db, o, expression.Expression{})
 				assert.NoError(t, err)
 
 				// set args
@@ -67,15 +74,60 @@ func testtestItemPutItem(t *testing.T) {
 				cmpopts.IgnoreUnexported(testItem{}),
 			},
 		},
+		"success with all old": {
+			args: args{
+				ctx:  context.Background(),
+				opts: []PutOptionFunc{WithPutReturnValues(types.ReturnValueAllOld)},
+			},
+			setup: func(t *testing.T, args *args) {
+				var err error
+
+				// init db
+				args.db, err = ddbMain.conn()
+				assert.NoError(t, err)
+
+				// randomize
+				o := testItem{}
+				err = RandomizeDDBStruct(&o)
+				assert.NoError(t, err)
+
+				// put item
+				_, err = PutItem(args.ctx, args.db, o, expression.Expression{})
+				assert.NoError(t, err)
+
+				// set args
+				old := o
+				args.want = &old
+
+				err = RandomizeDDBStruct(&o)
+				assert.NoError(t, err)
+				o.HashKey = old.HashKey
+				args.item = o
+			},
+			selfAssert: []func(t *testing.T, args args){
+				func(t *testing.T, args args) {
+					got, err := GetItem[testItem](args.ctx, args.db, testItemPrimaryIndex{HashKey: args.item.HashKey}, expression.Expression{})
+					assert.NoError(t, err)
+					assert.Equal(t, args.item, *got)
+				},
+			},
+			opts: []cmp.Option{
+				cmpopts.IgnoreUnexported(testItem{}),
+			},
+		},
 	}
 	for name, tt := range tests {
 		tt := tt
 		tt.setup(t, &tt.args)
 		t.Run(name, func(t *testing.T) {
 			t.Parallel()
-			if err := PutItem(tt.args.ctx, tt.args.db, tt.args.item, tt.args.expr); (err != nil) != tt.wantErr {
+			got, err := PutItem(tt.args.ctx, tt.args.db, tt.args.item, tt.args.expr, tt.args.opts...)
+			if (err != nil) != tt.wantErr {
 				t.Errorf("wantErr is %t, but err is %v", tt.wantErr, err)
 			}
+			if diff := cmp.Diff(tt.args.want, got, tt.opts...); len(diff) > 0 {
+				t.Errorf("Compare value is mismatch (-want +got):%s\n", diff)
+			}
 			for _, fn := range tt.selfAssert {
 				fn(t, tt.args)
 			}
@@ -87,16 +139,17 @@ func testtestItemBatchPutItem(t *testing.T) {
 	t.Parallel()
 	type args struct {
 		ctx context.Context
-		db  *dynamodb.Client
+		db  DynamoDBAPI
 
 		items []testItem
+		opts  []BatchPutOptionFunc
 	}
 	tests := map[string]struct {
 		args       args
 		setup      func(*testing.T, *args)
 		wantErr    bool
 		opts       []cmp.Option
-		selfAssert []func(t *testing.T, args *args)
+		selfAssert []func(t *testing.T, args *args, err error)
 	}{
 		"success": {
 			args: args{
@@ -122,15 +175,16 @@ func testtestItemBatchPutItem(t *testing.T) {
 				}
 
 			},
-			selfAssert: []func(t *testing.T, args *args){
-				func(t *testing.T, args *args) {
+			selfAssert: []func(t *testing.T, args *args, err error){
+				func(t *testing.T, args *args, err error) {
 					indices := []PrimaryIndex{}
 					for _, item := range args.items {
 						indices = append(indices, testItemPrimaryIndex{HashKey: item.HashKey})
 					}
 					db, err := ddbMain.conn()
 					assert.NoError(t, err)
-					proj := ProjectionAll[testItem]()
+					proj, err := ProjectionAll[testItem]()
+					assert.NoError(t, err)
 					expr, err := expression.NewBuilder().WithProjection(proj).Build()
 					assert.NoError(t, err)
 					got, err := BatchGetItems[testItem](args.ctx, db, indices, expr)
@@ -142,17 +196,94 @@ func testtestItemBatchPutItem(t *testing.T) {
 				cmpopts.IgnoreUnexported(testItem{}),
 			},
 		},
+		"success with unprocessed items": {
+			args: args{
+				ctx: context.Background(),
+			},
+			setup: func(t *testing.T, args *args) {
+				db, err := ddbMain.conn()
+				assert.NoError(t, err)
+
+				// the first two calls leave half of the items unprocessed
+				args.db = &unprocessedClient{DynamoDBAPI: db, failures: 2}
+
+				args.items = make([]testItem, maxBatchPutItemSize)
+				for i := range args.items {
+					// randomize
+					o := testItem{}
+					err = RandomizeDDBStruct(&o)
+					assert.NoError(t, err)
+					args.items[i] = o
+				}
+			},
+			selfAssert: []func(t *testing.T, args *args, err error){
+				func(t *testing.T, args *args, err error) {
+					db, err := ddbMain.conn()
+					assert.NoError(t, err)
+					for _, item := range args.items {
+						got, err := GetItem[testItem](args.ctx, db, testItemPrimaryIndex{HashKey: item.HashKey}, expression.Expression{})
+						assert.NoError(t, err)
+						assert.Equal(t, item, *got)
+					}
+				},
+			},
+		},
+		"unprocessed items remain": {
+			args: args{
+				ctx:  context.Background(),
+				opts: []BatchPutOptionFunc{WithBatchPutMaxAttempts(2)},
+			},
+			setup: func(t *testing.T, args *args) {
+				db, err := ddbMain.conn()
+				assert.NoError(t, err)
+
+				args.db = &unprocessedClient{DynamoDBAPI: db, failures: -1}
+
+				args.items = make([]testItem, 8)
+				for i := range args.items {
+					// randomize
+					o := testItem{}
+					err = RandomizeDDBStruct(&o)
+					assert.NoError(t, err)
+					args.items[i] = o
+				}
+			},
+			wantErr: true,
+			selfAssert: []func(t *testing.T, args *args, err error){
+				func(t *testing.T, args *args, err error) {
+					assert.ErrorIs(t, err, ErrUnprocessedItems)
+
+					var uerr *UnprocessedItemsError[testItem]
+					assert.True(t, errors.As(err, &uerr))
+					// 8 => 4 => 2 items are left unprocessed
+					assert.ElementsMatch(t, args.items[6:], uerr.Items)
+
+					db, err := ddbMain.conn()
+					assert.NoError(t, err)
+
+					for i, item := range args.items {
+						_, err := GetItem[testItem](args.ctx, db, testItemPrimaryIndex{HashKey: item.HashKey}, expression.Expression{})
+						if i < 6 {
+							assert.NoError(t, err)
+						} else {
+							assert.ErrorIs(t, err, ErrItemNotFound)
+						}
+					}
+				},
+			},
+		},
 	}
 	for name, tt := range tests {
 		tt := tt
 		t.Run(name, func(t *testing.T) {
 			t.Parallel()
 			tt.setup(t, &tt.args)
-			if err := BatchPutItem(tt.args.ctx, tt.args.db, tt.args.items); (err != nil) != tt.wantErr {
+			err := BatchPutItem(tt.args.ctx, tt.args.db, tt.args.items, tt.args.opts...)
+			if (err != nil) != tt.wantErr {
 				t.Errorf("wantErr is %t, but err is %v", tt.wantErr, err)
 			}
 			for _, fn := range tt.selfAssert {
-				fn(t, &tt.args)
+				fn(t, &tt.args, err)
 			}
 		})
 	}
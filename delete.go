@@ -3,17 +3,54 @@ package dorm
 import (
 	"context"
 
-	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
 	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/expression"
 	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
 	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/cockroachdb/errors"
 )
 
 const maxBatchDeleteSize = 25
 
+// DeleteItemOptions DeleteItem options for DeleteItem function
+type DeleteItemOptions struct {
+	// ReturnValues is NONE or ALL_OLD. If it is ALL_OLD, DeleteItem returns the deleted item.
+	ReturnValues types.ReturnValue
+	// ReturnValuesOnConditionCheckFailure is set to ALL_OLD to get the current item from ConditionFailedError.
+	ReturnValuesOnConditionCheckFailure types.ReturnValuesOnConditionCheckFailure
+	// ExpectedVersion is the version the item must have to be deleted, if V has a version field.
+	ExpectedVersion *int64
+}
+
+// DeleteOptionFunc DeleteItem option function
+type DeleteOptionFunc func(*DeleteItemOptions)
+
+// WithDeleteReturnValues sets the ReturnValues for DeleteItemOptions.
+func WithDeleteReturnValues(rv types.ReturnValue) DeleteOptionFunc {
+	return func(opts *DeleteItemOptions) {
+		opts.ReturnValues = rv
+	}
+}
+
+// WithDeleteReturnValuesOnConditionCheckFailure sets the ReturnValuesOnConditionCheckFailure for DeleteItemOptions.
+func WithDeleteReturnValuesOnConditionCheckFailure(rv types.ReturnValuesOnConditionCheckFailure) DeleteOptionFunc {
+	return func(opts *DeleteItemOptions) {
+		opts.ReturnValuesOnConditionCheckFailure = rv
+	}
+}
+
+// WithDeleteExpectedVersion sets the ExpectedVersion for DeleteItemOptions.
+func WithDeleteExpectedVersion(version int64) DeleteOptionFunc {
+	return func(opts *DeleteItemOptions) {
+		opts.ExpectedVersion = &version
+	}
+}
+
 // BatchDeleteItemOptions BatchDeleteItem options for BatchDeleteItem function
 type BatchDeleteItemOptions struct {
 	Concurrency int
+	// MaxAttempts is the maximum number of BatchWriteItem calls per batch while unprocessed keys remain.
+	// If it is 0 or less, 5 is used.
+	MaxAttempts int
 }
 
 // BatchDeleteOptionFunc BatchDeleteItem option function
@@ -26,27 +63,67 @@ func WithBatchDeleteConcurrency(concurrency int) BatchDeleteOptionFunc {
 	}
 }
 
+// WithBatchDeleteMaxAttempts sets the MaxAttempts for BatchDeleteItemOptions.
+func WithBatchDeleteMaxAttempts(attempts int) BatchDeleteOptionFunc {
+	return func(opts *BatchDeleteItemOptions) {
+		opts.MaxAttempts = attempts
+	}
+}
+
 // DeleteItem deletes an item.
+//
+// It returns the deleted item if ReturnValues is ALL_OLD and the item existed, and nil otherwise.
+// If V has a field tagged with `dorm:"version"`, ExpectedVersion is required,
+// and ErrVersionConflict is returned if the stored version is different.
+// If V has a field tagged with `dorm:"entity=name"`, an item of another entity type is not deleted and ErrConditionFailed is returned.
 // https://pkg.go.dev/github.com/aws/aws-sdk-go-v2/service/dynamodb#Client.DeleteItem
-func DeleteItem[V ItemType](ctx context.Context, db *dynamodb.Client, idx PrimaryIndex, expr expression.Expression) error {
+func DeleteItem[V ItemType](ctx context.Context, db DynamoDBAPI, idx PrimaryIndex, expr expression.Expression, opts ...DeleteOptionFunc) (*V, error) {
+
+	o := DeleteItemOptions{
+		ReturnValues: types.ReturnValueNone,
+	}
+
+	for _, f := range opts {
+		f(&o)
+	}
 
-	key, err := buildIndex(idx)
+	key, err := primaryKeyOf[V](idx)
 	if err != nil {
-		return err
+		return nil, err
+	}
+
+	if err := requireExpectedVersion[V]("DeleteItem", "WithDeleteExpectedVersion", o.ExpectedVersion); err != nil {
+		return nil, err
+	}
+
+	parts, check, err := versioned[V](partsOf(expr), o.ExpectedVersion, false)
+	if err != nil {
+		return nil, err
+	}
+
+	parts, err = entityGuarded[V](parts)
+	if err != nil {
+		return nil, err
 	}
 
 	input := &dynamodb.DeleteItemInput{
 		Key:                       key,
 		TableName:                 getFullTableName[V](),
-		ConditionExpression:       expr.Condition(),
-		ExpressionAttributeNames:  expr.Names(),
-		ExpressionAttributeValues: expr.Values(),
-		ReturnValues:              types.ReturnValueNone,
+		ConditionExpression:       parts.Condition,
+		ExpressionAttributeNames:  parts.Names,
+		ExpressionAttributeValues: parts.Values,
+		ReturnValues:              o.ReturnValues,
+
+		ReturnValuesOnConditionCheckFailure: returnValuesOnConditionCheckFailure(o.ReturnValuesOnConditionCheckFailure, check),
 	}
 
-	_, err = db.DeleteItem(ctx, input)
+	output, err := db.DeleteItem(ctx, input)
+
+	if err != nil {
+		return nil, newOperationError("DeleteItem", input.TableName, key, conditionFailed[V](err, check))
+	}
 
-	return err
+	return unmarshalAttributes[V](output.Attributes)
 
 }
 
@@ -54,9 +131,12 @@ func DeleteItem[V ItemType](ctx context.Context, db *dynamodb.Client, idx Primar
 //
 // According to AWS specifications, it is possible to access multiple tables, but here we are limiting it to a single table.
 // Also, deletion and creation can be mixed, but we are limiting it to a single operation.
+// Unprocessed keys are resubmitted with exponential backoff up to MaxAttempts times.
+// If some keys are still unprocessed, an *UnprocessedKeysError listing them is returned.
+// V must not have a field tagged with `dorm:"version"`, since BatchWriteItem can't check the version.
 // https://pkg.go.dev/github.com/aws/aws-sdk-go-v2/service/dynamodb#Client.BatchWriteItem
 // https://docs.aws.amazon.com/en_us/amazondynamodb/latest/APIReference/API_BatchWriteItem.html
-func BatchDeleteItem[V ItemType](ctx context.Context, db *dynamodb.Client, keys []PrimaryIndex, opts ...BatchDeleteOptionFunc) error {
+func BatchDeleteItem[V ItemType](ctx context.Context, db DynamoDBAPI, keys []PrimaryIndex, opts ...BatchDeleteOptionFunc) error {
 
 	o := BatchDeleteItemOptions{}
 
@@ -64,46 +144,69 @@ func BatchDeleteItem[V ItemType](ctx context.Context, db *dynamodb.Client, keys
 		f(&o)
 	}
 
+	if err := unversioned[V]("BatchDeleteItem"); err != nil {
+		return err
+	}
+
 	if len(keys) == 0 {
 		return nil
 	}
 	// The number of operations that can be performed in a single batch is up to 25.
-	err := splitThread(ctx, db, NopExpression, maxBatchPutItemSize, o.Concurrency, batchDeleteItem[V], keys)
+	unprocessed, err := splitThreadWithReturnValue(ctx, db, NopExpression, maxBatchDeleteSize, o.Concurrency,
+		func(ctx context.Context, db DynamoDBAPI, _ expression.Expression, keys []PrimaryIndex) ([]PrimaryIndex, error) {
+			return batchDeleteItem[V](ctx, db, o.MaxAttempts, keys)
+		}, keys)
 
 	if err != nil {
 		return err
 	}
 
+	if len(unprocessed) > 0 {
+		return &UnprocessedKeysError{TableName: *getFullTableName[V](), Keys: unprocessed}
+	}
+
 	return nil
 
 }
 
-func batchDeleteItem[V ItemType](ctx context.Context, db *dynamodb.Client, expr expression.Expression, keys []PrimaryIndex) error {
+// batchDeleteItem deletes a single batch and returns the keys that could not be deleted.
+func batchDeleteItem[V ItemType](ctx context.Context, db DynamoDBAPI, maxAttempts int, keys []PrimaryIndex) ([]PrimaryIndex, error) {
 	// The number of operations that can be performed in a single batch is up to 25.
 
 	writeReqs := make([]types.WriteRequest, len(keys))
+	// Unprocessed requests are matched with the original keys by their content
+	byContent := make(map[string]PrimaryIndex, len(keys))
 	for i, item := range keys {
-		av, err := attributevalue.MarshalMap(item)
+		av, err := primaryKeyOf[V](item)
 		if err != nil {
-			return err
+			return nil, err
 		}
 		writeReqs[i] = types.WriteRequest{
 			DeleteRequest: &types.DeleteRequest{
 				Key: av,
 			},
 		}
+		byContent[attributeMapKey(av)] = item
 
 	}
 
-	_, err := db.BatchWriteItem(ctx, &dynamodb.BatchWriteItemInput{
-		RequestItems: map[string][]types.WriteRequest{
-			*getFullTableName[V](): writeReqs,
-		},
-	})
+	unprocessed, err := batchWriteItem(ctx, db, *getFullTableName[V](), writeReqs, maxAttempts)
 
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	return nil
+	res := make([]PrimaryIndex, 0, len(unprocessed))
+	for _, req := range unprocessed {
+		if req.DeleteRequest == nil {
+			continue
+		}
+		key, ok := byContent[attributeMapKey(req.DeleteRequest.Key)]
+		if !ok {
+			return nil, errors.New("unprocessed request doesn't match any key of the batch")
+		}
+		res = append(res, key)
+	}
+
+	return res, nil
 }
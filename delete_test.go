@@ -23,6 +23,10 @@ func testtestItemDeleteItem(t *testing.T) {
 		idx PrimaryIndex
 
 		expr expression.Expression
+		opts []DeleteOptionFunc
+
+		// want is the item DeleteItem returns.
+		want *testItem
 	}
 	tests := map[string]struct {
 		args args
@@ -49,7 +53,7 @@ func testtestItemDeleteItem(t *testing.T) {
 				assert.NoError(t, err)
 
 				// put item
-				err = PutItem(args.ctx, args.db, o, expression.Expression{})
+				_, err = PutItem(args.ctx, args.db, o, expression.Expression{})
 				assert.NoError(t, err)
 
 				// set args
@@ -72,15 +76,56 @@ func testtestItemDeleteItem(t *testing.T) {
 				},
 			},
 		},
+		"success with all old": {
+			args: args{
+				ctx:  context.Background(),
+				opts: []DeleteOptionFunc{WithDeleteReturnValues(types.ReturnValueAllOld)},
+			},
+			setup: func(t *testing.T, args *args) string {
+				var err error
+
+				// init db
+				args.db, err = ddbMain.conn()
+				assert.NoError(t, err)
+
+				// randomize
+				o := testItem{}
+				err = RandomizeDDBStruct(&o)
+				assert.NoError(t, err)
+
+				// put item
+				_, err = PutItem(args.ctx, args.db, o, expression.Expression{})
+				assert.NoError(t, err)
+
+				// set args
+				args.idx = testItemPrimaryIndex{HashKey: o.HashKey}
+				args.want = &o
+
+				return o.HashKey
+			},
+			opts: []cmp.Option{
+				cmpopts.IgnoreUnexported(testItem{}),
+			},
+			selfAssert: []func(t *testing.T, args args, id string){
+				func(t *testing.T, args args, id string) {
+					_, err := GetItem[testItem](args.ctx, args.db, testItemPrimaryIndex{HashKey: id}, expression.Expression{})
+					assert.True(t, errors.Is(err, ErrItemNotFound))
+				},
+			},
+		},
 	}
 	for name, tt := range tests {
 		tt := tt
 		t.Run(name, func(t *testing.T) {
 			t.Parallel()
 			id := tt.setup(t, &tt.args)
-			if err := DeleteItem[testItem](tt.args.ctx, tt.args.db, tt.args.idx, tt.args.expr); (err != nil) != tt.wantErr {
+			got, err := DeleteItem[testItem](tt.args.ctx, tt.args.db, tt.args.idx, tt.args.expr, tt.args.opts...)
+			if (err != nil) != tt.wantErr {
 				t.Errorf("wantErr is %t, but err is %v", tt.wantErr, err)
 			}
+			if diff := cmp.Diff(tt.args.want, got, tt.opts...); len(diff) > 0 {
+				t.Errorf("Compare value is mismatch (-want +got):%s\n", diff)
+			}
 			for _, fn := range tt.selfAssert {
 				fn(t, tt.args, id)
 			}
@@ -92,16 +137,17 @@ func testtestItemBatchDeleteItem(t *testing.T) {
 	t.Parallel()
 	type args struct {
 		ctx context.Context
-		db  *dynamodb.Client
+		db  DynamoDBAPI
 
 		keys []PrimaryIndex
+		opts []BatchDeleteOptionFunc
 	}
 	tests := map[string]struct {
 		args       args
 		setup      func(*testing.T, *args)
 		wantErr    bool
 		opts       []cmp.Option
-		selfAssert []func(t *testing.T, args *args)
+		selfAssert []func(t *testing.T, args *args, err error)
 	}{
 		"success": {
 			args: args{
@@ -160,12 +206,13 @@ func testtestItemBatchDeleteItem(t *testing.T) {
 				}
 
 			},
-			selfAssert: []func(t *testing.T, args *args){
-				func(t *testing.T, args *args) {
+			selfAssert: []func(t *testing.T, args *args, err error){
+				func(t *testing.T, args *args, err error) {
 					db, err := ddbMain.conn()
 					assert.NoError(t, err)
 
-					proj := ProjectionAll[testItem]()
+					proj, err := ProjectionAll[testItem]()
+					assert.NoError(t, err)
 					expr, err := expression.NewBuilder().WithProjection(proj).Build()
 					assert.NoError(t, err)
 					got, err := BatchGetItems[testItem](args.ctx, db, args.keys, expr)
@@ -177,17 +224,60 @@ func testtestItemBatchDeleteItem(t *testing.T) {
 				cmpopts.IgnoreUnexported(testItem{}),
 			},
 		},
+		"unprocessed keys remain": {
+			args: args{
+				ctx:  context.Background(),
+				opts: []BatchDeleteOptionFunc{WithBatchDeleteMaxAttempts(2)},
+			},
+			setup: func(t *testing.T, args *args) {
+				db, err := ddbMain.conn()
+				assert.NoError(t, err)
+
+				items := make([]testItem, 8)
+				args.keys = make([]PrimaryIndex, len(items))
+				for i := range items {
+					// randomize
+					o := testItem{}
+					err = RandomizeDDBStruct(&o)
+					assert.NoError(t, err)
+					items[i] = o
+					args.keys[i] = testItemPrimaryIndex{HashKey: o.HashKey}
+				}
+				err = BatchPutItem(args.ctx, db, items)
+				assert.NoError(t, err)
+
+				args.db = &unprocessedClient{DynamoDBAPI: db, failures: -1}
+			},
+			wantErr: true,
+			selfAssert: []func(t *testing.T, args *args, err error){
+				func(t *testing.T, args *args, err error) {
+					assert.ErrorIs(t, err, ErrUnprocessedItems)
+
+					var uerr *UnprocessedKeysError
+					assert.True(t, errors.As(err, &uerr))
+					// 8 => 4 => 2 keys are left unprocessed
+					assert.ElementsMatch(t, args.keys[6:], uerr.Keys)
+
+					db, err := ddbMain.conn()
+					assert.NoError(t, err)
+					got, err := BatchGetItems[testItem](args.ctx, db, args.keys, expression.Expression{})
+					assert.NoError(t, err)
+					assert.Len(t, got, 2)
+				},
+			},
+		},
 	}
 	for name, tt := range tests {
 		tt := tt
 		t.Run(name, func(t *testing.T) {
 			t.Parallel()
 			tt.setup(t, &tt.args)
-			if err := BatchDeleteItem[testItem](tt.args.ctx, tt.args.db, tt.args.keys); (err != nil) != tt.wantErr {
+			err := BatchDeleteItem[testItem](tt.args.ctx, tt.args.db, tt.args.keys, tt.args.opts...)
+			if (err != nil) != tt.wantErr {
 				t.Errorf("wantErr is %t, but err is %v", tt.wantErr, err)
 			}
 			for _, fn := range tt.selfAssert {
-				fn(t, &tt.args)
+				fn(t, &tt.args, err)
 			}
 		})
 	}
@@ -0,0 +1,133 @@
+package dorm
+
+import (
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/expression"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/cockroachdb/errors"
+)
+
+// DiffOptions options for DiffOf function
+type DiffOptions struct {
+	// Condition adds the condition that the stored values of the changed attributes are still the original ones,
+	// so that UpdateItem fails with ErrConditionFailed if they were changed after the original was read.
+	Condition bool
+	// Skippers are the functions that specify the fields to be excluded. Exclude when returning true.
+	Skippers []func(name string) bool
+}
+
+// DiffOptionFunc DiffOf option function
+type DiffOptionFunc func(*DiffOptions)
+
+// WithDiffCondition sets the Condition for DiffOptions.
+func WithDiffCondition() DiffOptionFunc {
+	return func(opts *DiffOptions) {
+		opts.Condition = true
+	}
+}
+
+// WithDiffSkipper adds the skippers to DiffOptions.
+func WithDiffSkipper(skipper ...func(name string) bool) DiffOptionFunc {
+	return func(opts *DiffOptions) {
+		opts.Skippers = append(opts.Skippers, skipper...)
+	}
+}
+
+// Diff is the update from an original item to a modified one, built by DiffOf.
+type Diff struct {
+	// Changed is the attribute names that are set or removed.
+	Changed []string
+	// Update sets the changed attributes, and removes the attributes that are nil or omitted in the modified item.
+	Update expression.UpdateBuilder
+	// Condition is set if DiffOptions.Condition is true and some attributes are changed.
+	Condition *expression.ConditionBuilder
+}
+
+// Empty reports whether no attribute is changed.
+func (d Diff) Empty() bool {
+	return len(d.Changed) == 0
+}
+
+// Build builds the expression for UpdateItem. It returns an error if no attribute is changed.
+func (d Diff) Build() (expression.Expression, error) {
+	if d.Empty() {
+		return expression.Expression{}, errors.New("no attribute is changed")
+	}
+	b := expression.NewBuilder().WithUpdate(d.Update)
+	if d.Condition != nil {
+		b = b.WithCondition(*d.Condition)
+	}
+	return b.Build()
+}
+
+// DiffOf compares original and modified field by field, and returns the update of the changed attributes.
+// The fields are chosen by the same rules as AttributeSetAll, so the keys, the version and the timestamps are skipped.
+// The values are compared after they are marshaled, so ",omitempty" and the other tag options are honoured.
+func DiffOf[V ItemType](original, modified V, opts ...DiffOptionFunc) (Diff, error) {
+	o := DiffOptions{}
+	for _, f := range opts {
+		f(&o)
+	}
+
+	before, err := marshalItem(original)
+	if err != nil {
+		return Diff{}, err
+	}
+	after, err := marshalItem(modified)
+	if err != nil {
+		return Diff{}, err
+	}
+
+	res := Diff{}
+	var cond *expression.ConditionBuilder
+	s, err := schemaOf[V]()
+	if err != nil {
+		return Diff{}, err
+	}
+	for _, f := range s.fields {
+		name := f.name
+		if isSkip(o.Skippers, name) || f.role == roleVersion || f.role.isKey() || f.role.isTimestamp() {
+			continue
+		}
+
+		b, a := before[name], after[name]
+		// sets are marshaled from maps in random order, so the values are compared by their sorted encodings
+		if (b == nil) == (a == nil) && attributeValueKey(b) == attributeValueKey(a) {
+			continue
+		}
+
+		res.Changed = append(res.Changed, name)
+		if isNullAttribute(a) {
+			res.Update = res.Update.Remove(expression.Name(name))
+		} else {
+			res.Update = res.Update.Set(expression.Name(name), expression.Value(a))
+		}
+
+		if o.Condition {
+			c := unchanged(name, b)
+			if cond != nil {
+				c = cond.And(c)
+			}
+			cond = &c
+		}
+	}
+	res.Condition = cond
+
+	return res, nil
+}
+
+// isNullAttribute reports whether av is absent or NULL.
+func isNullAttribute(av types.AttributeValue) bool {
+	if av == nil {
+		return true
+	}
+	_, ok := av.(*types.AttributeValueMemberNULL)
+	return ok
+}
+
+// unchanged is the condition that the stored attribute is still the original value av.
+func unchanged(name string, av types.AttributeValue) expression.ConditionBuilder {
+	if isNullAttribute(av) {
+		return expression.AttributeNotExists(expression.Name(name)).Or(expression.Name(name).AttributeType(expression.Null))
+	}
+	return expression.Name(name).Equal(expression.Value(av))
+}
@@ -0,0 +1,185 @@
+package dorm
+
+import (
+	"context"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/expression"
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDiffOf(t *testing.T) {
+	t.Parallel()
+
+	str := "string"
+	original := utilTestItemOptional{
+		String: "string",
+		Int:    1,
+		PtrSet: &str,
+	}
+
+	tests := map[string]struct {
+		modify func(o *utilTestItemOptional)
+		opts   []DiffOptionFunc
+
+		wantChanged []string
+		wantExpr    expression.Expression
+	}{
+		"no change": {
+			modify: func(o *utilTestItemOptional) {},
+		},
+		"set and remove": {
+			modify: func(o *utilTestItemOptional) {
+				o.Int = 2
+				o.PtrSet = nil
+			},
+			wantChanged: []string{"int", "ptr_set"},
+			wantExpr: mustBuildExpr(expression.NewBuilder().WithUpdate(
+				expression.Set(expression.Name("int"), expression.Value(2)).Remove(expression.Name("ptr_set")),
+			)),
+		},
+		"with condition": {
+			modify: func(o *utilTestItemOptional) {
+				o.String = ""
+				o.OmitEmpty = "set"
+			},
+			opts:        []DiffOptionFunc{WithDiffCondition()},
+			wantChanged: []string{"string", "omit_empty"},
+			wantExpr: mustBuildExpr(expression.NewBuilder().
+				WithUpdate(expression.Set(expression.Name("string"), expression.Value("")).Set(expression.Name("omit_empty"), expression.Value("set"))).
+				WithCondition(expression.Name("string").Equal(expression.Value("string")).And(
+					expression.AttributeNotExists(expression.Name("omit_empty")).Or(expression.Name("omit_empty").AttributeType(expression.Null)),
+				)),
+			),
+		},
+		"with skipper": {
+			modify: func(o *utilTestItemOptional) {
+				o.Int = 2
+				o.String = "changed"
+			},
+			opts:        []DiffOptionFunc{WithDiffSkipper(func(name string) bool { return name == "int" })},
+			wantChanged: []string{"string"},
+			wantExpr: mustBuildExpr(expression.NewBuilder().WithUpdate(
+				expression.Set(expression.Name("string"), expression.Value("changed")),
+			)),
+		},
+	}
+	for name, tt := range tests {
+		tt := tt
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			modified := original
+			tt.modify(&modified)
+
+			got, err := DiffOf(original, modified, tt.opts...)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.wantChanged, got.Changed)
+			if len(tt.wantChanged) == 0 {
+				assert.True(t, got.Empty())
+				_, err = got.Build()
+				assert.Error(t, err)
+				return
+			}
+
+			expr, err := got.Build()
+			assert.NoError(t, err)
+			assert.Equal(t, tt.wantExpr.Update(), expr.Update())
+			assert.Equal(t, tt.wantExpr.Condition(), expr.Condition())
+			assert.Equal(t, tt.wantExpr.Names(), expr.Names())
+			assert.Equal(t, tt.wantExpr.Values(), expr.Values())
+		})
+	}
+}
+
+type diffTestSetItem struct {
+	Item
+	ID  string   `dynamodbav:"id" dorm:"hash"`
+	Set []string `dynamodbav:"set,stringset"`
+}
+
+func (i diffTestSetItem) TableName() string { return "test" }
+
+func TestDiffOfSets(t *testing.T) {
+	t.Parallel()
+
+	original := diffTestSetItem{ID: "id", Set: []string{"a", "b", "c"}}
+
+	// the order of the elements of a set doesn't matter
+	got, err := DiffOf(original, diffTestSetItem{ID: "id", Set: []string{"c", "a", "b"}})
+	assert.NoError(t, err)
+	assert.True(t, got.Empty())
+
+	got, err = DiffOf(original, diffTestSetItem{ID: "id", Set: []string{"c", "a", "d"}})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"set"}, got.Changed)
+}
+
+func testtestItemDiff(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		// concurrent changes the stored item after the original is read.
+		concurrent bool
+		wantErr    error
+	}{
+		"success": {},
+		"changed after read": {
+			concurrent: true,
+			wantErr:    ErrConditionFailed,
+		},
+	}
+	for name, tt := range tests {
+		tt := tt
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			ctx := context.Background()
+
+			db, err := ddbMain.conn()
+			assert.NoError(t, err)
+
+			// randomize
+			o := testItem{}
+			err = RandomizeDDBStruct(&o)
+			assert.NoError(t, err)
+
+			_, err = PutItem(ctx, db, o, expression.Expression{})
+			assert.NoError(t, err)
+
+			original, err := GetItem[testItem](ctx, db, testItemPrimaryIndex{HashKey: o.HashKey}, expression.Expression{})
+			assert.NoError(t, err)
+
+			if tt.concurrent {
+				expr, err := expression.NewBuilder().
+					WithUpdate(expression.Set(expression.Name(testItemColumns.Str), expression.Value("concurrent"))).
+					Build()
+				assert.NoError(t, err)
+				_, err = UpdateItem[testItem](ctx, db, testItemPrimaryIndex{HashKey: o.HashKey}, expr)
+				assert.NoError(t, err)
+			}
+
+			modified := *original
+			modified.Str = "modified"
+			modified.Int64++
+
+			diff, err := DiffOf(*original, modified, WithDiffCondition())
+			assert.NoError(t, err)
+			assert.Equal(t, []string{testItemColumns.Str, testItemColumns.Int64}, diff.Changed)
+
+			expr, err := diff.Build()
+			assert.NoError(t, err)
+
+			got, err := UpdateItem[testItem](ctx, db, testItemPrimaryIndex{HashKey: o.HashKey}, expr)
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+				return
+			}
+			assert.NoError(t, err)
+			if diff := cmp.Diff(&modified, got, cmpopts.IgnoreUnexported(testItem{})); diff != "" {
+				t.Errorf("Compare value is mismatch (-want +got):%s\n", diff)
+			}
+		})
+	}
+}
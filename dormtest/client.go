@@ -0,0 +1,622 @@
+// Package dormtest provides an in-memory DynamoDB engine for unit tests.
+//
+// Client implements the DynamoDB operations used by dorm and evaluates the
+// key condition, filter, projection, condition and update expressions
+// produced by the expression package, so tests can run without a container.
+package dormtest
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+const (
+	maxItemSize           = 400 * 1024
+	maxBatchGetItemSize   = 100
+	maxBatchWriteItemSize = 25
+	maxTransactItemSize   = 100
+)
+
+// Client is an in-memory DynamoDB client. It is safe for concurrent use.
+type Client struct {
+	mu     sync.Mutex
+	tables map[string]*table
+	// tokens holds the ClientRequestTokens of the transactions already applied.
+	tokens map[string]bool
+}
+
+// NewClient creates a new Client without any tables.
+func NewClient() *Client {
+	return &Client{tables: map[string]*table{}, tokens: map[string]bool{}}
+}
+
+func (c *Client) table(name *string) (*table, error) {
+	t, ok := c.tables[aws.ToString(name)]
+	if !ok {
+		return nil, resourceNotFound(aws.ToString(name))
+	}
+	return t, nil
+}
+
+// checkKeyValue validates the type of a key attribute.
+func (t *table) checkKeyValue(name string, v types.AttributeValue) error {
+	want := string(t.attrs[name])
+	if typeName(v) != want {
+		return validationErrorf("One or more parameter values were invalid: Type mismatch for key %s expected: %s actual: %s", name, want, typeName(v))
+	}
+	if s, ok := v.(*types.AttributeValueMemberS); ok && s.Value == "" {
+		return validationErrorf("One or more parameter values are not valid. The AttributeValue for a key attribute cannot contain an empty string value. Key: %s", name)
+	}
+	if b, ok := v.(*types.AttributeValueMemberB); ok && len(b.Value) == 0 {
+		return validationErrorf("One or more parameter values are not valid. The AttributeValue for a key attribute cannot contain an empty binary value. Key: %s", name)
+	}
+	return nil
+}
+
+// keyOf validates a Key parameter and returns its identity.
+func (t *table) keyOf(key map[string]types.AttributeValue) (string, error) {
+	names := t.keys.names()
+	if len(key) != len(names) {
+		return "", validationErrorf("The provided key element does not match the schema")
+	}
+	for _, n := range names {
+		v, ok := key[n]
+		if !ok {
+			return "", validationErrorf("The provided key element does not match the schema")
+		}
+		if err := t.checkKeyValue(n, v); err != nil {
+			return "", err
+		}
+	}
+	return keyString(key, names...), nil
+}
+
+// itemKey validates the key and index attributes of an item and returns its identity.
+func (t *table) itemKey(item map[string]types.AttributeValue) (string, error) {
+	for _, n := range t.keys.names() {
+		v, ok := item[n]
+		if !ok {
+			return "", validationErrorf("One or more parameter values were invalid: Missing the key %s in the item", n)
+		}
+		if err := t.checkKeyValue(n, v); err != nil {
+			return "", err
+		}
+	}
+	for _, idx := range t.indexes {
+		for _, n := range idx.keys.names() {
+			if v, ok := item[n]; ok {
+				if err := t.checkKeyValue(n, v); err != nil {
+					return "", err
+				}
+			}
+		}
+	}
+	if itemSize(item) > maxItemSize {
+		return "", validationErrorf("Item size has exceeded the maximum allowed size")
+	}
+	return keyString(item, t.keys.names()...), nil
+}
+
+// checkCondition evaluates a condition expression against the current item.
+func checkCondition(expr *string, names map[string]string, values map[string]types.AttributeValue, item map[string]types.AttributeValue, rv types.ReturnValuesOnConditionCheckFailure) error {
+	if expr == nil {
+		return nil
+	}
+	cond, err := parseCondition(*expr, names, values)
+	if err != nil {
+		return validationErrorf("Invalid ConditionExpression: %v", err)
+	}
+	if item == nil {
+		item = map[string]types.AttributeValue{}
+	}
+	if cond.eval(item) {
+		return nil
+	}
+	var old map[string]types.AttributeValue
+	if rv == types.ReturnValuesOnConditionCheckFailureAllOld && len(item) > 0 {
+		old = copyItem(item)
+	}
+	return conditionFailed(old)
+}
+
+// GetItem returns the item with the given key.
+func (c *Client) GetItem(ctx context.Context, params *dynamodb.GetItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.GetItemOutput, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	t, err := c.table(params.TableName)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkUnused(params.ExpressionAttributeNames, nil, params.ProjectionExpression); err != nil {
+		return nil, err
+	}
+	k, err := t.keyOf(params.Key)
+	if err != nil {
+		return nil, err
+	}
+	proj, err := projectionOf(params.ProjectionExpression, params.ExpressionAttributeNames)
+	if err != nil {
+		return nil, err
+	}
+	item, ok := t.items[k]
+	if !ok {
+		return &dynamodb.GetItemOutput{}, nil
+	}
+	return &dynamodb.GetItemOutput{Item: project(item, proj)}, nil
+}
+
+// PutItem creates or replaces an item.
+func (c *Client) PutItem(ctx context.Context, params *dynamodb.PutItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.PutItemOutput, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	t, err := c.table(params.TableName)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkUnused(params.ExpressionAttributeNames, params.ExpressionAttributeValues, params.ConditionExpression); err != nil {
+		return nil, err
+	}
+	k, err := t.itemKey(params.Item)
+	if err != nil {
+		return nil, err
+	}
+	old := t.items[k]
+	if err := checkCondition(params.ConditionExpression, params.ExpressionAttributeNames, params.ExpressionAttributeValues, old, params.ReturnValuesOnConditionCheckFailure); err != nil {
+		return nil, err
+	}
+	t.items[k] = copyItem(params.Item)
+
+	out := &dynamodb.PutItemOutput{}
+	switch params.ReturnValues {
+	case types.ReturnValueNone, "":
+	case types.ReturnValueAllOld:
+		out.Attributes = old
+	default:
+		return nil, validationErrorf("ReturnValues can only be ALL_OLD or NONE")
+	}
+	return out, nil
+}
+
+// UpdateItem edits an existing item or creates a new one.
+func (c *Client) UpdateItem(ctx context.Context, params *dynamodb.UpdateItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.UpdateItemOutput, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	t, err := c.table(params.TableName)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkUnused(params.ExpressionAttributeNames, params.ExpressionAttributeValues, params.ConditionExpression, params.UpdateExpression); err != nil {
+		return nil, err
+	}
+	k, err := t.keyOf(params.Key)
+	if err != nil {
+		return nil, err
+	}
+	old := t.items[k]
+	if err := checkCondition(params.ConditionExpression, params.ExpressionAttributeNames, params.ExpressionAttributeValues, old, params.ReturnValuesOnConditionCheckFailure); err != nil {
+		return nil, err
+	}
+
+	next, actions, err := t.update(old, params.Key, params.UpdateExpression, params.ExpressionAttributeNames, params.ExpressionAttributeValues)
+	if err != nil {
+		return nil, err
+	}
+	t.items[k] = next
+
+	out := &dynamodb.UpdateItemOutput{}
+	switch params.ReturnValues {
+	case types.ReturnValueNone, "":
+	case types.ReturnValueAllOld:
+		out.Attributes = copyItem(old)
+	case types.ReturnValueAllNew:
+		out.Attributes = copyItem(next)
+	case types.ReturnValueUpdatedOld:
+		out.Attributes = pick(old, updatedAttributes(actions))
+	case types.ReturnValueUpdatedNew:
+		out.Attributes = pick(next, updatedAttributes(actions))
+	}
+	return out, nil
+}
+
+// update returns the item that results from applying the update expression to old,
+// which is nil if the item identified by key does not exist yet.
+func (t *table) update(old, key map[string]types.AttributeValue, expr *string, names map[string]string, values map[string]types.AttributeValue) (map[string]types.AttributeValue, []updateAction, error) {
+	cur := old
+	if cur == nil {
+		cur = copyItem(key)
+	}
+	var actions []updateAction
+	if expr != nil {
+		var err error
+		actions, err = parseUpdate(*expr, names, values)
+		if err != nil {
+			return nil, nil, validationErrorf("Invalid UpdateExpression: %v", err)
+		}
+	}
+	for _, a := range actions {
+		for _, n := range t.keys.names() {
+			if a.p[0].name == n {
+				return nil, nil, validationErrorf("One or more parameter values were invalid: Cannot update attribute %s. This attribute is part of the key", n)
+			}
+		}
+	}
+	next, err := applyUpdate(cur, actions)
+	if err != nil {
+		return nil, nil, err
+	}
+	if _, err := t.itemKey(next); err != nil {
+		return nil, nil, err
+	}
+	return next, actions, nil
+}
+
+// pick returns a copy of the named top level attributes that exist in the item.
+func pick(item map[string]types.AttributeValue, names []string) map[string]types.AttributeValue {
+	if item == nil {
+		return nil
+	}
+	res := map[string]types.AttributeValue{}
+	for _, n := range names {
+		if v, ok := item[n]; ok {
+			res[n] = copyValue(v)
+		}
+	}
+	if len(res) == 0 {
+		return nil
+	}
+	return res
+}
+
+// DeleteItem deletes the item with the given key.
+func (c *Client) DeleteItem(ctx context.Context, params *dynamodb.DeleteItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.DeleteItemOutput, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	t, err := c.table(params.TableName)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkUnused(params.ExpressionAttributeNames, params.ExpressionAttributeValues, params.ConditionExpression); err != nil {
+		return nil, err
+	}
+	k, err := t.keyOf(params.Key)
+	if err != nil {
+		return nil, err
+	}
+	old := t.items[k]
+	if err := checkCondition(params.ConditionExpression, params.ExpressionAttributeNames, params.ExpressionAttributeValues, old, params.ReturnValuesOnConditionCheckFailure); err != nil {
+		return nil, err
+	}
+	delete(t.items, k)
+
+	out := &dynamodb.DeleteItemOutput{}
+	switch params.ReturnValues {
+	case types.ReturnValueNone, "":
+	case types.ReturnValueAllOld:
+		out.Attributes = old
+	default:
+		return nil, validationErrorf("ReturnValues can only be ALL_OLD or NONE")
+	}
+	return out, nil
+}
+
+func projectionOf(expr *string, names map[string]string) ([]path, error) {
+	if expr == nil {
+		return nil, nil
+	}
+	proj, err := parseProjection(*expr, names)
+	if err != nil {
+		return nil, validationErrorf("Invalid ProjectionExpression: %v", err)
+	}
+	return proj, nil
+}
+
+// cursor describes how the items of a table or an index are ordered and paged.
+type cursor struct {
+	t     *table
+	keys  keySchema
+	index *index
+}
+
+func (c cursor) order(a, b map[string]types.AttributeValue) int {
+	if n := strings.Compare(keyString(a, c.keys.hash), keyString(b, c.keys.hash)); n != 0 {
+		return n
+	}
+	if c.keys.rng != "" {
+		if n, ok := compare(a[c.keys.rng], b[c.keys.rng]); ok && n != 0 {
+			return n
+		}
+	}
+	return strings.Compare(keyString(a, c.t.keys.names()...), keyString(b, c.t.keys.names()...))
+}
+
+// lastKey builds the LastEvaluatedKey for the item.
+func (c cursor) lastKey(item map[string]types.AttributeValue) map[string]types.AttributeValue {
+	names := append(c.t.keys.names(), c.keys.names()...)
+	return pick(item, names)
+}
+
+// project applies the projection of the index and of the request.
+func (c cursor) project(item map[string]types.AttributeValue, proj []path) map[string]types.AttributeValue {
+	if c.index != nil && c.index.projection.ProjectionType != types.ProjectionTypeAll {
+		names := append(c.t.keys.names(), c.keys.names()...)
+		if c.index.projection.ProjectionType == types.ProjectionTypeInclude {
+			names = append(names, c.index.projection.NonKeyAttributes...)
+		}
+		item = pick(item, names)
+	}
+	return project(item, proj)
+}
+
+func (c *Client) cursor(t *table, indexName *string) (cursor, error) {
+	if indexName == nil {
+		return cursor{t: t, keys: t.keys}, nil
+	}
+	idx, ok := t.indexes[*indexName]
+	if !ok {
+		return cursor{}, validationErrorf("The table does not have the specified index: %s", *indexName)
+	}
+	return cursor{t: t, keys: idx.keys, index: idx}, nil
+}
+
+// page evaluates the sorted items from the start key, applying limit, filter and projection.
+func (c cursor) page(sorted []map[string]types.AttributeValue, forward bool, start map[string]types.AttributeValue, limit *int32, filter condition, proj []path, sel types.Select) ([]map[string]types.AttributeValue, int32, map[string]types.AttributeValue) {
+	if !forward {
+		for i, j := 0, len(sorted)-1; i < j; i, j = i+1, j-1 {
+			sorted[i], sorted[j] = sorted[j], sorted[i]
+		}
+	}
+	var res []map[string]types.AttributeValue
+	var scanned int32
+	for _, item := range sorted {
+		if start != nil {
+			n := c.order(item, start)
+			if (forward && n <= 0) || (!forward && n >= 0) {
+				continue
+			}
+		}
+		scanned++
+		if filter == nil || filter.eval(item) {
+			if sel != types.SelectCount {
+				res = append(res, c.project(item, proj))
+			} else {
+				res = append(res, nil)
+			}
+		}
+		if limit != nil && scanned >= *limit {
+			return res, scanned, c.lastKey(item)
+		}
+	}
+	return res, scanned, nil
+}
+
+func (c cursor) candidates(keyCond condition) []map[string]types.AttributeValue {
+	var res []map[string]types.AttributeValue
+	for _, item := range c.t.items {
+		if _, ok := item[c.keys.hash]; !ok {
+			continue
+		}
+		if c.keys.rng != "" {
+			if _, ok := item[c.keys.rng]; !ok {
+				continue
+			}
+		}
+		if keyCond != nil && !keyCond.eval(item) {
+			continue
+		}
+		res = append(res, item)
+	}
+	sort.Slice(res, func(i, j int) bool { return c.order(res[i], res[j]) < 0 })
+	return res
+}
+
+// Query returns the items that match the key condition.
+func (c *Client) Query(ctx context.Context, params *dynamodb.QueryInput, optFns ...func(*dynamodb.Options)) (*dynamodb.QueryOutput, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	t, err := c.table(params.TableName)
+	if err != nil {
+		return nil, err
+	}
+	cur, err := c.cursor(t, params.IndexName)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkUnused(params.ExpressionAttributeNames, params.ExpressionAttributeValues, params.KeyConditionExpression, params.FilterExpression, params.ProjectionExpression); err != nil {
+		return nil, err
+	}
+	if params.KeyConditionExpression == nil {
+		return nil, validationErrorf("Either the KeyConditions or KeyConditionExpression parameter must be specified in the request")
+	}
+	keyCond, err := parseCondition(*params.KeyConditionExpression, params.ExpressionAttributeNames, params.ExpressionAttributeValues)
+	if err != nil {
+		return nil, validationErrorf("Invalid KeyConditionExpression: %v", err)
+	}
+	filter, proj, err := parseFilterAndProjection(params.FilterExpression, params.ProjectionExpression, params.ExpressionAttributeNames, params.ExpressionAttributeValues)
+	if err != nil {
+		return nil, err
+	}
+
+	forward := params.ScanIndexForward == nil || *params.ScanIndexForward
+	items, scanned, last := cur.page(cur.candidates(keyCond), forward, params.ExclusiveStartKey, params.Limit, filter, proj, params.Select)
+
+	out := &dynamodb.QueryOutput{
+		Count:            int32(len(items)),
+		ScannedCount:     scanned,
+		LastEvaluatedKey: last,
+	}
+	if params.Select != types.SelectCount {
+		out.Items = items
+	}
+	return out, nil
+}
+
+// Scan returns every item of the table or index that matches the filter.
+func (c *Client) Scan(ctx context.Context, params *dynamodb.ScanInput, optFns ...func(*dynamodb.Options)) (*dynamodb.ScanOutput, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	t, err := c.table(params.TableName)
+	if err != nil {
+		return nil, err
+	}
+	cur, err := c.cursor(t, params.IndexName)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkUnused(params.ExpressionAttributeNames, params.ExpressionAttributeValues, params.FilterExpression, params.ProjectionExpression); err != nil {
+		return nil, err
+	}
+	filter, proj, err := parseFilterAndProjection(params.FilterExpression, params.ProjectionExpression, params.ExpressionAttributeNames, params.ExpressionAttributeValues)
+	if err != nil {
+		return nil, err
+	}
+
+	items, scanned, last := cur.page(cur.candidates(nil), true, params.ExclusiveStartKey, params.Limit, filter, proj, params.Select)
+
+	out := &dynamodb.ScanOutput{
+		Count:            int32(len(items)),
+		ScannedCount:     scanned,
+		LastEvaluatedKey: last,
+	}
+	if params.Select != types.SelectCount {
+		out.Items = items
+	}
+	return out, nil
+}
+
+func parseFilterAndProjection(filterExpr, projExpr *string, names map[string]string, values map[string]types.AttributeValue) (condition, []path, error) {
+	var filter condition
+	if filterExpr != nil {
+		var err error
+		filter, err = parseCondition(*filterExpr, names, values)
+		if err != nil {
+			return nil, nil, validationErrorf("Invalid FilterExpression: %v", err)
+		}
+	}
+	proj, err := projectionOf(projExpr, names)
+	if err != nil {
+		return nil, nil, err
+	}
+	return filter, proj, nil
+}
+
+// BatchGetItem returns the items with the given keys from one or more tables.
+func (c *Client) BatchGetItem(ctx context.Context, params *dynamodb.BatchGetItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.BatchGetItemOutput, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	total := 0
+	for _, ka := range params.RequestItems {
+		total += len(ka.Keys)
+	}
+	if total > maxBatchGetItemSize {
+		return nil, validationErrorf("Too many items requested for the BatchGetItem call")
+	}
+
+	out := &dynamodb.BatchGetItemOutput{
+		Responses:       map[string][]map[string]types.AttributeValue{},
+		UnprocessedKeys: map[string]types.KeysAndAttributes{},
+	}
+	for name, ka := range params.RequestItems {
+		t, err := c.table(aws.String(name))
+		if err != nil {
+			return nil, err
+		}
+		if err := checkUnused(ka.ExpressionAttributeNames, nil, ka.ProjectionExpression); err != nil {
+			return nil, err
+		}
+		proj, err := projectionOf(ka.ProjectionExpression, ka.ExpressionAttributeNames)
+		if err != nil {
+			return nil, err
+		}
+		seen := map[string]bool{}
+		res := []map[string]types.AttributeValue{}
+		for _, key := range ka.Keys {
+			k, err := t.keyOf(key)
+			if err != nil {
+				return nil, err
+			}
+			if seen[k] {
+				return nil, validationErrorf("Provided list of item keys contains duplicates")
+			}
+			seen[k] = true
+			if item, ok := t.items[k]; ok {
+				res = append(res, project(item, proj))
+			}
+		}
+		out.Responses[name] = res
+	}
+	return out, nil
+}
+
+// BatchWriteItem puts or deletes multiple items in one or more tables.
+func (c *Client) BatchWriteItem(ctx context.Context, params *dynamodb.BatchWriteItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.BatchWriteItemOutput, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	total := 0
+	for _, reqs := range params.RequestItems {
+		total += len(reqs)
+	}
+	if total == 0 || total > maxBatchWriteItemSize {
+		return nil, validationErrorf("Member must have length less than or equal to 25")
+	}
+
+	type write struct {
+		t    *table
+		k    string
+		item map[string]types.AttributeValue
+	}
+	var writes []write
+	for name, reqs := range params.RequestItems {
+		t, err := c.table(aws.String(name))
+		if err != nil {
+			return nil, err
+		}
+		seen := map[string]bool{}
+		for _, r := range reqs {
+			var w write
+			switch {
+			case r.PutRequest != nil:
+				k, err := t.itemKey(r.PutRequest.Item)
+				if err != nil {
+					return nil, err
+				}
+				w = write{t: t, k: k, item: copyItem(r.PutRequest.Item)}
+			case r.DeleteRequest != nil:
+				k, err := t.keyOf(r.DeleteRequest.Key)
+				if err != nil {
+					return nil, err
+				}
+				w = write{t: t, k: k}
+			default:
+				return nil, validationErrorf("Supplied AttributeValue has no PutRequest or DeleteRequest")
+			}
+			if seen[w.k] {
+				return nil, validationErrorf("Provided list of item keys contains duplicates")
+			}
+			seen[w.k] = true
+			writes = append(writes, w)
+		}
+	}
+
+	for _, w := range writes {
+		if w.item == nil {
+			delete(w.t.items, w.k)
+		} else {
+			w.t.items[w.k] = w.item
+		}
+	}
+	return &dynamodb.BatchWriteItemOutput{UnprocessedItems: map[string][]types.WriteRequest{}}, nil
+}
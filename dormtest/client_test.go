@@ -0,0 +1,363 @@
+package dormtest_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/expression"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/cockroachdb/errors"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/hijiki51/dorm"
+	"github.com/hijiki51/dorm/dormtest"
+)
+
+var _ dorm.DynamoDBAPI = (*dormtest.Client)(nil)
+var _ dorm.TableAPI = (*dormtest.Client)(nil)
+var _ dorm.MigrationAPI = (*dormtest.Client)(nil)
+
+const fakeItemTableName = "fake-item"
+
+type fakeItem struct {
+	dorm.Item `dynamodbav:"-"`
+	HashKey   string   `dynamodbav:"hash_key"`
+	RangeKey  int      `dynamodbav:"range_key"`
+	GSIKey    string   `dynamodbav:"gsi_key,omitempty"`
+	Str       string   `dynamodbav:"str"`
+	Count     int      `dynamodbav:"count"`
+	Tags      []string `dynamodbav:"tags,stringset,omitempty"`
+	List      []string `dynamodbav:"list"`
+	Nested    struct {
+		Name string `dynamodbav:"name"`
+	} `dynamodbav:"nested"`
+}
+
+type fakeItemPrimaryIndex struct {
+	dorm.PrimaryIndex `dynamodbav:"-"`
+	HashKey           string `dynamodbav:"hash_key"`
+	RangeKey          int    `dynamodbav:"range_key"`
+}
+
+func (fakeItem) TableName() string { return fakeItemTableName }
+
+func newFakeClient(t *testing.T) *dormtest.Client {
+	t.Helper()
+	db := dormtest.NewClient()
+	_, err := db.CreateTable(context.Background(), &dynamodb.CreateTableInput{
+		TableName: aws.String(fakeItemTableName),
+		AttributeDefinitions: []types.AttributeDefinition{
+			{AttributeName: aws.String("hash_key"), AttributeType: types.ScalarAttributeTypeS},
+			{AttributeName: aws.String("range_key"), AttributeType: types.ScalarAttributeTypeN},
+			{AttributeName: aws.String("gsi_key"), AttributeType: types.ScalarAttributeTypeS},
+		},
+		KeySchema: []types.KeySchemaElement{
+			{AttributeName: aws.String("hash_key"), KeyType: types.KeyTypeHash},
+			{AttributeName: aws.String("range_key"), KeyType: types.KeyTypeRange},
+		},
+		GlobalSecondaryIndexes: []types.GlobalSecondaryIndex{
+			{
+				IndexName: aws.String("gsi"),
+				KeySchema: []types.KeySchemaElement{
+					{AttributeName: aws.String("gsi_key"), KeyType: types.KeyTypeHash},
+					{AttributeName: aws.String("range_key"), KeyType: types.KeyTypeRange},
+				},
+				Projection: &types.Projection{ProjectionType: types.ProjectionTypeKeysOnly},
+			},
+		},
+	})
+	assert.NoError(t, err)
+	return db
+}
+
+func putFakeItems(t *testing.T, db dorm.DynamoDBAPI, hash string, n int) []fakeItem {
+	t.Helper()
+	items := make([]fakeItem, n)
+	for i := range items {
+		items[i] = fakeItem{HashKey: hash, RangeKey: i, GSIKey: "gsi-" + hash, Str: "str", Count: i, List: []string{"a"}}
+		items[i].Nested.Name = "name"
+	}
+	assert.NoError(t, dorm.BatchPutItem(context.Background(), db, items))
+	return items
+}
+
+func mustBuild(t *testing.T, b expression.Builder) expression.Expression {
+	t.Helper()
+	expr, err := b.Build()
+	assert.NoError(t, err)
+	return expr
+}
+
+func TestClientItem(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		run func(t *testing.T, db *dormtest.Client)
+	}{
+		"put and get": {
+			run: func(t *testing.T, db *dormtest.Client) {
+				items := putFakeItems(t, db, "put", 1)
+				got, err := dorm.GetItem[fakeItem](context.Background(), db, fakeItemPrimaryIndex{HashKey: "put", RangeKey: 0}, dorm.NopExpression)
+				assert.NoError(t, err)
+				assert.Equal(t, items[0], *got)
+			},
+		},
+		"get not found": {
+			run: func(t *testing.T, db *dormtest.Client) {
+				_, err := dorm.GetItem[fakeItem](context.Background(), db, fakeItemPrimaryIndex{HashKey: "none"}, dorm.NopExpression)
+				assert.ErrorIs(t, err, dorm.ErrItemNotFound)
+			},
+		},
+		"get with projection": {
+			run: func(t *testing.T, db *dormtest.Client) {
+				putFakeItems(t, db, "proj", 1)
+				expr := mustBuild(t, expression.NewBuilder().WithProjection(expression.NamesList(expression.Name("hash_key"), expression.Name("nested.name"))))
+				got, err := dorm.GetItem[fakeItem](context.Background(), db, fakeItemPrimaryIndex{HashKey: "proj"}, expr)
+				assert.NoError(t, err)
+				assert.Equal(t, "proj", got.HashKey)
+				assert.Equal(t, "name", got.Nested.Name)
+				assert.Empty(t, got.Str)
+			},
+		},
+		"key mismatch": {
+			run: func(t *testing.T, db *dormtest.Client) {
+				_, err := db.GetItem(context.Background(), &dynamodb.GetItemInput{
+					TableName: aws.String(fakeItemTableName),
+					Key:       map[string]types.AttributeValue{"hash_key": &types.AttributeValueMemberS{Value: "a"}},
+				})
+				assert.Error(t, err)
+			},
+		},
+		"missing table": {
+			run: func(t *testing.T, db *dormtest.Client) {
+				_, err := db.GetItem(context.Background(), &dynamodb.GetItemInput{TableName: aws.String("missing")})
+				var rnf *types.ResourceNotFoundException
+				assert.True(t, errors.As(err, &rnf))
+			},
+		},
+		"put condition failed": {
+			run: func(t *testing.T, db *dormtest.Client) {
+				items := putFakeItems(t, db, "cond", 1)
+				expr := mustBuild(t, expression.NewBuilder().WithCondition(expression.AttributeNotExists(expression.Name("hash_key"))))
+				_, err := dorm.PutItem(context.Background(), db, items[0], expr)
+				var ccf *types.ConditionalCheckFailedException
+				assert.True(t, errors.As(err, &ccf))
+			},
+		},
+		"update expression": {
+			run: func(t *testing.T, db *dormtest.Client) {
+				putFakeItems(t, db, "update", 1)
+				upd := expression.Set(expression.Name("count"), expression.Name("count").Plus(expression.Value(2))).
+					Set(expression.Name("str"), expression.IfNotExists(expression.Name("missing"), expression.Value("default"))).
+					Set(expression.Name("list"), expression.ListAppend(expression.Name("list"), expression.Value([]string{"b"}))).
+					Set(expression.Name("nested.name"), expression.Value("renamed")).
+					Add(expression.Name("tags"), expression.Value(&types.AttributeValueMemberSS{Value: []string{"x", "y"}})).
+					Remove(expression.Name("gsi_key"))
+				cond := expression.Name("count").LessThan(expression.Value(1)).And(expression.Name("str").BeginsWith("st"))
+				expr := mustBuild(t, expression.NewBuilder().WithUpdate(upd).WithCondition(cond))
+				got, err := dorm.UpdateItem[fakeItem](context.Background(), db, fakeItemPrimaryIndex{HashKey: "update"}, expr)
+				assert.NoError(t, err)
+				assert.Equal(t, 2, got.Count)
+				assert.Equal(t, "default", got.Str)
+				assert.Equal(t, []string{"a", "b"}, got.List)
+				assert.Equal(t, "renamed", got.Nested.Name)
+				assert.ElementsMatch(t, []string{"x", "y"}, got.Tags)
+				assert.Empty(t, got.GSIKey)
+
+				expr = mustBuild(t, expression.NewBuilder().WithUpdate(expression.Delete(expression.Name("tags"), expression.Value(&types.AttributeValueMemberSS{Value: []string{"x"}}))))
+				got, err = dorm.UpdateItem[fakeItem](context.Background(), db, fakeItemPrimaryIndex{HashKey: "update"}, expr)
+				assert.NoError(t, err)
+				assert.Equal(t, []string{"y"}, got.Tags)
+			},
+		},
+		"update key attribute": {
+			run: func(t *testing.T, db *dormtest.Client) {
+				putFakeItems(t, db, "updatekey", 1)
+				expr := mustBuild(t, expression.NewBuilder().WithUpdate(expression.Set(expression.Name("range_key"), expression.Value(1))))
+				_, err := dorm.UpdateItem[fakeItem](context.Background(), db, fakeItemPrimaryIndex{HashKey: "updatekey"}, expr)
+				assert.Error(t, err)
+			},
+		},
+		"delete": {
+			run: func(t *testing.T, db *dormtest.Client) {
+				putFakeItems(t, db, "delete", 2)
+				_, err := dorm.DeleteItem[fakeItem](context.Background(), db, fakeItemPrimaryIndex{HashKey: "delete"}, dorm.NopExpression)
+				assert.NoError(t, err)
+				_, err = dorm.GetItem[fakeItem](context.Background(), db, fakeItemPrimaryIndex{HashKey: "delete"}, dorm.NopExpression)
+				assert.ErrorIs(t, err, dorm.ErrItemNotFound)
+				_, err = dorm.GetItem[fakeItem](context.Background(), db, fakeItemPrimaryIndex{HashKey: "delete", RangeKey: 1}, dorm.NopExpression)
+				assert.NoError(t, err)
+			},
+		},
+	}
+	for name, tt := range tests {
+		tt := tt
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			tt.run(t, newFakeClient(t))
+		})
+	}
+}
+
+func TestClientQuery(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		run func(t *testing.T, db *dormtest.Client)
+	}{
+		"range condition and filter": {
+			run: func(t *testing.T, db *dormtest.Client) {
+				putFakeItems(t, db, "query", 10)
+				putFakeItems(t, db, "other", 3)
+				kc := expression.Key("hash_key").Equal(expression.Value("query")).And(expression.Key("range_key").Between(expression.Value(2), expression.Value(7)))
+				filter := expression.Name("count").In(expression.Value(3), expression.Value(5), expression.Value(9))
+				expr := mustBuild(t, expression.NewBuilder().WithKeyCondition(kc).WithFilter(filter))
+				got, err := dorm.QueryAll[fakeItem](context.Background(), db, expr, dorm.WithReverse(true))
+				assert.NoError(t, err)
+				assert.Len(t, got, 2)
+				assert.Equal(t, 3, got[0].RangeKey)
+				assert.Equal(t, 5, got[1].RangeKey)
+			},
+		},
+		"descending with limit": {
+			run: func(t *testing.T, db *dormtest.Client) {
+				putFakeItems(t, db, "desc", 5)
+				kc := expression.Key("hash_key").Equal(expression.Value("desc"))
+				expr := mustBuild(t, expression.NewBuilder().WithKeyCondition(kc))
+				got, last, err := dorm.Query[fakeItem](context.Background(), db, expr, dorm.WithLimit(2))
+				assert.NoError(t, err)
+				assert.Equal(t, []int{4, 3}, []int{got[0].RangeKey, got[1].RangeKey})
+				assert.NotEmpty(t, last)
+
+				got, _, err = dorm.Query[fakeItem](context.Background(), db, expr, dorm.WithLimit(2), dorm.WithExclusiveStartKey(last))
+				assert.NoError(t, err)
+				assert.Equal(t, []int{2, 1}, []int{got[0].RangeKey, got[1].RangeKey})
+			},
+		},
+		"global secondary index": {
+			run: func(t *testing.T, db *dormtest.Client) {
+				putFakeItems(t, db, "gsi", 4)
+				kc := expression.Key("gsi_key").Equal(expression.Value("gsi-gsi")).And(expression.Key("range_key").GreaterThanEqual(expression.Value(1)))
+				expr := mustBuild(t, expression.NewBuilder().WithKeyCondition(kc))
+				got, err := dorm.QueryAll[fakeItem](context.Background(), db, expr, dorm.WithIndexName("gsi"), dorm.WithLimit(1), dorm.WithReverse(true))
+				assert.NoError(t, err)
+				assert.Len(t, got, 3)
+				for _, v := range got {
+					// The index only projects the keys.
+					assert.Empty(t, v.Str)
+				}
+			},
+		},
+		"scan all pages": {
+			run: func(t *testing.T, db *dormtest.Client) {
+				putFakeItems(t, db, "a", 3)
+				putFakeItems(t, db, "b", 4)
+				filter := expression.Name("range_key").GreaterThan(expression.Value(0))
+				expr := mustBuild(t, expression.NewBuilder().WithFilter(filter))
+				got, err := dorm.ScanAll[fakeItem](context.Background(), db, expr, dorm.WithScanLimit(2))
+				assert.NoError(t, err)
+				assert.Len(t, got, 5)
+			},
+		},
+		"batch get": {
+			run: func(t *testing.T, db *dormtest.Client) {
+				putFakeItems(t, db, "batch", 3)
+				idxs := []dorm.PrimaryIndex{
+					fakeItemPrimaryIndex{HashKey: "batch", RangeKey: 0},
+					fakeItemPrimaryIndex{HashKey: "batch", RangeKey: 2},
+					fakeItemPrimaryIndex{HashKey: "batch", RangeKey: 5},
+				}
+				got, err := dorm.BatchGetItems[fakeItem](context.Background(), db, idxs, dorm.NopExpression)
+				assert.NoError(t, err)
+				assert.Len(t, got, 2)
+			},
+		},
+		"batch delete": {
+			run: func(t *testing.T, db *dormtest.Client) {
+				putFakeItems(t, db, "batchdelete", 30)
+				keys := make([]dorm.PrimaryIndex, 30)
+				for i := range keys {
+					keys[i] = fakeItemPrimaryIndex{HashKey: "batchdelete", RangeKey: i}
+				}
+				assert.NoError(t, dorm.BatchDeleteItem[fakeItem](context.Background(), db, keys))
+				out, err := db.DescribeTable(context.Background(), &dynamodb.DescribeTableInput{TableName: aws.String(fakeItemTableName)})
+				assert.NoError(t, err)
+				assert.Equal(t, int64(0), aws.ToInt64(out.Table.ItemCount))
+			},
+		},
+	}
+	for name, tt := range tests {
+		tt := tt
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			tt.run(t, newFakeClient(t))
+		})
+	}
+}
+
+func TestClientTransactWriteItems(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		run func(t *testing.T, db *dormtest.Client)
+	}{
+		"cancellation reasons": {
+			run: func(t *testing.T, db *dormtest.Client) {
+				items := putFakeItems(t, db, "tx", 2)
+				cond := mustBuild(t, expression.NewBuilder().WithCondition(expression.AttributeNotExists(expression.Name("hash_key"))))
+				deleted, err := attributevalue.MarshalMap(fakeItemPrimaryIndex{HashKey: "tx", RangeKey: 0})
+				assert.NoError(t, err)
+				key, err := attributevalue.MarshalMap(fakeItemPrimaryIndex{HashKey: "tx", RangeKey: 1})
+				assert.NoError(t, err)
+				_, err = db.TransactWriteItems(context.Background(), &dynamodb.TransactWriteItemsInput{
+					TransactItems: []types.TransactWriteItem{
+						{Delete: &types.Delete{
+							TableName: aws.String(fakeItemTableName),
+							Key:       deleted,
+						}},
+						{ConditionCheck: &types.ConditionCheck{
+							TableName:                           aws.String(fakeItemTableName),
+							Key:                                 key,
+							ConditionExpression:                 cond.Condition(),
+							ExpressionAttributeNames:            cond.Names(),
+							ReturnValuesOnConditionCheckFailure: types.ReturnValuesOnConditionCheckFailureAllOld,
+						}},
+					},
+				})
+				var cerr *types.TransactionCanceledException
+				assert.True(t, errors.As(err, &cerr))
+				assert.Equal(t, "None", aws.ToString(cerr.CancellationReasons[0].Code))
+				assert.Equal(t, "ConditionalCheckFailed", aws.ToString(cerr.CancellationReasons[1].Code))
+
+				var old fakeItem
+				assert.NoError(t, attributevalue.UnmarshalMap(cerr.CancellationReasons[1].Item, &old))
+				assert.Equal(t, items[1], old)
+
+				// the delete is not applied
+				_, err = dorm.GetItem[fakeItem](context.Background(), db, fakeItemPrimaryIndex{HashKey: "tx", RangeKey: 0}, dorm.NopExpression)
+				assert.NoError(t, err)
+			},
+		},
+		"multiple operations on one item": {
+			run: func(t *testing.T, db *dormtest.Client) {
+				items := putFakeItems(t, db, "dup", 1)
+				idx := fakeItemPrimaryIndex{HashKey: "dup"}
+				err := dorm.TransactWriteItems(context.Background(), db, dorm.NewTransactWriteBuilder().Add(
+					dorm.TransactPut(items[0], dorm.NopExpression),
+					dorm.TransactDelete[fakeItem](idx, dorm.NopExpression),
+				))
+				assert.Error(t, err)
+			},
+		},
+	}
+	for name, tt := range tests {
+		tt := tt
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			tt.run(t, newFakeClient(t))
+		})
+	}
+}
@@ -0,0 +1,478 @@
+package dormtest
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// condition is a parsed condition, filter or key condition expression.
+type condition interface {
+	eval(item map[string]types.AttributeValue) bool
+}
+
+// operand is a value referenced from a condition.
+type operand interface {
+	value(item map[string]types.AttributeValue) (types.AttributeValue, bool)
+}
+
+type pathOperand struct{ p path }
+
+func (o pathOperand) value(item map[string]types.AttributeValue) (types.AttributeValue, bool) {
+	return o.p.get(item)
+}
+
+type valueOperand struct{ v types.AttributeValue }
+
+func (o valueOperand) value(map[string]types.AttributeValue) (types.AttributeValue, bool) {
+	return o.v, true
+}
+
+type sizeOperand struct{ p path }
+
+func (o sizeOperand) value(item map[string]types.AttributeValue) (types.AttributeValue, bool) {
+	v, ok := o.p.get(item)
+	if !ok {
+		return nil, false
+	}
+	n, ok := size(v)
+	if !ok {
+		return nil, false
+	}
+	return &types.AttributeValueMemberN{Value: fmt.Sprint(n)}, true
+}
+
+type andCond struct{ l, r condition }
+
+func (c andCond) eval(item map[string]types.AttributeValue) bool {
+	return c.l.eval(item) && c.r.eval(item)
+}
+
+type orCond struct{ l, r condition }
+
+func (c orCond) eval(item map[string]types.AttributeValue) bool {
+	return c.l.eval(item) || c.r.eval(item)
+}
+
+type notCond struct{ c condition }
+
+func (c notCond) eval(item map[string]types.AttributeValue) bool {
+	return !c.c.eval(item)
+}
+
+type compareCond struct {
+	op   tokenKind
+	l, r operand
+}
+
+func (c compareCond) eval(item map[string]types.AttributeValue) bool {
+	l, lok := c.l.value(item)
+	r, rok := c.r.value(item)
+	if c.op == tokNe {
+		if !lok || !rok {
+			return lok != rok
+		}
+		return !equal(l, r)
+	}
+	if !lok || !rok {
+		return false
+	}
+	if c.op == tokEq {
+		return equal(l, r)
+	}
+	n, ok := compare(l, r)
+	if !ok {
+		return false
+	}
+	switch c.op {
+	case tokLt:
+		return n < 0
+	case tokLe:
+		return n <= 0
+	case tokGt:
+		return n > 0
+	case tokGe:
+		return n >= 0
+	}
+	return false
+}
+
+type betweenCond struct{ v, lo, hi operand }
+
+func (c betweenCond) eval(item map[string]types.AttributeValue) bool {
+	v, ok1 := c.v.value(item)
+	lo, ok2 := c.lo.value(item)
+	hi, ok3 := c.hi.value(item)
+	if !ok1 || !ok2 || !ok3 {
+		return false
+	}
+	a, ok := compare(v, lo)
+	if !ok {
+		return false
+	}
+	b, ok := compare(v, hi)
+	if !ok {
+		return false
+	}
+	return a >= 0 && b <= 0
+}
+
+type inCond struct {
+	v    operand
+	list []operand
+}
+
+func (c inCond) eval(item map[string]types.AttributeValue) bool {
+	v, ok := c.v.value(item)
+	if !ok {
+		return false
+	}
+	for _, o := range c.list {
+		w, ok := o.value(item)
+		if ok && equal(v, w) {
+			return true
+		}
+	}
+	return false
+}
+
+type funcCond struct {
+	name string
+	p    path
+	arg  operand
+}
+
+func (c funcCond) eval(item map[string]types.AttributeValue) bool {
+	v, exists := c.p.get(item)
+	switch c.name {
+	case "attribute_exists":
+		return exists
+	case "attribute_not_exists":
+		return !exists
+	}
+	if !exists {
+		return false
+	}
+	arg, ok := c.arg.value(item)
+	if !ok {
+		return false
+	}
+	switch c.name {
+	case "attribute_type":
+		s, ok := arg.(*types.AttributeValueMemberS)
+		return ok && s.Value == typeName(v)
+	case "begins_with":
+		switch x := v.(type) {
+		case *types.AttributeValueMemberS:
+			s, ok := arg.(*types.AttributeValueMemberS)
+			return ok && strings.HasPrefix(x.Value, s.Value)
+		case *types.AttributeValueMemberB:
+			b, ok := arg.(*types.AttributeValueMemberB)
+			return ok && bytes.HasPrefix(x.Value, b.Value)
+		}
+	case "contains":
+		switch x := v.(type) {
+		case *types.AttributeValueMemberS:
+			s, ok := arg.(*types.AttributeValueMemberS)
+			return ok && strings.Contains(x.Value, s.Value)
+		case *types.AttributeValueMemberB:
+			b, ok := arg.(*types.AttributeValueMemberB)
+			return ok && bytes.Contains(x.Value, b.Value)
+		case *types.AttributeValueMemberSS:
+			s, ok := arg.(*types.AttributeValueMemberS)
+			if !ok {
+				return false
+			}
+			for _, e := range x.Value {
+				if e == s.Value {
+					return true
+				}
+			}
+		case *types.AttributeValueMemberNS:
+			if _, ok := arg.(*types.AttributeValueMemberN); !ok {
+				return false
+			}
+			for _, e := range x.Value {
+				if equal(&types.AttributeValueMemberN{Value: e}, arg) {
+					return true
+				}
+			}
+		case *types.AttributeValueMemberBS:
+			b, ok := arg.(*types.AttributeValueMemberB)
+			if !ok {
+				return false
+			}
+			for _, e := range x.Value {
+				if bytes.Equal(e, b.Value) {
+					return true
+				}
+			}
+		case *types.AttributeValueMemberL:
+			for _, e := range x.Value {
+				if equal(e, arg) {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+// parseCondition parses a condition expression.
+func parseCondition(expr string, names map[string]string, values map[string]types.AttributeValue) (condition, error) {
+	p, err := newParser(expr, names, values)
+	if err != nil {
+		return nil, err
+	}
+	c, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if t := p.peek(); t.kind != tokEOF {
+		return nil, fmt.Errorf("unexpected token %q", t.text)
+	}
+	return c, nil
+}
+
+func (p *parser) parseOr() (condition, error) {
+	l, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.isKeyword("OR") {
+		p.next()
+		r, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		l = orCond{l, r}
+	}
+	return l, nil
+}
+
+func (p *parser) parseAnd() (condition, error) {
+	l, err := p.parseNot()
+	if err != nil {
+		return nil, err
+	}
+	for p.isKeyword("AND") {
+		p.next()
+		r, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		l = andCond{l, r}
+	}
+	return l, nil
+}
+
+func (p *parser) parseNot() (condition, error) {
+	if p.isKeyword("NOT") {
+		p.next()
+		c, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		return notCond{c}, nil
+	}
+	return p.parsePrimary()
+}
+
+func (p *parser) parsePrimary() (condition, error) {
+	if p.peek().kind == tokLParen {
+		p.next()
+		c, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if _, err := p.expect(tokRParen, ")"); err != nil {
+			return nil, err
+		}
+		return c, nil
+	}
+
+	if t := p.peek(); t.kind == tokIdent && p.toks[p.pos+1].kind == tokLParen {
+		switch name := strings.ToLower(t.text); name {
+		case "attribute_exists", "attribute_not_exists", "attribute_type", "begins_with", "contains":
+			return p.parseFunction(name)
+		}
+	}
+
+	l, err := p.parseOperand()
+	if err != nil {
+		return nil, err
+	}
+
+	switch t := p.next(); t.kind {
+	case tokEq, tokNe, tokLt, tokLe, tokGt, tokGe:
+		r, err := p.parseOperand()
+		if err != nil {
+			return nil, err
+		}
+		return compareCond{op: t.kind, l: l, r: r}, nil
+	case tokIdent:
+		switch strings.ToUpper(t.text) {
+		case "BETWEEN":
+			lo, err := p.parseOperand()
+			if err != nil {
+				return nil, err
+			}
+			if !p.isKeyword("AND") {
+				return nil, fmt.Errorf("expected AND in BETWEEN")
+			}
+			p.next()
+			hi, err := p.parseOperand()
+			if err != nil {
+				return nil, err
+			}
+			return betweenCond{v: l, lo: lo, hi: hi}, nil
+		case "IN":
+			if _, err := p.expect(tokLParen, "("); err != nil {
+				return nil, err
+			}
+			var list []operand
+			for {
+				o, err := p.parseOperand()
+				if err != nil {
+					return nil, err
+				}
+				list = append(list, o)
+				if p.peek().kind != tokComma {
+					break
+				}
+				p.next()
+			}
+			if _, err := p.expect(tokRParen, ")"); err != nil {
+				return nil, err
+			}
+			return inCond{v: l, list: list}, nil
+		}
+		return nil, fmt.Errorf("unexpected keyword %q", t.text)
+	default:
+		return nil, fmt.Errorf("unexpected token %q", t.text)
+	}
+}
+
+func (p *parser) parseFunction(name string) (condition, error) {
+	p.next()
+	p.next()
+	pth, err := p.parsePath()
+	if err != nil {
+		return nil, err
+	}
+	c := funcCond{name: name, p: pth}
+	if name != "attribute_exists" && name != "attribute_not_exists" {
+		if _, err := p.expect(tokComma, ","); err != nil {
+			return nil, err
+		}
+		if c.arg, err = p.parseOperand(); err != nil {
+			return nil, err
+		}
+	}
+	if _, err := p.expect(tokRParen, ")"); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+func (p *parser) parseOperand() (operand, error) {
+	t := p.peek()
+	switch {
+	case t.kind == tokValue:
+		p.next()
+		v, ok := p.values[t.text]
+		if !ok {
+			return nil, fmt.Errorf("expression attribute value %s is not defined", t.text)
+		}
+		return valueOperand{v}, nil
+	case t.kind == tokIdent && strings.EqualFold(t.text, "size") && p.toks[p.pos+1].kind == tokLParen:
+		p.next()
+		p.next()
+		pth, err := p.parsePath()
+		if err != nil {
+			return nil, err
+		}
+		if _, err := p.expect(tokRParen, ")"); err != nil {
+			return nil, err
+		}
+		return sizeOperand{pth}, nil
+	default:
+		pth, err := p.parsePath()
+		if err != nil {
+			return nil, err
+		}
+		return pathOperand{pth}, nil
+	}
+}
+
+// parseProjection parses a projection expression into its paths.
+func parseProjection(expr string, names map[string]string) ([]path, error) {
+	p, err := newParser(expr, names, nil)
+	if err != nil {
+		return nil, err
+	}
+	var res []path
+	for {
+		pth, err := p.parsePath()
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, pth)
+		if p.peek().kind != tokComma {
+			break
+		}
+		p.next()
+	}
+	if t := p.peek(); t.kind != tokEOF {
+		return nil, fmt.Errorf("unexpected token %q", t.text)
+	}
+	return res, nil
+}
+
+// project builds a new item that only contains the given paths.
+func project(item map[string]types.AttributeValue, paths []path) map[string]types.AttributeValue {
+	if len(paths) == 0 {
+		return copyItem(item)
+	}
+	res := map[string]types.AttributeValue{}
+	for _, pth := range paths {
+		v, ok := pth.get(item)
+		if !ok {
+			continue
+		}
+		projectInto(res, pth, copyValue(v))
+	}
+	return res
+}
+
+// projectInto places v at pth in dst, creating intermediate maps and lists as needed.
+func projectInto(dst map[string]types.AttributeValue, pth path, v types.AttributeValue) {
+	var cur types.AttributeValue = &types.AttributeValueMemberM{Value: dst}
+	for i, e := range pth {
+		last := i == len(pth)-1
+		var next types.AttributeValue
+		if !last {
+			if pth[i+1].isIndex {
+				next = &types.AttributeValueMemberL{}
+			} else {
+				next = &types.AttributeValueMemberM{Value: map[string]types.AttributeValue{}}
+			}
+		} else {
+			next = v
+		}
+		switch c := cur.(type) {
+		case *types.AttributeValueMemberM:
+			if existing, ok := c.Value[e.name]; ok && !last {
+				cur = existing
+				continue
+			}
+			c.Value[e.name] = next
+		case *types.AttributeValueMemberL:
+			// Projected list elements keep their relative order, not their original index.
+			c.Value = append(c.Value, next)
+		}
+		cur = next
+	}
+}
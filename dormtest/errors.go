@@ -0,0 +1,67 @@
+package dormtest
+
+import (
+	"fmt"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/aws/smithy-go"
+)
+
+func validationErrorf(format string, args ...interface{}) error {
+	return &smithy.GenericAPIError{
+		Code:    "ValidationException",
+		Message: fmt.Sprintf(format, args...),
+		Fault:   smithy.FaultClient,
+	}
+}
+
+func resourceNotFound(name string) error {
+	return &types.ResourceNotFoundException{
+		Message: aws.String(fmt.Sprintf("Requested resource not found: Table: %s not found", name)),
+	}
+}
+
+func resourceInUse(name string) error {
+	return &types.ResourceInUseException{
+		Message: aws.String(fmt.Sprintf("Cannot create preexisting table: %s", name)),
+	}
+}
+
+func conditionFailed(item map[string]types.AttributeValue) error {
+	return &types.ConditionalCheckFailedException{
+		Message: aws.String("The conditional request failed"),
+		Item:    item,
+	}
+}
+
+// checkUnused rejects expression attribute names and values that are not referenced by any expression,
+// as DynamoDB does.
+func checkUnused(names map[string]string, values map[string]types.AttributeValue, exprs ...*string) error {
+	used := map[string]bool{}
+	for _, e := range exprs {
+		if e == nil {
+			continue
+		}
+		toks, err := tokenize(*e)
+		if err != nil {
+			return validationErrorf("Invalid expression: %v", err)
+		}
+		for _, t := range toks {
+			if t.kind == tokName || t.kind == tokValue {
+				used[t.text] = true
+			}
+		}
+	}
+	for k := range names {
+		if !used[k] {
+			return validationErrorf("Value provided in ExpressionAttributeNames unused in expressions: keys: {%s}", k)
+		}
+	}
+	for k := range values {
+		if !used[k] {
+			return validationErrorf("Value provided in ExpressionAttributeValues unused in expressions: keys: {%s}", k)
+		}
+	}
+	return nil
+}
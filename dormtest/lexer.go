@@ -0,0 +1,229 @@
+package dormtest
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+type tokenKind int
+
+const (
+	tokEOF tokenKind = iota
+	tokIdent
+	tokName
+	tokValue
+	tokNumber
+	tokLParen
+	tokRParen
+	tokLBracket
+	tokRBracket
+	tokComma
+	tokDot
+	tokEq
+	tokNe
+	tokLt
+	tokLe
+	tokGt
+	tokGe
+	tokPlus
+	tokMinus
+)
+
+type token struct {
+	kind tokenKind
+	text string
+}
+
+// tokenize splits a DynamoDB expression into tokens.
+func tokenize(s string) ([]token, error) {
+	var toks []token
+	rs := []rune(s)
+	for i := 0; i < len(rs); {
+		r := rs[i]
+		switch {
+		case unicode.IsSpace(r):
+			i++
+		case r == '(':
+			toks = append(toks, token{tokLParen, "("})
+			i++
+		case r == ')':
+			toks = append(toks, token{tokRParen, ")"})
+			i++
+		case r == '[':
+			toks = append(toks, token{tokLBracket, "["})
+			i++
+		case r == ']':
+			toks = append(toks, token{tokRBracket, "]"})
+			i++
+		case r == ',':
+			toks = append(toks, token{tokComma, ","})
+			i++
+		case r == '.':
+			toks = append(toks, token{tokDot, "."})
+			i++
+		case r == '+':
+			toks = append(toks, token{tokPlus, "+"})
+			i++
+		case r == '-':
+			toks = append(toks, token{tokMinus, "-"})
+			i++
+		case r == '=':
+			toks = append(toks, token{tokEq, "="})
+			i++
+		case r == '<':
+			switch {
+			case i+1 < len(rs) && rs[i+1] == '>':
+				toks = append(toks, token{tokNe, "<>"})
+				i += 2
+			case i+1 < len(rs) && rs[i+1] == '=':
+				toks = append(toks, token{tokLe, "<="})
+				i += 2
+			default:
+				toks = append(toks, token{tokLt, "<"})
+				i++
+			}
+		case r == '>':
+			if i+1 < len(rs) && rs[i+1] == '=' {
+				toks = append(toks, token{tokGe, ">="})
+				i += 2
+			} else {
+				toks = append(toks, token{tokGt, ">"})
+				i++
+			}
+		case r == '#' || r == ':':
+			j := i + 1
+			for j < len(rs) && isIdentRune(rs[j]) {
+				j++
+			}
+			if j == i+1 {
+				return nil, fmt.Errorf("invalid token at %d in %q", i, s)
+			}
+			kind := tokName
+			if r == ':' {
+				kind = tokValue
+			}
+			toks = append(toks, token{kind, string(rs[i:j])})
+			i = j
+		case unicode.IsDigit(r):
+			j := i
+			for j < len(rs) && unicode.IsDigit(rs[j]) {
+				j++
+			}
+			toks = append(toks, token{tokNumber, string(rs[i:j])})
+			i = j
+		case isIdentRune(r):
+			j := i
+			for j < len(rs) && isIdentRune(rs[j]) {
+				j++
+			}
+			toks = append(toks, token{tokIdent, string(rs[i:j])})
+			i = j
+		default:
+			return nil, fmt.Errorf("unexpected character %q in %q", r, s)
+		}
+	}
+	toks = append(toks, token{kind: tokEOF})
+	return toks, nil
+}
+
+func isIdentRune(r rune) bool {
+	return r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r)
+}
+
+// parser is a recursive descent parser for DynamoDB expressions.
+type parser struct {
+	toks   []token
+	pos    int
+	names  map[string]string
+	values map[string]types.AttributeValue
+}
+
+func newParser(expr string, names map[string]string, values map[string]types.AttributeValue) (*parser, error) {
+	toks, err := tokenize(expr)
+	if err != nil {
+		return nil, err
+	}
+	return &parser{toks: toks, names: names, values: values}, nil
+}
+
+func (p *parser) peek() token {
+	return p.toks[p.pos]
+}
+
+func (p *parser) next() token {
+	t := p.toks[p.pos]
+	if t.kind != tokEOF {
+		p.pos++
+	}
+	return t
+}
+
+func (p *parser) expect(kind tokenKind, what string) (token, error) {
+	t := p.next()
+	if t.kind != kind {
+		return t, fmt.Errorf("expected %s but got %q", what, t.text)
+	}
+	return t, nil
+}
+
+// isKeyword reports whether the next token is the given case-insensitive keyword.
+func (p *parser) isKeyword(kw string) bool {
+	t := p.peek()
+	return t.kind == tokIdent && strings.EqualFold(t.text, kw)
+}
+
+// parsePath parses a document path such as #a.#b[1].c.
+func (p *parser) parsePath() (path, error) {
+	var res path
+	first, err := p.parsePathName()
+	if err != nil {
+		return nil, err
+	}
+	res = append(res, pathElem{name: first})
+	for {
+		switch p.peek().kind {
+		case tokDot:
+			p.next()
+			n, err := p.parsePathName()
+			if err != nil {
+				return nil, err
+			}
+			res = append(res, pathElem{name: n})
+		case tokLBracket:
+			p.next()
+			t, err := p.expect(tokNumber, "list index")
+			if err != nil {
+				return nil, err
+			}
+			var idx int
+			if _, err := fmt.Sscanf(t.text, "%d", &idx); err != nil {
+				return nil, err
+			}
+			if _, err := p.expect(tokRBracket, "]"); err != nil {
+				return nil, err
+			}
+			res = append(res, pathElem{index: idx, isIndex: true})
+		default:
+			return res, nil
+		}
+	}
+}
+
+func (p *parser) parsePathName() (string, error) {
+	t := p.next()
+	switch t.kind {
+	case tokName:
+		n, ok := p.names[t.text]
+		if !ok {
+			return "", fmt.Errorf("expression attribute name %s is not defined", t.text)
+		}
+		return n, nil
+	case tokIdent:
+		return t.text, nil
+	default:
+		return "", fmt.Errorf("expected attribute name but got %q", t.text)
+	}
+}
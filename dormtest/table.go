@@ -0,0 +1,382 @@
+package dormtest
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+type keySchema struct {
+	hash string
+	rng  string
+}
+
+func (k keySchema) names() []string {
+	if k.rng == "" {
+		return []string{k.hash}
+	}
+	return []string{k.hash, k.rng}
+}
+
+type index struct {
+	keys       keySchema
+	projection types.Projection
+}
+
+// table holds the schema and the items of a single table.
+type table struct {
+	desc    types.TableDescription
+	keys    keySchema
+	attrs   map[string]types.ScalarAttributeType
+	indexes map[string]*index
+	items   map[string]map[string]types.AttributeValue
+	ttl     types.TimeToLiveDescription
+}
+
+func parseKeySchema(elems []types.KeySchemaElement) (keySchema, error) {
+	var k keySchema
+	for _, e := range elems {
+		switch e.KeyType {
+		case types.KeyTypeHash:
+			k.hash = aws.ToString(e.AttributeName)
+		case types.KeyTypeRange:
+			k.rng = aws.ToString(e.AttributeName)
+		}
+	}
+	if k.hash == "" {
+		return k, validationErrorf("No Hash Key specified in schema. All Dynamo DB tables must have exactly one hash key")
+	}
+	return k, nil
+}
+
+func newTable(in *dynamodb.CreateTableInput) (*table, error) {
+	name := aws.ToString(in.TableName)
+	keys, err := parseKeySchema(in.KeySchema)
+	if err != nil {
+		return nil, err
+	}
+	t := &table{
+		keys:    keys,
+		attrs:   map[string]types.ScalarAttributeType{},
+		indexes: map[string]*index{},
+		items:   map[string]map[string]types.AttributeValue{},
+		ttl:     types.TimeToLiveDescription{TimeToLiveStatus: types.TimeToLiveStatusDisabled},
+	}
+	for _, d := range in.AttributeDefinitions {
+		t.attrs[aws.ToString(d.AttributeName)] = d.AttributeType
+	}
+	for _, n := range keys.names() {
+		if _, ok := t.attrs[n]; !ok {
+			return nil, validationErrorf("One or more parameter values were invalid: Some index key attributes are not defined in AttributeDefinitions. Keys: [%s]", n)
+		}
+	}
+
+	now := time.Now()
+	t.desc = types.TableDescription{
+		TableName:            aws.String(name),
+		TableArn:             aws.String(fmt.Sprintf("arn:aws:dynamodb:ddblocal:000000000000:table/%s", name)),
+		TableStatus:          types.TableStatusActive,
+		CreationDateTime:     aws.Time(now),
+		KeySchema:            in.KeySchema,
+		AttributeDefinitions: in.AttributeDefinitions,
+		StreamSpecification:  in.StreamSpecification,
+	}
+	billing := in.BillingMode
+	if billing == "" {
+		billing = types.BillingModeProvisioned
+	}
+	t.desc.BillingModeSummary = &types.BillingModeSummary{BillingMode: billing}
+	t.desc.ProvisionedThroughput = throughputDescription(in.ProvisionedThroughput)
+	if in.StreamSpecification != nil && aws.ToBool(in.StreamSpecification.StreamEnabled) {
+		t.desc.LatestStreamArn = aws.String(aws.ToString(t.desc.TableArn) + "/stream/" + now.Format(time.RFC3339))
+	}
+
+	for _, g := range in.GlobalSecondaryIndexes {
+		gd, err := t.addGlobalIndex(g.IndexName, g.KeySchema, g.Projection, g.ProvisionedThroughput)
+		if err != nil {
+			return nil, err
+		}
+		t.desc.GlobalSecondaryIndexes = append(t.desc.GlobalSecondaryIndexes, gd)
+	}
+	for _, l := range in.LocalSecondaryIndexes {
+		ks, err := parseKeySchema(l.KeySchema)
+		if err != nil {
+			return nil, err
+		}
+		if ks.hash != keys.hash {
+			return nil, validationErrorf("Table KeySchema does not have a range key, which is required when specifying a LocalSecondaryIndex")
+		}
+		if err := t.addIndex(aws.ToString(l.IndexName), ks, l.Projection); err != nil {
+			return nil, err
+		}
+		t.desc.LocalSecondaryIndexes = append(t.desc.LocalSecondaryIndexes, types.LocalSecondaryIndexDescription{
+			IndexName:  l.IndexName,
+			KeySchema:  l.KeySchema,
+			Projection: l.Projection,
+			IndexArn:   aws.String(aws.ToString(t.desc.TableArn) + "/index/" + aws.ToString(l.IndexName)),
+		})
+	}
+	return t, nil
+}
+
+func (t *table) addGlobalIndex(name *string, elems []types.KeySchemaElement, proj *types.Projection, pt *types.ProvisionedThroughput) (types.GlobalSecondaryIndexDescription, error) {
+	ks, err := parseKeySchema(elems)
+	if err != nil {
+		return types.GlobalSecondaryIndexDescription{}, err
+	}
+	if err := t.addIndex(aws.ToString(name), ks, proj); err != nil {
+		return types.GlobalSecondaryIndexDescription{}, err
+	}
+	return types.GlobalSecondaryIndexDescription{
+		IndexName:             name,
+		KeySchema:             elems,
+		Projection:            proj,
+		IndexStatus:           types.IndexStatusActive,
+		ProvisionedThroughput: throughputDescription(pt),
+		IndexArn:              aws.String(aws.ToString(t.desc.TableArn) + "/index/" + aws.ToString(name)),
+	}, nil
+}
+
+func (t *table) addIndex(name string, ks keySchema, proj *types.Projection) error {
+	if _, ok := t.indexes[name]; ok {
+		return validationErrorf("One or more parameter values were invalid: Duplicate index name: %s", name)
+	}
+	for _, n := range ks.names() {
+		if _, ok := t.attrs[n]; !ok {
+			return validationErrorf("One or more parameter values were invalid: Some index key attributes are not defined in AttributeDefinitions. Keys: [%s]", n)
+		}
+	}
+	idx := &index{keys: ks}
+	if proj != nil {
+		idx.projection = *proj
+	}
+	if idx.projection.ProjectionType == "" {
+		idx.projection.ProjectionType = types.ProjectionTypeAll
+	}
+	t.indexes[name] = idx
+	return nil
+}
+
+func throughputDescription(pt *types.ProvisionedThroughput) *types.ProvisionedThroughputDescription {
+	d := &types.ProvisionedThroughputDescription{
+		ReadCapacityUnits:  aws.Int64(0),
+		WriteCapacityUnits: aws.Int64(0),
+	}
+	if pt != nil {
+		d.ReadCapacityUnits = pt.ReadCapacityUnits
+		d.WriteCapacityUnits = pt.WriteCapacityUnits
+	}
+	return d
+}
+
+// description returns a snapshot of the table description.
+func (t *table) description() *types.TableDescription {
+	d := t.desc
+	d.ItemCount = aws.Int64(int64(len(t.items)))
+	var n int64
+	for _, item := range t.items {
+		n += int64(itemSize(item))
+	}
+	d.TableSizeBytes = aws.Int64(n)
+	return &d
+}
+
+// CreateTable creates a new table.
+func (c *Client) CreateTable(ctx context.Context, params *dynamodb.CreateTableInput, optFns ...func(*dynamodb.Options)) (*dynamodb.CreateTableOutput, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	name := aws.ToString(params.TableName)
+	if _, ok := c.tables[name]; ok {
+		return nil, resourceInUse(name)
+	}
+	t, err := newTable(params)
+	if err != nil {
+		return nil, err
+	}
+	c.tables[name] = t
+	return &dynamodb.CreateTableOutput{TableDescription: t.description()}, nil
+}
+
+// DeleteTable deletes a table and all of its items.
+func (c *Client) DeleteTable(ctx context.Context, params *dynamodb.DeleteTableInput, optFns ...func(*dynamodb.Options)) (*dynamodb.DeleteTableOutput, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	t, err := c.table(params.TableName)
+	if err != nil {
+		return nil, err
+	}
+	delete(c.tables, aws.ToString(params.TableName))
+	d := t.description()
+	d.TableStatus = types.TableStatusDeleting
+	return &dynamodb.DeleteTableOutput{TableDescription: d}, nil
+}
+
+// DescribeTable returns the description of a table.
+func (c *Client) DescribeTable(ctx context.Context, params *dynamodb.DescribeTableInput, optFns ...func(*dynamodb.Options)) (*dynamodb.DescribeTableOutput, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	t, err := c.table(params.TableName)
+	if err != nil {
+		return nil, err
+	}
+	return &dynamodb.DescribeTableOutput{Table: t.description()}, nil
+}
+
+// UpdateTable changes the billing mode, the throughput, the stream and the GSIs of a table.
+// The changes take effect immediately, so the table and its indexes stay ACTIVE.
+func (c *Client) UpdateTable(ctx context.Context, params *dynamodb.UpdateTableInput, optFns ...func(*dynamodb.Options)) (*dynamodb.UpdateTableOutput, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	t, err := c.table(params.TableName)
+	if err != nil {
+		return nil, err
+	}
+	changes := 0
+	for _, u := range params.GlobalSecondaryIndexUpdates {
+		if u.Create != nil || u.Delete != nil {
+			changes++
+		}
+	}
+	if changes > 1 {
+		return nil, validationErrorf("Subscriber limit exceeded: Only 1 online index can be created or deleted simultaneously per table")
+	}
+
+	for _, d := range params.AttributeDefinitions {
+		name := aws.ToString(d.AttributeName)
+		if typ, ok := t.attrs[name]; ok {
+			if typ != d.AttributeType {
+				return nil, validationErrorf("One or more parameter values were invalid: Attribute %s is already defined as %s", name, typ)
+			}
+			continue
+		}
+		t.attrs[name] = d.AttributeType
+		t.desc.AttributeDefinitions = append(append([]types.AttributeDefinition{}, t.desc.AttributeDefinitions...), d)
+	}
+
+	for _, u := range params.GlobalSecondaryIndexUpdates {
+		switch {
+		case u.Create != nil:
+			gd, err := t.addGlobalIndex(u.Create.IndexName, u.Create.KeySchema, u.Create.Projection, u.Create.ProvisionedThroughput)
+			if err != nil {
+				return nil, err
+			}
+			t.desc.GlobalSecondaryIndexes = append(append([]types.GlobalSecondaryIndexDescription{}, t.desc.GlobalSecondaryIndexes...), gd)
+		case u.Delete != nil:
+			i := t.globalIndex(u.Delete.IndexName)
+			if i < 0 {
+				return nil, resourceNotFound(aws.ToString(u.Delete.IndexName))
+			}
+			delete(t.indexes, aws.ToString(u.Delete.IndexName))
+			gsis := append([]types.GlobalSecondaryIndexDescription{}, t.desc.GlobalSecondaryIndexes[:i]...)
+			t.desc.GlobalSecondaryIndexes = append(gsis, t.desc.GlobalSecondaryIndexes[i+1:]...)
+		case u.Update != nil:
+			i := t.globalIndex(u.Update.IndexName)
+			if i < 0 {
+				return nil, resourceNotFound(aws.ToString(u.Update.IndexName))
+			}
+			gsis := append([]types.GlobalSecondaryIndexDescription{}, t.desc.GlobalSecondaryIndexes...)
+			gsis[i].ProvisionedThroughput = throughputDescription(u.Update.ProvisionedThroughput)
+			t.desc.GlobalSecondaryIndexes = gsis
+		}
+	}
+
+	if params.BillingMode != "" {
+		t.desc.BillingModeSummary = &types.BillingModeSummary{BillingMode: params.BillingMode}
+	}
+	if params.ProvisionedThroughput != nil {
+		t.desc.ProvisionedThroughput = throughputDescription(params.ProvisionedThroughput)
+	}
+	if spec := params.StreamSpecification; spec != nil {
+		t.desc.StreamSpecification = spec
+		t.desc.LatestStreamArn = nil
+		if aws.ToBool(spec.StreamEnabled) {
+			t.desc.LatestStreamArn = aws.String(aws.ToString(t.desc.TableArn) + "/stream/" + time.Now().Format(time.RFC3339))
+		}
+	}
+	return &dynamodb.UpdateTableOutput{TableDescription: t.description()}, nil
+}
+
+// globalIndex returns the position of the GSI in the description, or -1 if there is none.
+func (t *table) globalIndex(name *string) int {
+	for i, g := range t.desc.GlobalSecondaryIndexes {
+		if aws.ToString(g.IndexName) == aws.ToString(name) {
+			return i
+		}
+	}
+	return -1
+}
+
+// DescribeTimeToLive returns the TTL settings of a table.
+func (c *Client) DescribeTimeToLive(ctx context.Context, params *dynamodb.DescribeTimeToLiveInput, optFns ...func(*dynamodb.Options)) (*dynamodb.DescribeTimeToLiveOutput, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	t, err := c.table(params.TableName)
+	if err != nil {
+		return nil, err
+	}
+	d := t.ttl
+	return &dynamodb.DescribeTimeToLiveOutput{TimeToLiveDescription: &d}, nil
+}
+
+// UpdateTimeToLive enables or disables TTL on a table. The change takes effect immediately.
+// Expired items are not deleted.
+func (c *Client) UpdateTimeToLive(ctx context.Context, params *dynamodb.UpdateTimeToLiveInput, optFns ...func(*dynamodb.Options)) (*dynamodb.UpdateTimeToLiveOutput, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	t, err := c.table(params.TableName)
+	if err != nil {
+		return nil, err
+	}
+	spec := params.TimeToLiveSpecification
+	if spec == nil || aws.ToString(spec.AttributeName) == "" {
+		return nil, validationErrorf("TimeToLiveSpecification and its AttributeName are required")
+	}
+	enabled := t.ttl.TimeToLiveStatus == types.TimeToLiveStatusEnabled
+	switch {
+	case aws.ToBool(spec.Enabled) && enabled:
+		return nil, validationErrorf("TimeToLive is already enabled")
+	case !aws.ToBool(spec.Enabled) && !enabled:
+		return nil, validationErrorf("TimeToLive is already disabled")
+	}
+
+	if aws.ToBool(spec.Enabled) {
+		t.ttl = types.TimeToLiveDescription{AttributeName: spec.AttributeName, TimeToLiveStatus: types.TimeToLiveStatusEnabled}
+	} else {
+		t.ttl = types.TimeToLiveDescription{TimeToLiveStatus: types.TimeToLiveStatusDisabled}
+	}
+	out := *spec
+	return &dynamodb.UpdateTimeToLiveOutput{TimeToLiveSpecification: &out}, nil
+}
+
+// ListTables lists the names of the tables in lexical order.
+func (c *Client) ListTables(ctx context.Context, params *dynamodb.ListTablesInput, optFns ...func(*dynamodb.Options)) (*dynamodb.ListTablesOutput, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	names := make([]string, 0, len(c.tables))
+	for n := range c.tables {
+		if params.ExclusiveStartTableName == nil || n > *params.ExclusiveStartTableName {
+			names = append(names, n)
+		}
+	}
+	sort.Strings(names)
+
+	out := &dynamodb.ListTablesOutput{}
+	if params.Limit != nil && int(*params.Limit) < len(names) {
+		names = names[:*params.Limit]
+		out.LastEvaluatedTableName = aws.String(names[len(names)-1])
+	}
+	out.TableNames = names
+	return out, nil
+}
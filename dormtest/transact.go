@@ -0,0 +1,202 @@
+package dormtest
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// transactWrite is a single action of a TransactWriteItems request, resolved against its table.
+type transactWrite struct {
+	t *table
+	k string
+	// next is the item after the action, or nil if the action deletes it.
+	next map[string]types.AttributeValue
+	// write is false for a ConditionCheck.
+	write bool
+}
+
+func (c *Client) prepareTransactWrite(item types.TransactWriteItem) (*transactWrite, error) {
+	var (
+		tableName, cond, update *string
+		names                   map[string]string
+		values                  map[string]types.AttributeValue
+		key                     map[string]types.AttributeValue
+		put                     map[string]types.AttributeValue
+		rv                      types.ReturnValuesOnConditionCheckFailure
+		n                       int
+	)
+	if p := item.Put; p != nil {
+		n++
+		tableName, cond, names, values, put, rv = p.TableName, p.ConditionExpression, p.ExpressionAttributeNames, p.ExpressionAttributeValues, p.Item, p.ReturnValuesOnConditionCheckFailure
+	}
+	if u := item.Update; u != nil {
+		n++
+		tableName, cond, names, values, key, rv = u.TableName, u.ConditionExpression, u.ExpressionAttributeNames, u.ExpressionAttributeValues, u.Key, u.ReturnValuesOnConditionCheckFailure
+		update = u.UpdateExpression
+		if update == nil {
+			return nil, validationErrorf("Update must have an UpdateExpression")
+		}
+	}
+	if d := item.Delete; d != nil {
+		n++
+		tableName, cond, names, values, key, rv = d.TableName, d.ConditionExpression, d.ExpressionAttributeNames, d.ExpressionAttributeValues, d.Key, d.ReturnValuesOnConditionCheckFailure
+	}
+	if cc := item.ConditionCheck; cc != nil {
+		n++
+		tableName, cond, names, values, key, rv = cc.TableName, cc.ConditionExpression, cc.ExpressionAttributeNames, cc.ExpressionAttributeValues, cc.Key, cc.ReturnValuesOnConditionCheckFailure
+		if cond == nil {
+			return nil, validationErrorf("ConditionCheck must have a ConditionExpression")
+		}
+	}
+	if n != 1 {
+		return nil, validationErrorf("TransactItems can only contain one of Check, Put, Update or Delete")
+	}
+
+	t, err := c.table(tableName)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkUnused(names, values, cond, update); err != nil {
+		return nil, err
+	}
+
+	w := &transactWrite{t: t, write: item.ConditionCheck == nil}
+	if put != nil {
+		w.k, err = t.itemKey(put)
+	} else {
+		w.k, err = t.keyOf(key)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	old := t.items[w.k]
+	if err := checkCondition(cond, names, values, old, rv); err != nil {
+		return w, err
+	}
+	switch {
+	case put != nil:
+		w.next = copyItem(put)
+	case update != nil:
+		w.next, _, err = t.update(old, key, update, names, values)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return w, nil
+}
+
+// TransactWriteItems applies all of the actions atomically. If any condition fails,
+// none of them are applied and a TransactionCanceledException with a reason for each action is returned.
+func (c *Client) TransactWriteItems(ctx context.Context, params *dynamodb.TransactWriteItemsInput, optFns ...func(*dynamodb.Options)) (*dynamodb.TransactWriteItemsOutput, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if len(params.TransactItems) == 0 || len(params.TransactItems) > maxTransactItemSize {
+		return nil, validationErrorf("Member must have length less than or equal to %d and greater than or equal to 1", maxTransactItemSize)
+	}
+	if params.ClientRequestToken != nil && c.tokens[*params.ClientRequestToken] {
+		return &dynamodb.TransactWriteItemsOutput{}, nil
+	}
+
+	writes := make([]*transactWrite, len(params.TransactItems))
+	reasons := make([]types.CancellationReason, len(params.TransactItems))
+	seen := map[string]bool{}
+	canceled := false
+	for i, item := range params.TransactItems {
+		w, err := c.prepareTransactWrite(item)
+		var ccf *types.ConditionalCheckFailedException
+		switch {
+		case errors.As(err, &ccf):
+			canceled = true
+			reasons[i] = types.CancellationReason{
+				Code:    aws.String("ConditionalCheckFailed"),
+				Message: ccf.Message,
+				Item:    ccf.Item,
+			}
+		case err != nil:
+			return nil, err
+		default:
+			reasons[i] = types.CancellationReason{Code: aws.String("None")}
+		}
+		id := *w.t.desc.TableName + "\x00" + w.k
+		if seen[id] {
+			return nil, validationErrorf("Transaction request cannot include multiple operations on one item")
+		}
+		seen[id] = true
+		writes[i] = w
+	}
+
+	if canceled {
+		codes := make([]string, len(reasons))
+		for i, r := range reasons {
+			codes[i] = *r.Code
+		}
+		return nil, &types.TransactionCanceledException{
+			Message:             aws.String("Transaction cancelled, please refer cancellation reasons for specific reasons [" + strings.Join(codes, ", ") + "]"),
+			CancellationReasons: reasons,
+		}
+	}
+
+	for _, w := range writes {
+		switch {
+		case !w.write:
+		case w.next == nil:
+			delete(w.t.items, w.k)
+		default:
+			w.t.items[w.k] = w.next
+		}
+	}
+	if params.ClientRequestToken != nil {
+		c.tokens[*params.ClientRequestToken] = true
+	}
+	return &dynamodb.TransactWriteItemsOutput{}, nil
+}
+
+// TransactGetItems reads all of the items as a single snapshot.
+func (c *Client) TransactGetItems(ctx context.Context, params *dynamodb.TransactGetItemsInput, optFns ...func(*dynamodb.Options)) (*dynamodb.TransactGetItemsOutput, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if len(params.TransactItems) == 0 || len(params.TransactItems) > maxTransactItemSize {
+		return nil, validationErrorf("Member must have length less than or equal to %d and greater than or equal to 1", maxTransactItemSize)
+	}
+
+	responses := make([]types.ItemResponse, len(params.TransactItems))
+	seen := map[string]bool{}
+	for i, item := range params.TransactItems {
+		g := item.Get
+		if g == nil {
+			return nil, validationErrorf("TransactItems must contain Get")
+		}
+		t, err := c.table(g.TableName)
+		if err != nil {
+			return nil, err
+		}
+		if err := checkUnused(g.ExpressionAttributeNames, nil, g.ProjectionExpression); err != nil {
+			return nil, err
+		}
+		k, err := t.keyOf(g.Key)
+		if err != nil {
+			return nil, err
+		}
+		id := *t.desc.TableName + "\x00" + k
+		if seen[id] {
+			return nil, validationErrorf("Transaction request cannot include multiple operations on one item")
+		}
+		seen[id] = true
+		proj, err := projectionOf(g.ProjectionExpression, g.ExpressionAttributeNames)
+		if err != nil {
+			return nil, err
+		}
+		if v, ok := t.items[k]; ok {
+			responses[i].Item = project(v, proj)
+		}
+	}
+	return &dynamodb.TransactGetItemsOutput{Responses: responses}, nil
+}
@@ -0,0 +1,383 @@
+package dormtest
+
+import (
+	"fmt"
+	"math/big"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// setValue is the right hand side of a SET action.
+type setValue interface {
+	value(item map[string]types.AttributeValue) (types.AttributeValue, error)
+}
+
+type operandValue struct {
+	o operand
+	p path
+}
+
+func (v operandValue) value(item map[string]types.AttributeValue) (types.AttributeValue, error) {
+	res, ok := v.o.value(item)
+	if !ok {
+		return nil, validationErrorf("The provided expression refers to an attribute that does not exist in the item: %s", v.p)
+	}
+	return res, nil
+}
+
+type arithmeticValue struct {
+	op   tokenKind
+	l, r setValue
+}
+
+func (v arithmeticValue) value(item map[string]types.AttributeValue) (types.AttributeValue, error) {
+	l, err := v.l.value(item)
+	if err != nil {
+		return nil, err
+	}
+	r, err := v.r.value(item)
+	if err != nil {
+		return nil, err
+	}
+	ln, lok := l.(*types.AttributeValueMemberN)
+	rn, rok := r.(*types.AttributeValueMemberN)
+	if !lok || !rok {
+		return nil, validationErrorf("An operand in the update expression has an incorrect data type")
+	}
+	x, err := parseNumber(ln.Value)
+	if err != nil {
+		return nil, err
+	}
+	y, err := parseNumber(rn.Value)
+	if err != nil {
+		return nil, err
+	}
+	res := new(big.Float).SetPrec(200)
+	if v.op == tokPlus {
+		res.Add(x, y)
+	} else {
+		res.Sub(x, y)
+	}
+	return &types.AttributeValueMemberN{Value: formatNumber(res)}, nil
+}
+
+type ifNotExistsValue struct {
+	p   path
+	def setValue
+}
+
+func (v ifNotExistsValue) value(item map[string]types.AttributeValue) (types.AttributeValue, error) {
+	if cur, ok := v.p.get(item); ok {
+		return cur, nil
+	}
+	return v.def.value(item)
+}
+
+type listAppendValue struct{ l, r setValue }
+
+func (v listAppendValue) value(item map[string]types.AttributeValue) (types.AttributeValue, error) {
+	l, err := v.l.value(item)
+	if err != nil {
+		return nil, err
+	}
+	r, err := v.r.value(item)
+	if err != nil {
+		return nil, err
+	}
+	ll, lok := l.(*types.AttributeValueMemberL)
+	rl, rok := r.(*types.AttributeValueMemberL)
+	if !lok || !rok {
+		return nil, validationErrorf("An operand in the update expression has an incorrect data type")
+	}
+	res := append(append([]types.AttributeValue{}, ll.Value...), rl.Value...)
+	return &types.AttributeValueMemberL{Value: res}, nil
+}
+
+type updateAction struct {
+	kind string
+	p    path
+	v    setValue
+}
+
+// parseUpdate parses an update expression into its actions.
+func parseUpdate(expr string, names map[string]string, values map[string]types.AttributeValue) ([]updateAction, error) {
+	p, err := newParser(expr, names, values)
+	if err != nil {
+		return nil, err
+	}
+	var res []updateAction
+	seen := map[string]bool{}
+	for p.peek().kind != tokEOF {
+		t := p.next()
+		kind := strings.ToUpper(t.text)
+		if t.kind != tokIdent || (kind != "SET" && kind != "REMOVE" && kind != "ADD" && kind != "DELETE") {
+			return nil, fmt.Errorf("unexpected token %q", t.text)
+		}
+		if seen[kind] {
+			return nil, fmt.Errorf("the %s section can only be used once in an update expression", kind)
+		}
+		seen[kind] = true
+		for {
+			pth, err := p.parsePath()
+			if err != nil {
+				return nil, err
+			}
+			a := updateAction{kind: kind, p: pth}
+			switch kind {
+			case "SET":
+				if _, err := p.expect(tokEq, "="); err != nil {
+					return nil, err
+				}
+				if a.v, err = p.parseSetValue(); err != nil {
+					return nil, err
+				}
+			case "ADD", "DELETE":
+				o, err := p.parseOperand()
+				if err != nil {
+					return nil, err
+				}
+				a.v = operandValue{o: o}
+			}
+			res = append(res, a)
+			if p.peek().kind != tokComma {
+				break
+			}
+			p.next()
+		}
+	}
+	return res, nil
+}
+
+func (p *parser) parseSetValue() (setValue, error) {
+	l, err := p.parseSetOperand()
+	if err != nil {
+		return nil, err
+	}
+	if t := p.peek(); t.kind == tokPlus || t.kind == tokMinus {
+		p.next()
+		r, err := p.parseSetOperand()
+		if err != nil {
+			return nil, err
+		}
+		return arithmeticValue{op: t.kind, l: l, r: r}, nil
+	}
+	return l, nil
+}
+
+func (p *parser) parseSetOperand() (setValue, error) {
+	t := p.peek()
+	if t.kind == tokIdent && p.toks[p.pos+1].kind == tokLParen {
+		switch strings.ToLower(t.text) {
+		case "if_not_exists":
+			p.next()
+			p.next()
+			pth, err := p.parsePath()
+			if err != nil {
+				return nil, err
+			}
+			if _, err := p.expect(tokComma, ","); err != nil {
+				return nil, err
+			}
+			def, err := p.parseSetValue()
+			if err != nil {
+				return nil, err
+			}
+			if _, err := p.expect(tokRParen, ")"); err != nil {
+				return nil, err
+			}
+			return ifNotExistsValue{p: pth, def: def}, nil
+		case "list_append":
+			p.next()
+			p.next()
+			l, err := p.parseSetValue()
+			if err != nil {
+				return nil, err
+			}
+			if _, err := p.expect(tokComma, ","); err != nil {
+				return nil, err
+			}
+			r, err := p.parseSetValue()
+			if err != nil {
+				return nil, err
+			}
+			if _, err := p.expect(tokRParen, ")"); err != nil {
+				return nil, err
+			}
+			return listAppendValue{l: l, r: r}, nil
+		}
+	}
+	o, err := p.parseOperand()
+	if err != nil {
+		return nil, err
+	}
+	v := operandValue{o: o}
+	if po, ok := o.(pathOperand); ok {
+		v.p = po.p
+	}
+	return v, nil
+}
+
+// applyUpdate applies the actions to a copy of item. All operands are evaluated against the original item.
+func applyUpdate(item map[string]types.AttributeValue, actions []updateAction) (map[string]types.AttributeValue, error) {
+	vals := make([]types.AttributeValue, len(actions))
+	for i, a := range actions {
+		if a.v == nil {
+			continue
+		}
+		v, err := a.v.value(item)
+		if err != nil {
+			return nil, err
+		}
+		vals[i] = copyValue(v)
+	}
+
+	res := copyItem(item)
+	for i, a := range actions {
+		switch a.kind {
+		case "SET":
+			if err := a.p.set(res, vals[i]); err != nil {
+				return nil, err
+			}
+		case "REMOVE":
+			a.p.remove(res)
+		case "ADD":
+			cur, ok := a.p.get(res)
+			if !ok {
+				if err := a.p.set(res, vals[i]); err != nil {
+					return nil, err
+				}
+				continue
+			}
+			v, err := addValue(cur, vals[i])
+			if err != nil {
+				return nil, err
+			}
+			if err := a.p.set(res, v); err != nil {
+				return nil, err
+			}
+		case "DELETE":
+			cur, ok := a.p.get(res)
+			if !ok {
+				continue
+			}
+			v, err := deleteValue(cur, vals[i])
+			if err != nil {
+				return nil, err
+			}
+			if v == nil {
+				a.p.remove(res)
+			} else if err := a.p.set(res, v); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return res, nil
+}
+
+func addValue(cur, v types.AttributeValue) (types.AttributeValue, error) {
+	switch c := cur.(type) {
+	case *types.AttributeValueMemberN:
+		return arithmeticValue{op: tokPlus, l: constValue{c}, r: constValue{v}}.value(nil)
+	case *types.AttributeValueMemberSS:
+		w, ok := v.(*types.AttributeValueMemberSS)
+		if !ok {
+			break
+		}
+		return &types.AttributeValueMemberSS{Value: union(c.Value, w.Value, func(s string) string { return s })}, nil
+	case *types.AttributeValueMemberNS:
+		w, ok := v.(*types.AttributeValueMemberNS)
+		if !ok {
+			break
+		}
+		return &types.AttributeValueMemberNS{Value: union(c.Value, w.Value, normalizeNumber)}, nil
+	case *types.AttributeValueMemberBS:
+		w, ok := v.(*types.AttributeValueMemberBS)
+		if !ok {
+			break
+		}
+		return &types.AttributeValueMemberBS{Value: union(c.Value, w.Value, func(b []byte) string { return string(b) })}, nil
+	}
+	return nil, validationErrorf("An operand in the update expression has an incorrect data type")
+}
+
+func deleteValue(cur, v types.AttributeValue) (types.AttributeValue, error) {
+	var res types.AttributeValue
+	var n int
+	switch c := cur.(type) {
+	case *types.AttributeValueMemberSS:
+		w, ok := v.(*types.AttributeValueMemberSS)
+		if !ok {
+			return nil, validationErrorf("An operand in the update expression has an incorrect data type")
+		}
+		l := difference(c.Value, w.Value, func(s string) string { return s })
+		res, n = &types.AttributeValueMemberSS{Value: l}, len(l)
+	case *types.AttributeValueMemberNS:
+		w, ok := v.(*types.AttributeValueMemberNS)
+		if !ok {
+			return nil, validationErrorf("An operand in the update expression has an incorrect data type")
+		}
+		l := difference(c.Value, w.Value, normalizeNumber)
+		res, n = &types.AttributeValueMemberNS{Value: l}, len(l)
+	case *types.AttributeValueMemberBS:
+		w, ok := v.(*types.AttributeValueMemberBS)
+		if !ok {
+			return nil, validationErrorf("An operand in the update expression has an incorrect data type")
+		}
+		l := difference(c.Value, w.Value, func(b []byte) string { return string(b) })
+		res, n = &types.AttributeValueMemberBS{Value: l}, len(l)
+	default:
+		return nil, validationErrorf("An operand in the update expression has an incorrect data type")
+	}
+	if n == 0 {
+		return nil, nil
+	}
+	return res, nil
+}
+
+type constValue struct{ v types.AttributeValue }
+
+func (v constValue) value(map[string]types.AttributeValue) (types.AttributeValue, error) {
+	return v.v, nil
+}
+
+func union[T any](a, b []T, key func(T) string) []T {
+	seen := map[string]bool{}
+	res := make([]T, 0, len(a)+len(b))
+	for _, l := range [][]T{a, b} {
+		for _, v := range l {
+			if !seen[key(v)] {
+				seen[key(v)] = true
+				res = append(res, v)
+			}
+		}
+	}
+	return res
+}
+
+func difference[T any](a, b []T, key func(T) string) []T {
+	drop := map[string]bool{}
+	for _, v := range b {
+		drop[key(v)] = true
+	}
+	res := make([]T, 0, len(a))
+	for _, v := range a {
+		if !drop[key(v)] {
+			res = append(res, v)
+		}
+	}
+	return res
+}
+
+// updatedAttributes returns the top level attribute names touched by the actions.
+func updatedAttributes(actions []updateAction) []string {
+	var res []string
+	seen := map[string]bool{}
+	for _, a := range actions {
+		if n := a.p[0].name; !seen[n] {
+			seen[n] = true
+			res = append(res, n)
+		}
+	}
+	return res
+}
@@ -0,0 +1,393 @@
+package dormtest
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"math/big"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+type pathElem struct {
+	name    string
+	index   int
+	isIndex bool
+}
+
+// path is a document path in an item.
+type path []pathElem
+
+func (p path) String() string {
+	var sb strings.Builder
+	for i, e := range p {
+		if e.isIndex {
+			fmt.Fprintf(&sb, "[%d]", e.index)
+			continue
+		}
+		if i > 0 {
+			sb.WriteByte('.')
+		}
+		sb.WriteString(e.name)
+	}
+	return sb.String()
+}
+
+// get resolves the path in the item.
+func (p path) get(item map[string]types.AttributeValue) (types.AttributeValue, bool) {
+	var cur types.AttributeValue = &types.AttributeValueMemberM{Value: item}
+	for _, e := range p {
+		switch v := cur.(type) {
+		case *types.AttributeValueMemberM:
+			if e.isIndex {
+				return nil, false
+			}
+			next, ok := v.Value[e.name]
+			if !ok {
+				return nil, false
+			}
+			cur = next
+		case *types.AttributeValueMemberL:
+			if !e.isIndex || e.index >= len(v.Value) {
+				return nil, false
+			}
+			cur = v.Value[e.index]
+		default:
+			return nil, false
+		}
+	}
+	return cur, true
+}
+
+// set assigns the value at the path. The parent of the path must exist.
+func (p path) set(item map[string]types.AttributeValue, val types.AttributeValue) error {
+	parent, ok := p[:len(p)-1].get(item)
+	if !ok {
+		return validationErrorf("The document path provided in the update expression is invalid for update")
+	}
+	last := p[len(p)-1]
+	switch v := parent.(type) {
+	case *types.AttributeValueMemberM:
+		if last.isIndex {
+			return validationErrorf("The document path provided in the update expression is invalid for update")
+		}
+		v.Value[last.name] = val
+	case *types.AttributeValueMemberL:
+		if !last.isIndex {
+			return validationErrorf("The document path provided in the update expression is invalid for update")
+		}
+		if last.index >= len(v.Value) {
+			v.Value = append(v.Value, val)
+		} else {
+			v.Value[last.index] = val
+		}
+	default:
+		return validationErrorf("The document path provided in the update expression is invalid for update")
+	}
+	return nil
+}
+
+// remove deletes the value at the path if it exists.
+func (p path) remove(item map[string]types.AttributeValue) {
+	parent, ok := p[:len(p)-1].get(item)
+	if !ok {
+		return
+	}
+	last := p[len(p)-1]
+	switch v := parent.(type) {
+	case *types.AttributeValueMemberM:
+		if !last.isIndex {
+			delete(v.Value, last.name)
+		}
+	case *types.AttributeValueMemberL:
+		if last.isIndex && last.index < len(v.Value) {
+			v.Value = append(v.Value[:last.index], v.Value[last.index+1:]...)
+		}
+	}
+}
+
+// copyItem returns a deep copy of the item.
+func copyItem(item map[string]types.AttributeValue) map[string]types.AttributeValue {
+	if item == nil {
+		return nil
+	}
+	res := make(map[string]types.AttributeValue, len(item))
+	for k, v := range item {
+		res[k] = copyValue(v)
+	}
+	return res
+}
+
+func copyValue(av types.AttributeValue) types.AttributeValue {
+	switch v := av.(type) {
+	case *types.AttributeValueMemberS:
+		return &types.AttributeValueMemberS{Value: v.Value}
+	case *types.AttributeValueMemberN:
+		return &types.AttributeValueMemberN{Value: v.Value}
+	case *types.AttributeValueMemberB:
+		return &types.AttributeValueMemberB{Value: append([]byte{}, v.Value...)}
+	case *types.AttributeValueMemberBOOL:
+		return &types.AttributeValueMemberBOOL{Value: v.Value}
+	case *types.AttributeValueMemberNULL:
+		return &types.AttributeValueMemberNULL{Value: v.Value}
+	case *types.AttributeValueMemberSS:
+		return &types.AttributeValueMemberSS{Value: append([]string{}, v.Value...)}
+	case *types.AttributeValueMemberNS:
+		return &types.AttributeValueMemberNS{Value: append([]string{}, v.Value...)}
+	case *types.AttributeValueMemberBS:
+		bs := make([][]byte, len(v.Value))
+		for i, b := range v.Value {
+			bs[i] = append([]byte{}, b...)
+		}
+		return &types.AttributeValueMemberBS{Value: bs}
+	case *types.AttributeValueMemberL:
+		l := make([]types.AttributeValue, len(v.Value))
+		for i, e := range v.Value {
+			l[i] = copyValue(e)
+		}
+		return &types.AttributeValueMemberL{Value: l}
+	case *types.AttributeValueMemberM:
+		return &types.AttributeValueMemberM{Value: copyItem(v.Value)}
+	default:
+		return av
+	}
+}
+
+// typeName returns the DynamoDB type descriptor of the value.
+func typeName(av types.AttributeValue) string {
+	switch av.(type) {
+	case *types.AttributeValueMemberS:
+		return "S"
+	case *types.AttributeValueMemberN:
+		return "N"
+	case *types.AttributeValueMemberB:
+		return "B"
+	case *types.AttributeValueMemberBOOL:
+		return "BOOL"
+	case *types.AttributeValueMemberNULL:
+		return "NULL"
+	case *types.AttributeValueMemberSS:
+		return "SS"
+	case *types.AttributeValueMemberNS:
+		return "NS"
+	case *types.AttributeValueMemberBS:
+		return "BS"
+	case *types.AttributeValueMemberL:
+		return "L"
+	case *types.AttributeValueMemberM:
+		return "M"
+	default:
+		return ""
+	}
+}
+
+func parseNumber(s string) (*big.Float, error) {
+	f, _, err := big.ParseFloat(strings.TrimSpace(s), 10, 200, big.ToNearestEven)
+	if err != nil {
+		return nil, validationErrorf("A value provided cannot be converted into a number: %s", s)
+	}
+	return f, nil
+}
+
+func formatNumber(f *big.Float) string {
+	return f.Text('g', -1)
+}
+
+// compare orders two scalar values of the same type. ok is false when they are not comparable.
+func compare(a, b types.AttributeValue) (int, bool) {
+	switch av := a.(type) {
+	case *types.AttributeValueMemberS:
+		bv, ok := b.(*types.AttributeValueMemberS)
+		if !ok {
+			return 0, false
+		}
+		return strings.Compare(av.Value, bv.Value), true
+	case *types.AttributeValueMemberN:
+		bv, ok := b.(*types.AttributeValueMemberN)
+		if !ok {
+			return 0, false
+		}
+		x, err := parseNumber(av.Value)
+		if err != nil {
+			return 0, false
+		}
+		y, err := parseNumber(bv.Value)
+		if err != nil {
+			return 0, false
+		}
+		return x.Cmp(y), true
+	case *types.AttributeValueMemberB:
+		bv, ok := b.(*types.AttributeValueMemberB)
+		if !ok {
+			return 0, false
+		}
+		return bytes.Compare(av.Value, bv.Value), true
+	default:
+		return 0, false
+	}
+}
+
+// equal reports whether two values are equal following DynamoDB semantics.
+func equal(a, b types.AttributeValue) bool {
+	if typeName(a) != typeName(b) {
+		return false
+	}
+	switch av := a.(type) {
+	case *types.AttributeValueMemberS, *types.AttributeValueMemberN, *types.AttributeValueMemberB:
+		c, ok := compare(a, b)
+		return ok && c == 0
+	case *types.AttributeValueMemberBOOL:
+		return av.Value == b.(*types.AttributeValueMemberBOOL).Value
+	case *types.AttributeValueMemberNULL:
+		return true
+	case *types.AttributeValueMemberSS:
+		return sameSet(av.Value, b.(*types.AttributeValueMemberSS).Value, func(s string) string { return s })
+	case *types.AttributeValueMemberNS:
+		return sameSet(av.Value, b.(*types.AttributeValueMemberNS).Value, normalizeNumber)
+	case *types.AttributeValueMemberBS:
+		return sameSet(av.Value, b.(*types.AttributeValueMemberBS).Value, func(b []byte) string { return string(b) })
+	case *types.AttributeValueMemberL:
+		bl := b.(*types.AttributeValueMemberL).Value
+		if len(av.Value) != len(bl) {
+			return false
+		}
+		for i := range av.Value {
+			if !equal(av.Value[i], bl[i]) {
+				return false
+			}
+		}
+		return true
+	case *types.AttributeValueMemberM:
+		bm := b.(*types.AttributeValueMemberM).Value
+		if len(av.Value) != len(bm) {
+			return false
+		}
+		for k, v := range av.Value {
+			w, ok := bm[k]
+			if !ok || !equal(v, w) {
+				return false
+			}
+		}
+		return true
+	default:
+		return false
+	}
+}
+
+func sameSet[T any](a, b []T, key func(T) string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	seen := make(map[string]bool, len(a))
+	for _, v := range a {
+		seen[key(v)] = true
+	}
+	for _, v := range b {
+		if !seen[key(v)] {
+			return false
+		}
+	}
+	return true
+}
+
+func normalizeNumber(s string) string {
+	f, err := parseNumber(s)
+	if err != nil {
+		return s
+	}
+	return formatNumber(f)
+}
+
+// size returns the result of the size function for the value.
+func size(av types.AttributeValue) (int, bool) {
+	switch v := av.(type) {
+	case *types.AttributeValueMemberS:
+		return utf8.RuneCountInString(v.Value), true
+	case *types.AttributeValueMemberB:
+		return len(v.Value), true
+	case *types.AttributeValueMemberSS:
+		return len(v.Value), true
+	case *types.AttributeValueMemberNS:
+		return len(v.Value), true
+	case *types.AttributeValueMemberBS:
+		return len(v.Value), true
+	case *types.AttributeValueMemberL:
+		return len(v.Value), true
+	case *types.AttributeValueMemberM:
+		return len(v.Value), true
+	default:
+		return 0, false
+	}
+}
+
+// itemSize approximates the stored size of an item in bytes.
+func itemSize(item map[string]types.AttributeValue) int {
+	n := 0
+	for k, v := range item {
+		n += len(k) + valueSize(v)
+	}
+	return n
+}
+
+func valueSize(av types.AttributeValue) int {
+	switch v := av.(type) {
+	case *types.AttributeValueMemberS:
+		return len(v.Value)
+	case *types.AttributeValueMemberN:
+		return len(v.Value)
+	case *types.AttributeValueMemberB:
+		return len(v.Value)
+	case *types.AttributeValueMemberSS:
+		n := 0
+		for _, s := range v.Value {
+			n += len(s)
+		}
+		return n
+	case *types.AttributeValueMemberNS:
+		n := 0
+		for _, s := range v.Value {
+			n += len(s)
+		}
+		return n
+	case *types.AttributeValueMemberBS:
+		n := 0
+		for _, b := range v.Value {
+			n += len(b)
+		}
+		return n
+	case *types.AttributeValueMemberL:
+		n := 3
+		for _, e := range v.Value {
+			n += 1 + valueSize(e)
+		}
+		return n
+	case *types.AttributeValueMemberM:
+		return 3 + itemSize(v.Value)
+	default:
+		return 1
+	}
+}
+
+// keyString encodes the values of the named attributes into a comparable string.
+func keyString(item map[string]types.AttributeValue, names ...string) string {
+	sorted := append([]string{}, names...)
+	sort.Strings(sorted)
+	var sb strings.Builder
+	for _, n := range sorted {
+		sb.WriteString(n)
+		sb.WriteByte('=')
+		switch v := item[n].(type) {
+		case *types.AttributeValueMemberS:
+			sb.WriteString("S:" + v.Value)
+		case *types.AttributeValueMemberN:
+			sb.WriteString("N:" + normalizeNumber(v.Value))
+		case *types.AttributeValueMemberB:
+			sb.WriteString("B:" + base64.StdEncoding.EncodeToString(v.Value))
+		}
+		sb.WriteByte(0)
+	}
+	return sb.String()
+}
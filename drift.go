@@ -0,0 +1,264 @@
+package dorm
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// SchemaDriftKind is the kind of a SchemaDifference.
+type SchemaDriftKind string
+
+// Kinds of SchemaDifference.
+const (
+	// SchemaDriftKeySchema is a difference of the key attributes of the table or an index.
+	SchemaDriftKeySchema SchemaDriftKind = "KeySchema"
+	// SchemaDriftAttributeType is a difference of the type of a key attribute.
+	SchemaDriftAttributeType SchemaDriftKind = "AttributeType"
+	// SchemaDriftMissingIndex is an index declared on the ItemType that the table doesn't have.
+	SchemaDriftMissingIndex SchemaDriftKind = "MissingIndex"
+	// SchemaDriftExtraIndex is an index of the table that is not declared on the ItemType.
+	SchemaDriftExtraIndex SchemaDriftKind = "ExtraIndex"
+	// SchemaDriftProjection is a difference of the projection of an index.
+	SchemaDriftProjection SchemaDriftKind = "Projection"
+	// SchemaDriftTimeToLive is a difference of the TTL attribute of the table.
+	SchemaDriftTimeToLive SchemaDriftKind = "TimeToLive"
+)
+
+// SchemaDifference is a difference between the schema declared on an ItemType and the table.
+type SchemaDifference struct {
+	Kind SchemaDriftKind
+	// Index is the name of the index, or empty if the difference is about the table.
+	Index string
+	// Attribute is the name of the attribute of SchemaDriftAttributeType.
+	Attribute string
+	// Want is the declared value, and Got is the value of the table. They are empty if there is no such value.
+	Want string
+	Got  string
+}
+
+func (d SchemaDifference) String() string {
+	target := "table"
+	switch {
+	case d.Attribute != "":
+		target = "attribute " + d.Attribute
+	case d.Index != "":
+		target = "index " + d.Index
+	}
+	value := func(s string) string {
+		if s == "" {
+			return "none"
+		}
+		return s
+	}
+	return fmt.Sprintf("%s of %s: want %s, got %s", d.Kind, target, value(d.Want), value(d.Got))
+}
+
+// CheckTableOptions options for CheckTable function
+type CheckTableOptions struct {
+	// TimeToLive is the attribute name of the TTL of the table. TTL must be disabled if it is empty.
+	// It is the field tagged with `dorm:"ttl"` by default.
+	TimeToLive string
+}
+
+// CheckTableOptionFunc CheckTable option function
+type CheckTableOptionFunc func(*CheckTableOptions)
+
+// WithCheckTableTimeToLive sets the TimeToLive for CheckTableOptions.
+func WithCheckTableTimeToLive(attributeName string) CheckTableOptionFunc {
+	return func(opts *CheckTableOptions) {
+		opts.TimeToLive = attributeName
+	}
+}
+
+// CheckTable compares the table of V with the schema that CreateTable builds from the dorm tags of V,
+// and returns SchemaDriftError if they are different.
+//
+// It reports the differences of the key attributes and their types, the GSIs and LSIs the table lacks or has in addition,
+// the projections of the indexes and the TTL attribute. The billing mode and the throughput are not compared.
+// It is meant to run at startup or in CI, to find the tables that have diverged from the Go types.
+func CheckTable[V ItemType](ctx context.Context, db TableAPI, opts ...CheckTableOptionFunc) error {
+	o := CheckTableOptions{TimeToLive: timeToLiveOf[V]()}
+	for _, f := range opts {
+		f(&o)
+	}
+
+	want, err := createTableInput[V]()
+	if err != nil {
+		return err
+	}
+	got, err := DescribeTable[V](ctx, db)
+	if err != nil {
+		return err
+	}
+	ttl, err := db.DescribeTimeToLive(ctx, &dynamodb.DescribeTimeToLiveInput{TableName: want.TableName})
+	if err != nil {
+		return newOperationError("DescribeTimeToLive", want.TableName, nil, err)
+	}
+
+	diffs := diffKeySchema("", want.KeySchema, got.KeySchema)
+	diffs = append(diffs, diffAttributeTypes(want.AttributeDefinitions, got.AttributeDefinitions)...)
+	if w, g := o.TimeToLive, timeToLiveAttribute(ttl.TimeToLiveDescription); w != g {
+		disabled := func(s string) string {
+			if s == "" {
+				return string(types.TimeToLiveStatusDisabled)
+			}
+			return s
+		}
+		diffs = append(diffs, SchemaDifference{Kind: SchemaDriftTimeToLive, Want: disabled(w), Got: disabled(g)})
+	}
+	diffs = append(diffs, diffIndexes(wantGlobalIndexes(want), gotGlobalIndexes(got))...)
+	diffs = append(diffs, diffIndexes(wantLocalIndexes(want), gotLocalIndexes(got))...)
+
+	if len(diffs) > 0 {
+		return &SchemaDriftError{TableName: aws.ToString(want.TableName), Differences: diffs}
+	}
+	return nil
+}
+
+// diffAttributeTypes compares the types of the attributes defined in both.
+// A missing attribute is reported as a difference of the key schema or a missing index instead.
+func diffAttributeTypes(want, got []types.AttributeDefinition) []SchemaDifference {
+	gotTypes := map[string]types.ScalarAttributeType{}
+	for _, d := range got {
+		gotTypes[aws.ToString(d.AttributeName)] = d.AttributeType
+	}
+	var diffs []SchemaDifference
+	for _, d := range want {
+		name := aws.ToString(d.AttributeName)
+		if t, ok := gotTypes[name]; ok && t != d.AttributeType {
+			diffs = append(diffs, SchemaDifference{
+				Kind:      SchemaDriftAttributeType,
+				Attribute: name,
+				Want:      string(d.AttributeType),
+				Got:       string(t),
+			})
+		}
+	}
+	return diffs
+}
+
+// timeToLiveAttribute returns the TTL attribute of the table, or empty if TTL is disabled or being disabled.
+func timeToLiveAttribute(d *types.TimeToLiveDescription) string {
+	if d == nil {
+		return ""
+	}
+	switch d.TimeToLiveStatus {
+	case types.TimeToLiveStatusEnabled, types.TimeToLiveStatusEnabling:
+		return aws.ToString(d.AttributeName)
+	}
+	return ""
+}
+
+// indexSchema is the part of an index compared by CheckTable.
+type indexSchema struct {
+	keySchema  []types.KeySchemaElement
+	projection *types.Projection
+}
+
+func wantGlobalIndexes(input *dynamodb.CreateTableInput) map[string]indexSchema {
+	res := map[string]indexSchema{}
+	for _, g := range input.GlobalSecondaryIndexes {
+		res[aws.ToString(g.IndexName)] = indexSchema{keySchema: g.KeySchema, projection: g.Projection}
+	}
+	return res
+}
+
+func gotGlobalIndexes(desc *types.TableDescription) map[string]indexSchema {
+	res := map[string]indexSchema{}
+	for _, g := range desc.GlobalSecondaryIndexes {
+		res[aws.ToString(g.IndexName)] = indexSchema{keySchema: g.KeySchema, projection: g.Projection}
+	}
+	return res
+}
+
+func wantLocalIndexes(input *dynamodb.CreateTableInput) map[string]indexSchema {
+	res := map[string]indexSchema{}
+	for _, l := range input.LocalSecondaryIndexes {
+		res[aws.ToString(l.IndexName)] = indexSchema{keySchema: l.KeySchema, projection: l.Projection}
+	}
+	return res
+}
+
+func gotLocalIndexes(desc *types.TableDescription) map[string]indexSchema {
+	res := map[string]indexSchema{}
+	for _, l := range desc.LocalSecondaryIndexes {
+		res[aws.ToString(l.IndexName)] = indexSchema{keySchema: l.KeySchema, projection: l.Projection}
+	}
+	return res
+}
+
+// diffIndexes compares the indexes by their names, in the order of the names.
+func diffIndexes(want, got map[string]indexSchema) []SchemaDifference {
+	names := make([]string, 0, len(want)+len(got))
+	for name := range want {
+		names = append(names, name)
+	}
+	for name := range got {
+		if _, ok := want[name]; !ok {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	var diffs []SchemaDifference
+	for _, name := range names {
+		w, inWant := want[name]
+		g, inGot := got[name]
+		switch {
+		case !inGot:
+			diffs = append(diffs, SchemaDifference{Kind: SchemaDriftMissingIndex, Index: name, Want: formatKeySchema(w.keySchema)})
+		case !inWant:
+			diffs = append(diffs, SchemaDifference{Kind: SchemaDriftExtraIndex, Index: name, Got: formatKeySchema(g.keySchema)})
+		default:
+			diffs = append(diffs, diffKeySchema(name, w.keySchema, g.keySchema)...)
+			if wp, gp := formatProjection(w.projection), formatProjection(g.projection); wp != gp {
+				diffs = append(diffs, SchemaDifference{Kind: SchemaDriftProjection, Index: name, Want: wp, Got: gp})
+			}
+		}
+	}
+	return diffs
+}
+
+// diffKeySchema compares the key schemas of the table, or the index if index is not empty.
+func diffKeySchema(index string, want, got []types.KeySchemaElement) []SchemaDifference {
+	if w, g := formatKeySchema(want), formatKeySchema(got); w != g {
+		return []SchemaDifference{{Kind: SchemaDriftKeySchema, Index: index, Want: w, Got: g}}
+	}
+	return nil
+}
+
+// formatKeySchema formats the key schema as "name HASH, name RANGE".
+func formatKeySchema(elems []types.KeySchemaElement) string {
+	var hash, rng string
+	for _, e := range elems {
+		switch e.KeyType {
+		case types.KeyTypeHash:
+			hash = aws.ToString(e.AttributeName) + " " + string(e.KeyType)
+		case types.KeyTypeRange:
+			rng = aws.ToString(e.AttributeName) + " " + string(e.KeyType)
+		}
+	}
+	if rng == "" {
+		return hash
+	}
+	return hash + ", " + rng
+}
+
+// formatProjection formats the projection as its type, followed by the sorted non-key attributes for INCLUDE.
+func formatProjection(p *types.Projection) string {
+	if p == nil {
+		return ""
+	}
+	if len(p.NonKeyAttributes) == 0 {
+		return string(p.ProjectionType)
+	}
+	attrs := append([]string{}, p.NonKeyAttributes...)
+	sort.Strings(attrs)
+	return fmt.Sprintf("%s(%s)", p.ProjectionType, strings.Join(attrs, ", "))
+}
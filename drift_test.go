@@ -0,0 +1,136 @@
+package dorm
+
+import (
+	"context"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/cockroachdb/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+func testCheckTable(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+
+	db, err := ddbMain.conn()
+	assert.NoError(t, err)
+
+	_, err = CreateTable[driftTestItem](ctx, db)
+	assert.NoError(t, err)
+	assert.NoError(t, CheckTable[driftTestItem](ctx, db))
+
+	_, err = db.UpdateTimeToLive(ctx, &dynamodb.UpdateTimeToLiveInput{
+		TableName: aws.String(driftTestItem{}.TableName()),
+		TimeToLiveSpecification: &types.TimeToLiveSpecification{
+			AttributeName: aws.String("expires_at"),
+			Enabled:       aws.Bool(true),
+		},
+	})
+	assert.NoError(t, err)
+
+	err = CheckTable[driftTestItem](ctx, db)
+	assert.ErrorIs(t, err, ErrSchemaDrift)
+	var drift *SchemaDriftError
+	if assert.True(t, errors.As(err, &drift)) {
+		assert.Equal(t, []SchemaDifference{
+			{Kind: SchemaDriftTimeToLive, Want: "DISABLED", Got: "expires_at"},
+		}, drift.Differences)
+	}
+	assert.NoError(t, CheckTable[driftTestItem](ctx, db, WithCheckTableTimeToLive("expires_at")))
+
+	// The table was created from an older definition of the item
+	_, err = CreateTable[driftTestStaleTable](ctx, db)
+	assert.NoError(t, err)
+
+	err = CheckTable[driftTestStaleItem](ctx, db)
+	assert.ErrorIs(t, err, ErrSchemaDrift)
+	if assert.True(t, errors.As(err, &drift)) {
+		assert.Equal(t, "test-drift-stale", drift.TableName)
+		assert.Equal(t, []SchemaDifference{
+			{Kind: SchemaDriftAttributeType, Attribute: "created", Want: "N", Got: "S"},
+			{Kind: SchemaDriftMissingIndex, Index: "by-email", Want: "email HASH"},
+			{Kind: SchemaDriftExtraIndex, Index: "by-name", Got: "name HASH"},
+			{Kind: SchemaDriftProjection, Index: "by-score", Want: "ALL", Got: "KEYS_ONLY"},
+		}, drift.Differences)
+	}
+
+	_, err = DescribeTable[driftTestMissingTable](ctx, db)
+	assert.ErrorIs(t, err, ErrResourceNotFound)
+	assert.ErrorIs(t, CheckTable[driftTestMissingTable](ctx, db), ErrResourceNotFound)
+}
+
+func TestSchemaDifferenceString(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		diff SchemaDifference
+		want string
+	}{
+		"table": {
+			diff: SchemaDifference{Kind: SchemaDriftKeySchema, Want: "id HASH, sk RANGE", Got: "id HASH"},
+			want: "KeySchema of table: want id HASH, sk RANGE, got id HASH",
+		},
+		"attribute": {
+			diff: SchemaDifference{Kind: SchemaDriftAttributeType, Attribute: "sk", Want: "N", Got: "S"},
+			want: "AttributeType of attribute sk: want N, got S",
+		},
+		"missing index": {
+			diff: SchemaDifference{Kind: SchemaDriftMissingIndex, Index: "gsi", Want: "email HASH"},
+			want: "MissingIndex of index gsi: want email HASH, got none",
+		},
+	}
+
+	for name, tt := range tests {
+		tt := tt
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			assert.Equal(t, tt.want, tt.diff.String())
+		})
+	}
+}
+
+// driftTestItem is the declared item of test-drift.
+type driftTestItem struct {
+	Item    `dynamodbav:"-"`
+	UserID  string `dynamodbav:"user_id" dorm:"hash"`
+	Created int64  `dynamodbav:"created" dorm:"range"`
+	Email   string `dynamodbav:"email" dorm:"gsi=by-email:hash"`
+	Score   int    `dynamodbav:"score" dorm:"lsi=by-score"`
+}
+
+func (i driftTestItem) TableName() string { return "test-drift" }
+
+// driftTestStaleItem is declared like driftTestItem, while its table is created from driftTestStaleTable.
+type driftTestStaleItem struct {
+	Item    `dynamodbav:"-"`
+	UserID  string `dynamodbav:"user_id" dorm:"hash"`
+	Created int64  `dynamodbav:"created" dorm:"range"`
+	Email   string `dynamodbav:"email" dorm:"gsi=by-email:hash"`
+	Score   int    `dynamodbav:"score" dorm:"lsi=by-score"`
+}
+
+func (i driftTestStaleItem) TableName() string { return "test-drift-stale" }
+
+type driftTestStaleTable struct {
+	Item    `dynamodbav:"-"`
+	UserID  string `dynamodbav:"user_id" dorm:"hash"`
+	Created string `dynamodbav:"created" dorm:"range"`
+	Name    string `dynamodbav:"name" dorm:"gsi=by-name:hash"`
+	Score   int    `dynamodbav:"score" dorm:"lsi=by-score"`
+}
+
+func (i driftTestStaleTable) TableName() string { return "test-drift-stale" }
+
+func (i driftTestStaleTable) ConfigureTable(input *dynamodb.CreateTableInput) {
+	input.LocalSecondaryIndexes[0].Projection = &types.Projection{ProjectionType: types.ProjectionTypeKeysOnly}
+}
+
+type driftTestMissingTable struct {
+	Item   `dynamodbav:"-"`
+	UserID string `dynamodbav:"user_id" dorm:"hash"`
+}
+
+func (i driftTestMissingTable) TableName() string { return "test-drift-missing" }
@@ -0,0 +1,315 @@
+package dorm
+
+import (
+	"context"
+	"reflect"
+
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/expression"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/cockroachdb/errors"
+)
+
+// entityTagOption declares the discriminator of an entity type, such as `dorm:"entity=user"`.
+const entityTagOption = "entity="
+
+// entityPrefix is the placeholder prefix of the expressions added for the discriminator.
+const entityPrefix = "d"
+
+// entityField is the string field of an ItemType tagged with `dorm:"entity=name"`.
+//
+// In single-table design, several ItemTypes share one table and are told apart by the attribute of this field.
+// Writes set the attribute to the name of the entity type, and reads only return the items of the entity type.
+type entityField struct {
+	*attributeField
+	// value is the name of the entity type stored in the attribute.
+	value string
+}
+
+func newEntityField(f *attributeField) (*entityField, error) {
+	value, _ := tagOptionValue(f.Tag.Get(dormStructTag), entityTagOption)
+	if value == "" {
+		return nil, errors.Newf("invalid entity option of field %s, want entity=name", f.Name)
+	}
+	if f.Type.Kind() != reflect.String {
+		return nil, errors.Newf("entity field %s must be a string", f.Name)
+	}
+	return &entityField{attributeField: f, value: value}, nil
+}
+
+// stamp sets the attribute of the marshaled item av to the name of the entity type.
+func (f *entityField) stamp(av map[string]types.AttributeValue) {
+	if f == nil {
+		return
+	}
+	av[f.name] = &types.AttributeValueMemberS{Value: f.value}
+}
+
+// matches reports whether the item av is of the entity type.
+// The items without the attribute match, since the projection may leave it out.
+func (f *entityField) matches(av map[string]types.AttributeValue) bool {
+	if f == nil {
+		return true
+	}
+	v, ok := av[f.name]
+	if !ok {
+		return true
+	}
+	s, ok := v.(*types.AttributeValueMemberS)
+	return ok && s.Value == f.value
+}
+
+// entityFiltered adds the filter on the discriminator of V to p. It does nothing if V has no entity field.
+func entityFiltered[V ItemType](p exprParts) (exprParts, error) {
+	s, err := schemaOf[V]()
+	if err != nil || s.entity == nil {
+		return p, err
+	}
+	filter := expression.Name(s.entity.name).Equal(expression.Value(s.entity.value))
+	return p.merge(entityPrefix, expression.NewBuilder().WithFilter(filter))
+}
+
+// condition is the condition that the stored item is of the entity type, or doesn't exist yet.
+func (f *entityField) condition() expression.ConditionBuilder {
+	name := expression.Name(f.name)
+	return name.AttributeNotExists().Or(name.Equal(expression.Value(f.value)))
+}
+
+// entityStamped adds the update of the discriminator of V to p, unless the update already sets it,
+// and the condition that the stored item is of V, so that an item of another entity type with the same key is not changed.
+// It does nothing if V has no entity field.
+func entityStamped[V ItemType](p exprParts) (exprParts, error) {
+	s, err := schemaOf[V]()
+	if err != nil || s.entity == nil {
+		return p, err
+	}
+	b := expression.NewBuilder().WithCondition(s.entity.condition())
+	if !p.updates(s.entity.name) {
+		b = b.WithUpdate(expression.Set(expression.Name(s.entity.name), expression.Value(s.entity.value)))
+	}
+	return p.merge(entityPrefix, b)
+}
+
+// entityGuarded adds the condition that the stored item is of V to p, so that an item of another entity type
+// with the same key is not deleted. It does nothing if V has no entity field.
+func entityGuarded[V ItemType](p exprParts) (exprParts, error) {
+	s, err := schemaOf[V]()
+	if err != nil || s.entity == nil {
+		return p, err
+	}
+	return p.merge(entityPrefix, expression.NewBuilder().WithCondition(s.entity.condition()))
+}
+
+// Entity is an entity type of an EntitySet. Create it with EntityOf.
+type Entity struct {
+	table  string
+	field  *entityField
+	ttl    *ttlField
+	decode func(av map[string]types.AttributeValue) (any, error)
+	err    error
+}
+
+// EntityOf returns the entity type V, which must have a field tagged with `dorm:"entity=name"`.
+func EntityOf[V ItemType]() Entity {
+	s, err := schemaOf[V]()
+	if err == nil && s.entity == nil {
+		err = errors.Newf("%s has no field tagged with `dorm:\"entity=name\"`", reflect.TypeOf((*V)(nil)).Elem())
+	}
+	if err != nil {
+		return Entity{err: err}
+	}
+	return Entity{
+		table: tableNameOf[V](),
+		field: s.entity,
+		ttl:   s.ttl,
+		decode: func(av map[string]types.AttributeValue) (any, error) {
+			var v V
+			err := unmarshalItem(av, &v)
+			return v, err
+		},
+	}
+}
+
+// EntitySet is the entity types that share a table. It decodes the items of the table into their entity types.
+type EntitySet struct {
+	table     string
+	attribute string
+	entities  map[string]Entity
+	// names are the names of the entity types in the order they were given.
+	names []string
+}
+
+// NewEntitySet creates an EntitySet of entities. The entity types must have the same table
+// and the same discriminator attribute, and different names.
+func NewEntitySet(entities ...Entity) (*EntitySet, error) {
+	if len(entities) == 0 {
+		return nil, errors.New("entity set has no entity types")
+	}
+	set := &EntitySet{entities: map[string]Entity{}}
+	for _, e := range entities {
+		if e.err != nil {
+			return nil, e.err
+		}
+		if set.table == "" {
+			set.table, set.attribute = e.table, e.field.name
+		}
+		if e.table != set.table || e.field.name != set.attribute {
+			return nil, errors.Newf("entity %s of %s.%s doesn't share %s.%s", e.field.value, e.table, e.field.name, set.table, set.attribute)
+		}
+		if _, ok := set.entities[e.field.value]; ok {
+			return nil, errors.Newf("entity %s is given more than once", e.field.value)
+		}
+		set.entities[e.field.value] = e
+		set.names = append(set.names, e.field.value)
+	}
+	return set, nil
+}
+
+// TableName returns the name of the table the entity types share.
+func (s *EntitySet) TableName() string {
+	return s.table
+}
+
+// Decode decodes the item av into the entity type of its discriminator. The result is a value of the ItemType.
+func (s *EntitySet) Decode(av map[string]types.AttributeValue) (any, error) {
+	e, err := s.entityOf(av)
+	if err != nil {
+		return nil, err
+	}
+	return e.decode(av)
+}
+
+func (s *EntitySet) entityOf(av map[string]types.AttributeValue) (Entity, error) {
+	v, ok := av[s.attribute].(*types.AttributeValueMemberS)
+	if !ok {
+		return Entity{}, errors.Newf("item has no entity attribute %s", s.attribute)
+	}
+	e, ok := s.entities[v.Value]
+	if !ok {
+		return Entity{}, errors.Newf("unknown entity %s", v.Value)
+	}
+	return e, nil
+}
+
+// filtered adds the filter on the discriminators of the entity types to p.
+func (s *EntitySet) filtered(p exprParts) (exprParts, error) {
+	values := make([]expression.OperandBuilder, len(s.names))
+	for i, n := range s.names {
+		values[i] = expression.Value(n)
+	}
+	filter := expression.Name(s.attribute).In(values[0], values[1:]...)
+	return p.merge(entityPrefix, expression.NewBuilder().WithFilter(filter))
+}
+
+// decodeAll decodes the items returned by Query and Scan, skipping the expired ones if skipExpired is true.
+func (s *EntitySet) decodeAll(items []map[string]types.AttributeValue, skipExpired bool) ([]any, error) {
+	res := make([]any, 0, len(items))
+	t := now()
+	for _, av := range items {
+		e, err := s.entityOf(av)
+		if err != nil {
+			return nil, err
+		}
+		if skipExpired && e.ttl.expired(av, t) {
+			continue
+		}
+		v, err := e.decode(av)
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, v)
+	}
+	return res, nil
+}
+
+// QueryEntities executes a query on the table of set, and decodes the items into their entity types.
+// Only the items of the entity types of set are returned. The projection must include the discriminator attribute.
+//
+// Use a type switch on the results, which are values of the ItemTypes given to NewEntitySet.
+// SkipExpired is applied after the items are read, by the TTL attribute of each entity type.
+func QueryEntities(ctx context.Context, db DynamoDBAPI, set *EntitySet, expr expression.Expression, opts ...QueryOptionFunc) ([]any, map[string]types.AttributeValue, error) {
+	o := QueryOptions{}
+	for _, f := range opts {
+		f(&o)
+	}
+
+	parts, err := set.filtered(partsOf(expr))
+	if err != nil {
+		return nil, nil, err
+	}
+	input := queryInput(&set.table, parts, o)
+
+	output, err := db.Query(ctx, input)
+	if err != nil {
+		return nil, nil, newOperationError("Query", input.TableName, nil, err)
+	}
+
+	vals, err := set.decodeAll(output.Items, o.SkipExpired)
+	if err != nil {
+		return nil, nil, err
+	}
+	return vals, output.LastEvaluatedKey, nil
+}
+
+// QueryAllEntities executes a query on the table of set to retrieve all items, as QueryEntities does.
+func QueryAllEntities(ctx context.Context, db DynamoDBAPI, set *EntitySet, expr expression.Expression, opts ...QueryOptionFunc) ([]any, error) {
+	resp := []any{}
+	iopts := opts
+	for {
+		v, lastKey, err := QueryEntities(ctx, db, set, expr, iopts...)
+		if err != nil {
+			return nil, err
+		}
+		resp = append(resp, v...)
+		if len(lastKey) == 0 {
+			return resp, nil
+		}
+		iopts = append(iopts, func(o *QueryOptions) {
+			o.ExclusiveStartKey = lastKey
+		})
+	}
+}
+
+// ScanEntities performs a scan of the table of set, and decodes the items into their entity types.
+// Only the items of the entity types of set are returned. The projection must include the discriminator attribute.
+func ScanEntities(ctx context.Context, db DynamoDBAPI, set *EntitySet, expr expression.Expression, opts ...ScanOptionFunc) ([]any, map[string]types.AttributeValue, error) {
+	o := ScanOptions{}
+	for _, f := range opts {
+		f(&o)
+	}
+
+	parts, err := set.filtered(partsOf(expr))
+	if err != nil {
+		return nil, nil, err
+	}
+	input := scanInput(&set.table, parts, o)
+
+	output, err := db.Scan(ctx, input)
+	if err != nil {
+		return nil, nil, newOperationError("Scan", input.TableName, nil, err)
+	}
+
+	vals, err := set.decodeAll(output.Items, o.SkipExpired)
+	if err != nil {
+		return nil, nil, err
+	}
+	return vals, output.LastEvaluatedKey, nil
+}
+
+// ScanAllEntities performs a scan of the table of set to retrieve all items, as ScanEntities does.
+func ScanAllEntities(ctx context.Context, db DynamoDBAPI, set *EntitySet, expr expression.Expression, opts ...ScanOptionFunc) ([]any, error) {
+	resp := []any{}
+	iopts := opts
+	for {
+		v, lastKey, err := ScanEntities(ctx, db, set, expr, iopts...)
+		if err != nil {
+			return nil, err
+		}
+		resp = append(resp, v...)
+		if len(lastKey) == 0 {
+			return resp, nil
+		}
+		iopts = append(iopts, func(o *ScanOptions) {
+			o.ExclusiveStartKey = lastKey
+		})
+	}
+}
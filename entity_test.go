@@ -0,0 +1,199 @@
+package dorm
+
+import (
+	"context"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/expression"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func testEntities(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+
+	db, err := ddbMain.conn()
+	assert.NoError(t, err)
+
+	_, err = CreateTable[entityTestUser](ctx, db)
+	assert.NoError(t, err)
+
+	_, err = PutItem(ctx, db, entityTestUser{PK: "USER#1", SK: "PROFILE", Name: "alice"}, expression.Expression{})
+	assert.NoError(t, err)
+	err = BatchPutItem(ctx, db, []entityTestOrder{
+		{PK: "USER#1", SK: "ORDER#1", Total: 100},
+		{PK: "USER#1", SK: "ORDER#2", Total: 200},
+	})
+	assert.NoError(t, err)
+
+	// The discriminator is stamped even if the field is empty
+	raw, err := db.GetItem(ctx, &dynamodb.GetItemInput{
+		TableName: aws.String(entityTestUser{}.TableName()),
+		Key: map[string]types.AttributeValue{
+			"pk": &types.AttributeValueMemberS{Value: "USER#1"},
+			"sk": &types.AttributeValueMemberS{Value: "ORDER#1"},
+		},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, &types.AttributeValueMemberS{Value: "order"}, raw.Item["type"])
+
+	// UpdateItem stamps the discriminator of a new item
+	update, err := AttributeSetAll(entityTestOrder{Total: 300})
+	assert.NoError(t, err)
+	_, err = UpdateItem[entityTestOrder](ctx, db, entityTestKey{PK: "USER#1", SK: "ORDER#3"}, mustBuildExpr(expression.NewBuilder().WithUpdate(update)))
+	assert.NoError(t, err)
+	_, err = UpdateItem[entityTestOrder](ctx, db, entityTestKey{PK: "USER#1", SK: "ORDER#4"},
+		mustBuildExpr(expression.NewBuilder().WithUpdate(expression.Set(expression.Name("total"), expression.Value(400)))))
+	assert.NoError(t, err)
+
+	expr := mustBuildExpr(expression.NewBuilder().WithKeyCondition(expression.Key("pk").Equal(expression.Value("USER#1"))))
+	orders, err := QueryAll[entityTestOrder](ctx, db, expr)
+	assert.NoError(t, err)
+	assert.ElementsMatch(t, []entityTestOrder{
+		{PK: "USER#1", SK: "ORDER#1", Type: "order", Total: 100},
+		{PK: "USER#1", SK: "ORDER#2", Type: "order", Total: 200},
+		{PK: "USER#1", SK: "ORDER#3", Type: "order", Total: 300},
+		{PK: "USER#1", SK: "ORDER#4", Type: "order", Total: 400},
+	}, orders)
+
+	users, err := ScanAll[entityTestUser](ctx, db, expression.Expression{})
+	assert.NoError(t, err)
+	assert.Equal(t, []entityTestUser{{PK: "USER#1", SK: "PROFILE", Type: "user", Name: "alice"}}, users)
+
+	_, err = GetItem[entityTestUser](ctx, db, entityTestKey{PK: "USER#1", SK: "ORDER#1"}, expression.Expression{})
+	assert.ErrorIs(t, err, ErrItemNotFound)
+
+	// The items of another entity type with the same key are not changed
+	orderKey := entityTestKey{PK: "USER#1", SK: "ORDER#1"}
+	update, err = AttributeSetAll(entityTestUser{Name: "bob"})
+	assert.NoError(t, err)
+	_, err = UpdateItem[entityTestUser](ctx, db, orderKey, mustBuildExpr(expression.NewBuilder().WithUpdate(update)))
+	assert.ErrorIs(t, err, ErrConditionFailed)
+	_, err = DeleteItem[entityTestUser](ctx, db, orderKey, expression.Expression{})
+	assert.ErrorIs(t, err, ErrConditionFailed)
+	err = TransactWriteItems(ctx, db, NewTransactWriteBuilder().Add(
+		TransactUpdate[entityTestUser](orderKey, mustBuildExpr(expression.NewBuilder().WithUpdate(update))),
+	))
+	assert.ErrorIs(t, err, ErrTransactionCanceled)
+	err = TransactWriteItems(ctx, db, NewTransactWriteBuilder().Add(
+		TransactDelete[entityTestUser](orderKey, expression.Expression{}),
+	))
+	assert.ErrorIs(t, err, ErrTransactionCanceled)
+	order, err := GetItem[entityTestOrder](ctx, db, orderKey, expression.Expression{})
+	assert.NoError(t, err)
+	assert.Equal(t, &entityTestOrder{PK: "USER#1", SK: "ORDER#1", Type: "order", Total: 100}, order)
+
+	gotUser := TransactGet[entityTestUser](orderKey, expression.Expression{})
+	gotOrder := TransactGet[entityTestOrder](entityTestKey{PK: "USER#1", SK: "ORDER#2"}, expression.Expression{})
+	assert.NoError(t, TransactGetItems(ctx, db, gotUser, gotOrder))
+	_, err = gotUser.Result()
+	assert.ErrorIs(t, err, ErrItemNotFound)
+	order, err = gotOrder.Result()
+	assert.NoError(t, err)
+	assert.Equal(t, "order", order.Type)
+
+	set, err := NewEntitySet(EntityOf[entityTestUser](), EntityOf[entityTestOrder]())
+	assert.NoError(t, err)
+	all, err := QueryAllEntities(ctx, db, set, expr)
+	assert.NoError(t, err)
+	var gotUsers, gotOrders int
+	for _, v := range all {
+		switch v := v.(type) {
+		case entityTestUser:
+			gotUsers++
+			assert.Equal(t, "alice", v.Name)
+		case entityTestOrder:
+			gotOrders++
+		default:
+			t.Errorf("unexpected entity %T", v)
+		}
+	}
+	assert.Equal(t, 1, gotUsers)
+	assert.Equal(t, 4, gotOrders)
+
+	// The entity types out of the set are skipped
+	set, err = NewEntitySet(EntityOf[entityTestUser]())
+	assert.NoError(t, err)
+	all, err = ScanAllEntities(ctx, db, set, expression.Expression{})
+	assert.NoError(t, err)
+	assert.Len(t, all, 1)
+}
+
+func TestNewEntitySet(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		entities []Entity
+		err      bool
+	}{
+		"valid":         {entities: []Entity{EntityOf[entityTestUser](), EntityOf[entityTestOrder]()}},
+		"empty":         {err: true},
+		"duplicate":     {entities: []Entity{EntityOf[entityTestUser](), EntityOf[entityTestUser]()}, err: true},
+		"no entity":     {entities: []Entity{EntityOf[testItem]()}, err: true},
+		"another table": {entities: []Entity{EntityOf[entityTestUser](), EntityOf[entityTestOtherTable]()}, err: true},
+	}
+
+	for name, tt := range tests {
+		tt := tt
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			set, err := NewEntitySet(tt.entities...)
+			if tt.err {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, "test-entity", set.TableName())
+
+			v, err := set.Decode(map[string]types.AttributeValue{
+				"pk":    &types.AttributeValueMemberS{Value: "USER#1"},
+				"sk":    &types.AttributeValueMemberS{Value: "ORDER#1"},
+				"type":  &types.AttributeValueMemberS{Value: "order"},
+				"total": &types.AttributeValueMemberN{Value: "10"},
+			})
+			assert.NoError(t, err)
+			assert.Equal(t, entityTestOrder{PK: "USER#1", SK: "ORDER#1", Type: "order", Total: 10}, v)
+
+			_, err = set.Decode(map[string]types.AttributeValue{"type": &types.AttributeValueMemberS{Value: "invoice"}})
+			assert.Error(t, err)
+		})
+	}
+}
+
+type entityTestUser struct {
+	Item `dynamodbav:"-"`
+	PK   string `dynamodbav:"pk" dorm:"hash"`
+	SK   string `dynamodbav:"sk" dorm:"range"`
+	Type string `dynamodbav:"type" dorm:"entity=user"`
+	Name string `dynamodbav:"name"`
+}
+
+func (i entityTestUser) TableName() string { return "test-entity" }
+
+type entityTestOrder struct {
+	Item  `dynamodbav:"-"`
+	PK    string `dynamodbav:"pk" dorm:"hash"`
+	SK    string `dynamodbav:"sk" dorm:"range"`
+	Type  string `dynamodbav:"type" dorm:"entity=order"`
+	Total int    `dynamodbav:"total"`
+}
+
+func (i entityTestOrder) TableName() string { return "test-entity" }
+
+type entityTestOtherTable struct {
+	Item `dynamodbav:"-"`
+	PK   string `dynamodbav:"pk" dorm:"hash"`
+	Type string `dynamodbav:"type" dorm:"entity=other"`
+}
+
+func (i entityTestOtherTable) TableName() string { return "test-entity-other" }
+
+type entityTestKey struct {
+	PrimaryIndex `dynamodbav:"-"`
+	PK           string `dynamodbav:"pk"`
+	SK           string `dynamodbav:"sk"`
+}
@@ -1,6 +1,14 @@
 package dorm
 
-import "github.com/cockroachdb/errors"
+import (
+	"fmt"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/aws/smithy-go"
+	"github.com/cockroachdb/errors"
+)
 
 // nolint
 var (
@@ -9,4 +17,301 @@ var (
 	ErrItemNotFound = errors.New("Item not found")
 	// ErrMaxGetItemExceeded Max GetItem Exceeded error
 	ErrMaxGetItemExceeded = errors.New("Max GetItem Exceeded")
+	// ErrUnprocessedItems Unprocessed Items error
+	ErrUnprocessedItems = errors.New("Unprocessed items remain")
+	// ErrMaxTransactItemsExceeded Max Transact Items Exceeded error
+	ErrMaxTransactItemsExceeded = errors.New("Max TransactItems Exceeded")
+	// ErrEmptyTransaction Empty Transaction error
+	ErrEmptyTransaction = errors.New("Transaction has no items")
+	// ErrTransactionCanceled Transaction Canceled error
+	ErrTransactionCanceled = errors.New("Transaction canceled")
+
+	// ErrConditionFailed Condition Failed error
+	ErrConditionFailed = errors.New("Condition failed")
+	// ErrThrottled Throttled error
+	ErrThrottled = errors.New("Request throttled")
+	// ErrValidation Validation error
+	ErrValidation = errors.New("Validation failed")
+	// ErrResourceNotFound Resource Not Found error
+	ErrResourceNotFound = errors.New("Resource not found")
+	// ErrItemTooLarge Item Too Large error
+	ErrItemTooLarge = errors.New("Item too large")
+	// ErrItemCollectionTooLarge Item Collection Too Large error, for the items of a partition key over 10 GB in a table with LSIs
+	ErrItemCollectionTooLarge = errors.New("Item collection too large")
+	// ErrTransactionConflict Transaction Conflict error
+	ErrTransactionConflict = errors.New("Transaction conflict")
+	// ErrVersionConflict Version Conflict error
+	ErrVersionConflict = errors.New("Version conflict")
+	// ErrKeyMismatch Key Mismatch error
+	ErrKeyMismatch = errors.New("Key does not match the key schema")
+	// ErrSchemaDrift Schema Drift error
+	ErrSchemaDrift = errors.New("Table does not match the schema")
+)
+
+// Codes of CancellationReason.
+const (
+	CancellationReasonNone                            = "None"
+	CancellationReasonConditionalCheckFailed          = "ConditionalCheckFailed"
+	CancellationReasonItemCollectionSizeLimitExceeded = "ItemCollectionSizeLimitExceeded"
+	CancellationReasonTransactionConflict             = "TransactionConflict"
+	CancellationReasonProvisionedThroughputExceeded   = "ProvisionedThroughputExceeded"
+	CancellationReasonThrottlingError                 = "ThrottlingError"
+	CancellationReasonValidationError                 = "ValidationError"
 )
+
+// UnprocessedItemsError is returned by BatchPutItem when some items were still unprocessed after all attempts.
+type UnprocessedItemsError[V ItemType] struct {
+	TableName string
+	// Items are the items that were not written.
+	Items []V
+}
+
+func (e *UnprocessedItemsError[V]) Error() string {
+	return fmt.Sprintf("%d items were not written to %s: %s", len(e.Items), e.TableName, ErrUnprocessedItems)
+}
+
+// Is reports whether the target is ErrUnprocessedItems.
+func (e *UnprocessedItemsError[V]) Is(target error) bool {
+	return target == ErrUnprocessedItems
+}
+
+// UnprocessedKeysError is returned by BatchDeleteItem and BatchGetItems when some keys were still unprocessed after all attempts.
+type UnprocessedKeysError struct {
+	TableName string
+	// Keys are the keys that were not processed.
+	Keys []PrimaryIndex
+}
+
+func (e *UnprocessedKeysError) Error() string {
+	return fmt.Sprintf("%d keys were not processed in %s: %s", len(e.Keys), e.TableName, ErrUnprocessedItems)
+}
+
+// Is reports whether the target is ErrUnprocessedItems.
+func (e *UnprocessedKeysError) Is(target error) bool {
+	return target == ErrUnprocessedItems
+}
+
+// SchemaDriftError is returned by CheckTable when the table is different from the schema declared on the ItemType.
+type SchemaDriftError struct {
+	TableName string
+	// Differences are sorted by the table first, and then by the index names.
+	Differences []SchemaDifference
+}
+
+func (e *SchemaDriftError) Error() string {
+	msgs := make([]string, len(e.Differences))
+	for i, d := range e.Differences {
+		msgs[i] = d.String()
+	}
+	return fmt.Sprintf("%s: %s: [%s]", e.TableName, ErrSchemaDrift, strings.Join(msgs, ", "))
+}
+
+// Is reports whether the target is ErrSchemaDrift.
+func (e *SchemaDriftError) Is(target error) bool {
+	return target == ErrSchemaDrift
+}
+
+// CancellationReason is the reason why an action of a canceled transaction failed.
+type CancellationReason struct {
+	// Index is the position of the action in the transaction.
+	Index int
+	// Code is one of the CancellationReason constants. It is CancellationReasonNone if the action did not fail.
+	Code    string
+	Message string
+	// Item is the item that failed the condition as a *V, where V is the type of the action.
+	// It is set only if ReturnValuesOnConditionCheckFailure of the action is ALL_OLD or an expected version was checked.
+	Item any
+	// VersionConflict is true if the stored version was different from the expected version of the action.
+	VersionConflict bool
+}
+
+// TransactionCanceledError is returned by TransactWriteItems and TransactGetItems when the transaction is canceled.
+type TransactionCanceledError struct {
+	// Reasons are in the same order as the actions of the transaction.
+	Reasons []CancellationReason
+
+	err error
+}
+
+func (e *TransactionCanceledError) Error() string {
+	failed := e.Failed()
+	msgs := make([]string, len(failed))
+	for i, r := range failed {
+		msgs[i] = fmt.Sprintf("action %d: %s", r.Index, r.Code)
+		if r.Message != "" {
+			msgs[i] += ": " + r.Message
+		}
+	}
+	return fmt.Sprintf("%s: [%s]", ErrTransactionCanceled, strings.Join(msgs, ", "))
+}
+
+// Is reports whether the target is ErrTransactionCanceled, or the sentinel of the code of a failed action
+// such as ErrConditionFailed and ErrTransactionConflict.
+func (e *TransactionCanceledError) Is(target error) bool {
+	if target == ErrTransactionCanceled {
+		return true
+	}
+	for _, r := range e.Failed() {
+		if r.VersionConflict && target == ErrVersionConflict {
+			return true
+		}
+		if kind := classifyCancellationReason(r.Code); kind != nil && kind == target {
+			return true
+		}
+	}
+	return false
+}
+
+// Unwrap returns the error returned by DynamoDB.
+func (e *TransactionCanceledError) Unwrap() error {
+	return e.err
+}
+
+// Failed returns the reasons of the actions that caused the cancellation.
+func (e *TransactionCanceledError) Failed() []CancellationReason {
+	var failed []CancellationReason
+	for _, r := range e.Reasons {
+		if r.Code != CancellationReasonNone && r.Code != "" {
+			failed = append(failed, r)
+		}
+	}
+	return failed
+}
+
+// ConditionFailedError is returned by PutItem, UpdateItem and DeleteItem when the condition fails.
+type ConditionFailedError[V ItemType] struct {
+	// Item is the current item. It is set only if the item exists and either ReturnValuesOnConditionCheckFailure is ALL_OLD
+	// or an expected version was checked.
+	Item *V
+	// VersionConflict is true if the stored version was different from the expected version.
+	VersionConflict bool
+
+	err error
+}
+
+func (e *ConditionFailedError[V]) Error() string {
+	return e.err.Error()
+}
+
+// Is reports whether the target is ErrConditionFailed, or ErrVersionConflict if the version was different.
+func (e *ConditionFailedError[V]) Is(target error) bool {
+	return target == ErrConditionFailed || (e.VersionConflict && target == ErrVersionConflict)
+}
+
+// Unwrap returns the error returned by DynamoDB.
+func (e *ConditionFailedError[V]) Unwrap() error {
+	return e.err
+}
+
+// conditionFailed converts a ConditionalCheckFailedException into ConditionFailedError,
+// decoding the returned item into V and comparing its version with check, which may be nil.
+// Other errors are returned as is.
+func conditionFailed[V ItemType](err error, check *versionCheck) error {
+	var ccf *types.ConditionalCheckFailedException
+	if !errors.As(err, &ccf) {
+		return err
+	}
+
+	item, decodeErr := unmarshalAttributes[V](ccf.Item)
+	if decodeErr != nil {
+		decodeErr = errors.Wrap(decodeErr, "failed to decode the item of the failed condition")
+	}
+
+	cerr := &ConditionFailedError[V]{
+		Item:            item,
+		VersionConflict: check.conflict(ccf.Item),
+		err:             err,
+	}
+	return errors.CombineErrors(cerr, decodeErr)
+}
+
+// OperationError is returned when DynamoDB fails an operation.
+//
+// Use errors.Is with ErrConditionFailed, ErrThrottled, ErrValidation, ErrResourceNotFound,
+// ErrItemTooLarge, ErrItemCollectionTooLarge or ErrTransactionConflict to tell the cause without inspecting SDK errors.
+type OperationError struct {
+	// Op is the name of the DynamoDB operation, such as PutItem.
+	Op string
+	// Table is the name of the table. It is empty for transactions.
+	Table string
+	// Key is the key of the item. It is nil for operations on several items.
+	Key map[string]types.AttributeValue
+
+	Err error
+}
+
+func (e *OperationError) Error() string {
+	if e.Table == "" {
+		return fmt.Sprintf("%s: %v", e.Op, e.Err)
+	}
+	return fmt.Sprintf("%s %s: %v", e.Op, e.Table, e.Err)
+}
+
+// Is reports whether the target is the sentinel that classifies the error.
+func (e *OperationError) Is(target error) bool {
+	kind := classify(e.Err)
+	return kind != nil && kind == target
+}
+
+// Unwrap returns the error returned by DynamoDB.
+func (e *OperationError) Unwrap() error {
+	return e.Err
+}
+
+// newOperationError wraps the error returned by DynamoDB in OperationError. It returns nil if err is nil.
+func newOperationError(op string, table *string, key map[string]types.AttributeValue, err error) error {
+	if err == nil {
+		return nil
+	}
+	return errors.WithStack(&OperationError{
+		Op:    op,
+		Table: aws.ToString(table),
+		Key:   key,
+		Err:   err,
+	})
+}
+
+// classify returns the sentinel for the error code of a DynamoDB error, or nil if there is none.
+func classify(err error) error {
+	var apiErr smithy.APIError
+	if !errors.As(err, &apiErr) {
+		return nil
+	}
+
+	switch apiErr.ErrorCode() {
+	case "ConditionalCheckFailedException":
+		return ErrConditionFailed
+	case "ProvisionedThroughputExceededException", "ThrottlingException", "RequestLimitExceeded":
+		return ErrThrottled
+	case "ResourceNotFoundException":
+		return ErrResourceNotFound
+	case "TransactionConflictException":
+		return ErrTransactionConflict
+	case "ItemCollectionSizeLimitExceededException":
+		return ErrItemCollectionTooLarge
+	case "ValidationException":
+		// DynamoDB reports items over 400KB as a validation error.
+		if strings.Contains(apiErr.ErrorMessage(), "Item size") {
+			return ErrItemTooLarge
+		}
+		return ErrValidation
+	}
+	return nil
+}
+
+// classifyCancellationReason returns the sentinel for the code of CancellationReason, or nil if there is none.
+func classifyCancellationReason(code string) error {
+	switch code {
+	case CancellationReasonConditionalCheckFailed:
+		return ErrConditionFailed
+	case CancellationReasonTransactionConflict:
+		return ErrTransactionConflict
+	case CancellationReasonThrottlingError, CancellationReasonProvisionedThroughputExceeded:
+		return ErrThrottled
+	case CancellationReasonItemCollectionSizeLimitExceeded:
+		return ErrItemCollectionTooLarge
+	case CancellationReasonValidationError:
+		return ErrValidation
+	}
+	return nil
+}
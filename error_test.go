@@ -0,0 +1,216 @@
+package dorm
+
+import (
+	"context"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/expression"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/aws/smithy-go"
+	"github.com/cockroachdb/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+var errorSentinels = []error{
+	ErrConditionFailed,
+	ErrThrottled,
+	ErrValidation,
+	ErrResourceNotFound,
+	ErrItemTooLarge,
+	ErrItemCollectionTooLarge,
+	ErrTransactionConflict,
+}
+
+func TestOperationError(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		err  error
+		want []error
+	}{
+		"conditional check failed": {
+			err:  &types.ConditionalCheckFailedException{Message: aws.String("The conditional request failed")},
+			want: []error{ErrConditionFailed},
+		},
+		"provisioned throughput exceeded": {
+			err:  &types.ProvisionedThroughputExceededException{},
+			want: []error{ErrThrottled},
+		},
+		"throttling": {
+			err:  &smithy.GenericAPIError{Code: "ThrottlingException"},
+			want: []error{ErrThrottled},
+		},
+		"resource not found": {
+			err:  &types.ResourceNotFoundException{},
+			want: []error{ErrResourceNotFound},
+		},
+		"validation": {
+			err:  &smithy.GenericAPIError{Code: "ValidationException", Message: "One or more parameter values were invalid"},
+			want: []error{ErrValidation},
+		},
+		"item too large": {
+			err:  &smithy.GenericAPIError{Code: "ValidationException", Message: "Item size has exceeded the maximum allowed size"},
+			want: []error{ErrItemTooLarge},
+		},
+		"item collection too large": {
+			err:  &types.ItemCollectionSizeLimitExceededException{},
+			want: []error{ErrItemCollectionTooLarge},
+		},
+		"transaction conflict": {
+			err:  &types.TransactionConflictException{},
+			want: []error{ErrTransactionConflict},
+		},
+		"transaction canceled": {
+			err: &TransactionCanceledError{Reasons: []CancellationReason{
+				{Index: 0, Code: CancellationReasonConditionalCheckFailed},
+				{Index: 1, Code: CancellationReasonNone},
+				{Index: 2, Code: CancellationReasonTransactionConflict},
+			}},
+			want: []error{ErrTransactionCanceled, ErrConditionFailed, ErrTransactionConflict},
+		},
+		"unknown": {
+			err: errors.New("unknown"),
+		},
+	}
+	for name, tt := range tests {
+		tt := tt
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			err := newOperationError("PutItem", aws.String(testItemTableName), nil, tt.err)
+			for _, sentinel := range errorSentinels {
+				assert.Equal(t, contains(tt.want, sentinel), errors.Is(err, sentinel), sentinel.Error())
+			}
+			for _, w := range tt.want {
+				assert.ErrorIs(t, err, w)
+			}
+
+			var oerr *OperationError
+			assert.True(t, errors.As(err, &oerr))
+			assert.Equal(t, "PutItem", oerr.Op)
+			assert.Equal(t, testItemTableName, oerr.Table)
+			assert.Same(t, tt.err, errors.Unwrap(oerr))
+		})
+	}
+}
+
+func contains(errs []error, target error) bool {
+	for _, err := range errs {
+		if err == target {
+			return true
+		}
+	}
+	return false
+}
+
+func testtestItemOperationError(t *testing.T) {
+	t.Parallel()
+
+	db, err := ddbMain.conn()
+	assert.NoError(t, err)
+
+	o := testItem{}
+	err = RandomizeDDBStruct(&o)
+	assert.NoError(t, err)
+	_, err = PutItem(context.Background(), db, o, expression.Expression{})
+	assert.NoError(t, err)
+
+	expr, err := expression.NewBuilder().
+		WithCondition(expression.AttributeNotExists(expression.Name(testItemColumns.HashKey))).
+		Build()
+	assert.NoError(t, err)
+
+	_, err = DeleteItem[testItem](context.Background(), db, testItemPrimaryIndex{HashKey: o.HashKey}, expr)
+	assert.ErrorIs(t, err, ErrConditionFailed)
+
+	var oerr *OperationError
+	assert.True(t, errors.As(err, &oerr))
+	assert.Equal(t, "DeleteItem", oerr.Op)
+	assert.Equal(t, testItemTableName, oerr.Table)
+	assert.Equal(t, &types.AttributeValueMemberS{Value: o.HashKey}, oerr.Key[testItemColumns.HashKey])
+
+	var ccf *types.ConditionalCheckFailedException
+	assert.True(t, errors.As(err, &ccf))
+
+	_, err = PutItem(context.Background(), db, o, expr)
+	assert.ErrorIs(t, err, ErrConditionFailed)
+	assert.True(t, errors.As(err, &oerr))
+	assert.Equal(t, "PutItem", oerr.Op)
+	assert.Equal(t, &types.AttributeValueMemberS{Value: o.HashKey}, oerr.Key[testItemColumns.HashKey])
+}
+
+func testtestItemConditionFailedError(t *testing.T) {
+	t.Parallel()
+
+	notExists := expression.AttributeNotExists(expression.Name(testItemColumns.HashKey))
+
+	tests := map[string]struct {
+		write func(ctx context.Context, db DynamoDBAPI, o testItem, expr expression.Expression) error
+		// update adds an update to the expression.
+		update   bool
+		wantItem bool
+	}{
+		"put": {
+			write: func(ctx context.Context, db DynamoDBAPI, o testItem, expr expression.Expression) error {
+				_, err := PutItem(ctx, db, o, expr)
+				return err
+			},
+		},
+		"put with all old": {
+			write: func(ctx context.Context, db DynamoDBAPI, o testItem, expr expression.Expression) error {
+				_, err := PutItem(ctx, db, o, expr, WithPutReturnValuesOnConditionCheckFailure(types.ReturnValuesOnConditionCheckFailureAllOld))
+				return err
+			},
+			wantItem: true,
+		},
+		"update with all old": {
+			write: func(ctx context.Context, db DynamoDBAPI, o testItem, expr expression.Expression) error {
+				_, err := UpdateItem[testItem](ctx, db, testItemPrimaryIndex{HashKey: o.HashKey}, expr, WithUpdateReturnValuesOnConditionCheckFailure(types.ReturnValuesOnConditionCheckFailureAllOld))
+				return err
+			},
+			update:   true,
+			wantItem: true,
+		},
+		"delete with all old": {
+			write: func(ctx context.Context, db DynamoDBAPI, o testItem, expr expression.Expression) error {
+				_, err := DeleteItem[testItem](ctx, db, testItemPrimaryIndex{HashKey: o.HashKey}, expr, WithDeleteReturnValuesOnConditionCheckFailure(types.ReturnValuesOnConditionCheckFailureAllOld))
+				return err
+			},
+			wantItem: true,
+		},
+	}
+	for name, tt := range tests {
+		tt := tt
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			ctx := context.Background()
+
+			db, err := ddbMain.conn()
+			assert.NoError(t, err)
+
+			o := testItem{}
+			err = RandomizeDDBStruct(&o)
+			assert.NoError(t, err)
+			_, err = PutItem(ctx, db, o, expression.Expression{})
+			assert.NoError(t, err)
+
+			b := expression.NewBuilder().WithCondition(notExists)
+			if tt.update {
+				b = b.WithUpdate(expression.Set(expression.Name(testItemColumns.Str), expression.Value("updated")))
+			}
+			expr, err := b.Build()
+			assert.NoError(t, err)
+
+			err = tt.write(ctx, db, o, expr)
+			assert.ErrorIs(t, err, ErrConditionFailed)
+
+			var cerr *ConditionFailedError[testItem]
+			assert.True(t, errors.As(err, &cerr))
+			if !tt.wantItem {
+				assert.Nil(t, cerr.Item)
+				return
+			}
+			assert.Equal(t, &o, cerr.Item)
+		})
+	}
+}
@@ -0,0 +1,143 @@
+package dorm
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/expression"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// placeholderRegexp matches the name and value placeholders generated by expression.Builder, such as #0 and :0.
+var placeholderRegexp = regexp.MustCompile(`[#:][0-9]+`)
+
+// exprParts is a built expression that dorm can extend with its own conditions and updates.
+type exprParts struct {
+	Condition    *string
+	Update       *string
+	Projection   *string
+	Filter       *string
+	KeyCondition *string
+	Names        map[string]string
+	Values       map[string]types.AttributeValue
+}
+
+func partsOf(expr expression.Expression) exprParts {
+	return exprParts{
+		Condition:    expr.Condition(),
+		Update:       expr.Update(),
+		Projection:   expr.Projection(),
+		Filter:       expr.Filter(),
+		KeyCondition: expr.KeyCondition(),
+		Names:        expr.Names(),
+		Values:       expr.Values(),
+	}
+}
+
+// merge adds the expression built from b to p.
+// The placeholders of b are prefixed with prefix so that they don't collide with those of p,
+// so each caller must use a different prefix.
+// Conditions, filters and key conditions are joined with AND, and updates are merged by section.
+func (p exprParts) merge(prefix string, b expression.Builder) (exprParts, error) {
+	built, err := b.Build()
+	if err != nil {
+		return p, err
+	}
+
+	rename := func(s *string) *string {
+		if s == nil {
+			return nil
+		}
+		r := placeholderRegexp.ReplaceAllStringFunc(*s, func(m string) string {
+			return m[:1] + prefix + m[1:]
+		})
+		return &r
+	}
+
+	res := exprParts{
+		Condition:    joinExpr(p.Condition, rename(built.Condition()), func(a, b string) string { return "(" + a + ") AND (" + b + ")" }),
+		Filter:       joinExpr(p.Filter, rename(built.Filter()), func(a, b string) string { return "(" + a + ") AND (" + b + ")" }),
+		KeyCondition: joinExpr(p.KeyCondition, rename(built.KeyCondition()), func(a, b string) string { return "(" + a + ") AND (" + b + ")" }),
+		Projection:   joinExpr(p.Projection, rename(built.Projection()), func(a, b string) string { return a + ", " + b }),
+		Update:       joinExpr(p.Update, rename(built.Update()), mergeUpdate),
+	}
+
+	for k, v := range p.Names {
+		if res.Names == nil {
+			res.Names = map[string]string{}
+		}
+		res.Names[k] = v
+	}
+	for k, v := range built.Names() {
+		if res.Names == nil {
+			res.Names = map[string]string{}
+		}
+		res.Names[*rename(&k)] = v
+	}
+	for k, v := range p.Values {
+		if res.Values == nil {
+			res.Values = map[string]types.AttributeValue{}
+		}
+		res.Values[k] = v
+	}
+	for k, v := range built.Values() {
+		if res.Values == nil {
+			res.Values = map[string]types.AttributeValue{}
+		}
+		res.Values[*rename(&k)] = v
+	}
+
+	return res, nil
+}
+
+func joinExpr(a, b *string, join func(a, b string) string) *string {
+	switch {
+	case a == nil:
+		return b
+	case b == nil:
+		return a
+	}
+	s := join(*a, *b)
+	return &s
+}
+
+// mergeUpdate merges two update expressions, since each of SET, REMOVE, ADD and DELETE can only appear once.
+// It relies on the format of expression.Builder, which writes each section on its own line.
+func mergeUpdate(a, b string) string {
+	sections := map[string][]string{}
+	for _, s := range []string{a, b} {
+		for _, line := range strings.Split(s, "\n") {
+			if line == "" {
+				continue
+			}
+			keyword, actions, _ := strings.Cut(line, " ")
+			sections[keyword] = append(sections[keyword], actions)
+		}
+	}
+
+	keywords := make([]string, 0, len(sections))
+	for k := range sections {
+		keywords = append(keywords, k)
+	}
+	sort.Strings(keywords)
+
+	var sb strings.Builder
+	for _, k := range keywords {
+		sb.WriteString(k + " " + strings.Join(sections[k], ", ") + "\n")
+	}
+	return sb.String()
+}
+
+// updates reports whether the update expression refers to the attribute name.
+func (p exprParts) updates(name string) bool {
+	if p.Update == nil {
+		return false
+	}
+	for _, m := range placeholderRegexp.FindAllString(*p.Update, -1) {
+		if m[0] == '#' && p.Names[m] == name {
+			return true
+		}
+	}
+	return false
+}
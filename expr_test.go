@@ -0,0 +1,72 @@
+package dorm
+
+import (
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/expression"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExprPartsMerge(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		expr  expression.Expression
+		added expression.Builder
+
+		wantCondition *string
+		wantUpdate    *string
+		wantNames     map[string]string
+		wantValues    map[string]types.AttributeValue
+	}{
+		"condition and update": {
+			expr: mustBuildExpr(expression.NewBuilder().
+				WithCondition(expression.Name("a").Equal(expression.Value("x"))).
+				WithUpdate(expression.Set(expression.Name("b"), expression.Value("y")).Remove(expression.Name("c")))),
+			added: expression.NewBuilder().
+				WithCondition(expression.AttributeNotExists(expression.Name("v"))).
+				WithUpdate(expression.Add(expression.Name("v"), expression.Value(1)).Set(expression.Name("d"), expression.Value("z"))),
+			wantCondition: strPtr("(#0 = :0) AND (attribute_not_exists (#p0))"),
+			wantUpdate:    strPtr("ADD #p0 :p0\nREMOVE #1\nSET #2 = :1, #p1 = :p1\n"),
+			wantNames:     map[string]string{"#0": "a", "#1": "c", "#2": "b", "#p0": "v", "#p1": "d"},
+			wantValues: map[string]types.AttributeValue{
+				":0":  &types.AttributeValueMemberS{Value: "x"},
+				":1":  &types.AttributeValueMemberS{Value: "y"},
+				":p0": &types.AttributeValueMemberN{Value: "1"},
+				":p1": &types.AttributeValueMemberS{Value: "z"},
+			},
+		},
+		"empty expression": {
+			expr: expression.Expression{},
+			added: expression.NewBuilder().
+				WithCondition(expression.AttributeExists(expression.Name("v"))),
+			wantCondition: strPtr("attribute_exists (#p0)"),
+			wantNames:     map[string]string{"#p0": "v"},
+		},
+	}
+	for name, tt := range tests {
+		tt := tt
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			got, err := partsOf(tt.expr).merge("p", tt.added)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.wantCondition, got.Condition)
+			assert.Equal(t, tt.wantUpdate, got.Update)
+			assert.Equal(t, tt.wantNames, got.Names)
+			assert.Equal(t, tt.wantValues, got.Values)
+		})
+	}
+}
+
+func mustBuildExpr(b expression.Builder) expression.Expression {
+	expr, err := b.Build()
+	if err != nil {
+		panic(err)
+	}
+	return expr
+}
+
+func strPtr(s string) *string {
+	return &s
+}
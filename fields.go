@@ -0,0 +1,191 @@
+package dorm
+
+import (
+	"reflect"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/cockroachdb/errors"
+)
+
+var marshalerType = reflect.TypeOf((*attributevalue.Marshaler)(nil)).Elem()
+
+// attributeField is a struct field mapped to an attribute.
+type attributeField struct {
+	reflect.StructField
+	// index is the path of the field from the struct, including the embedded structs it is promoted from.
+	index []int
+	// name is the attribute name.
+	name string
+	// children are the attributes of a nested struct. It is nil if the field is not a nested struct.
+	children []attributeField
+	// role is given by the dorm tag. It is only set at the top level.
+	role fieldRole
+	// template is given by `dorm:"template=..."`. It is only set at the top level, and nil if there is no template.
+	template *keyTemplate
+}
+
+// itemStructType returns the struct type of an ItemType, which may be a pointer to a struct.
+func itemStructType(rt reflect.Type) (reflect.Type, error) {
+	if rt == nil {
+		return nil, errors.New("item type is nil")
+	}
+	for rt.Kind() == reflect.Pointer {
+		rt = rt.Elem()
+	}
+	if rt.Kind() != reflect.Struct {
+		return nil, errors.Newf("%s is not a struct", rt)
+	}
+	return rt, nil
+}
+
+// attributeFieldsOf returns the attributes of the struct type rt.
+//
+// The fields are walked as attributevalue does: the fields of embedded structs without an attribute name are promoted,
+// a shallower field hides a deeper one with the same name, and nested structs are walked into children.
+// Only the fields tagged with dynamodbav are taken at the top level, while all the exported fields are taken in
+// nested structs, since attributevalue marshals them all.
+func attributeFieldsOf(rt reflect.Type) ([]attributeField, error) {
+	rt, err := itemStructType(rt)
+	if err != nil {
+		return nil, err
+	}
+	return walkFields(rt, true, map[reflect.Type]bool{rt: true}), nil
+}
+
+func walkFields(rt reflect.Type, taggedOnly bool, visiting map[reflect.Type]bool) []attributeField {
+	var res []attributeField
+	// depth is the length of the index of the field taking each name, to resolve the promoted fields.
+	depth := map[string]int{}
+	hidden := map[string]bool{}
+
+	var walk func(rt reflect.Type, prefix []int)
+	walk = func(rt reflect.Type, prefix []int) {
+		for i := 0; i < rt.NumField(); i++ {
+			f := rt.Field(i)
+			tag := f.Tag.Get(structTag)
+			if tag == ignoreStructTag {
+				continue
+			}
+			index := append(append([]int{}, prefix...), i)
+			name, _, _ := strings.Cut(tag, ",")
+
+			ft := f.Type
+			for ft.Kind() == reflect.Pointer {
+				ft = ft.Elem()
+			}
+			if f.Anonymous && name == "" {
+				// Embedded structs are promoted, and embedded interfaces such as Item are not attributes.
+				if ft.Kind() == reflect.Struct && !visiting[ft] {
+					visiting[ft] = true
+					walk(ft, index)
+					delete(visiting, ft)
+				}
+				continue
+			}
+			if !f.IsExported() || (taggedOnly && tag == "") {
+				continue
+			}
+			if name == "" {
+				name = f.Name
+			}
+
+			if d, ok := depth[name]; ok {
+				switch {
+				case d < len(index):
+					continue
+				case d == len(index):
+					hidden[name] = true
+					continue
+				}
+			}
+			depth[name] = len(index)
+			delete(hidden, name)
+
+			field := attributeField{StructField: f, index: index, name: name}
+			if taggedOnly {
+				field.role = roleOf(f)
+			}
+			if isNestedStruct(ft) && !visiting[ft] {
+				visiting[ft] = true
+				field.children = walkFields(ft, false, visiting)
+				delete(visiting, ft)
+			}
+
+			// A deeper field with the same name is replaced.
+			replaced := false
+			for j := range res {
+				if res[j].name == name {
+					res[j] = field
+					replaced = true
+				}
+			}
+			if !replaced {
+				res = append(res, field)
+			}
+		}
+	}
+	walk(rt, nil)
+
+	if len(hidden) == 0 {
+		return res
+	}
+	filtered := res[:0]
+	for _, f := range res {
+		if !hidden[f.name] {
+			filtered = append(filtered, f)
+		}
+	}
+	return filtered
+}
+
+// isNestedStruct reports whether attributevalue marshals a value of rt as a map of its fields.
+func isNestedStruct(rt reflect.Type) bool {
+	return rt.Kind() == reflect.Struct && rt != timeType &&
+		!rt.Implements(marshalerType) && !reflect.PointerTo(rt).Implements(marshalerType)
+}
+
+// fieldValue returns the value of the field at index of v.
+// It returns false if an embedded pointer on the way is nil.
+func fieldValue(v reflect.Value, index []int) (reflect.Value, bool) {
+	for i, x := range index {
+		if i > 0 {
+			for v.Kind() == reflect.Pointer {
+				if v.IsNil() {
+					return reflect.Value{}, false
+				}
+				v = v.Elem()
+			}
+		}
+		v = v.Field(x)
+	}
+	return v, true
+}
+
+// settableField returns the field at index of v, allocating the nil embedded pointers on the way.
+// v must be addressable.
+func settableField(v reflect.Value, index []int) reflect.Value {
+	for i, x := range index {
+		if i > 0 {
+			for v.Kind() == reflect.Pointer {
+				if v.IsNil() {
+					v.Set(reflect.New(v.Type().Elem()))
+				}
+				v = v.Elem()
+			}
+		}
+		v = v.Field(x)
+	}
+	return v
+}
+
+// structValue dereferences v until it is a struct. It returns false if a pointer is nil.
+func structValue(v reflect.Value) (reflect.Value, bool) {
+	for v.Kind() == reflect.Pointer || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return reflect.Value{}, false
+		}
+		v = v.Elem()
+	}
+	return v, v.Kind() == reflect.Struct
+}
@@ -1,6 +1,12 @@
 package dorm
 
 import (
+	"encoding/base64"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
 	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
 	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
 )
@@ -34,6 +40,14 @@ func buildIndex[T IndexType](i T) (map[string]types.AttributeValue, error) {
 	if err != nil {
 		return nil, err
 	}
+	// compose the key templates of index structs
+	if rv, ok := structValue(reflect.ValueOf(i)); ok {
+		s := schemaOfType(rv.Type())
+		if s.err != nil {
+			return nil, s.err
+		}
+		s.composeTemplates(rv, v)
+	}
 	return v, nil
 }
 
@@ -67,3 +81,86 @@ func ConstructStartKeyWithGSI(p PrimaryIndex, s GlobalSecondaryIndex) (map[strin
 func ConstructStartKey(p PrimaryIndex) (map[string]types.AttributeValue, error) {
 	return buildIndex(p)
 }
+
+// KeyString returns a string that identifies the primary key.
+//
+// Equal keys always produce the same string, so it can be used as a map key,
+// as in the result of BatchGetItemsMap.
+func KeyString(p PrimaryIndex) (string, error) {
+	key, err := buildIndex(p)
+	if err != nil {
+		return "", err
+	}
+	return attributeMapKey(key), nil
+}
+
+// attributeMapKey encodes an attribute map into a string that is equal for equal maps.
+// It is used to match the items and keys returned by DynamoDB with the requested ones.
+func attributeMapKey(m map[string]types.AttributeValue) string {
+	var sb strings.Builder
+	writeAttributeMap(&sb, m)
+	return sb.String()
+}
+
+// attributeValueKey encodes an attribute value like attributeMapKey, so sets are equal regardless of their order.
+func attributeValueKey(av types.AttributeValue) string {
+	var sb strings.Builder
+	writeAttributeValue(&sb, av)
+	return sb.String()
+}
+
+func writeAttributeMap(sb *strings.Builder, m map[string]types.AttributeValue) {
+	names := make([]string, 0, len(m))
+	for k := range m {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	sb.WriteByte('{')
+	for _, k := range names {
+		sb.WriteString(strconv.Quote(k))
+		sb.WriteByte(':')
+		writeAttributeValue(sb, m[k])
+		sb.WriteByte(',')
+	}
+	sb.WriteByte('}')
+}
+
+func writeAttributeValue(sb *strings.Builder, av types.AttributeValue) {
+	switch v := av.(type) {
+	case *types.AttributeValueMemberS:
+		sb.WriteString("S" + strconv.Quote(v.Value))
+	case *types.AttributeValueMemberN:
+		sb.WriteString("N" + strconv.Quote(v.Value))
+	case *types.AttributeValueMemberB:
+		sb.WriteString("B" + base64.StdEncoding.EncodeToString(v.Value))
+	case *types.AttributeValueMemberBOOL:
+		sb.WriteString("BOOL" + strconv.FormatBool(v.Value))
+	case *types.AttributeValueMemberNULL:
+		sb.WriteString("NULL")
+	case *types.AttributeValueMemberSS:
+		writeSortedSet(sb, "SS", v.Value, strconv.Quote)
+	case *types.AttributeValueMemberNS:
+		writeSortedSet(sb, "NS", v.Value, strconv.Quote)
+	case *types.AttributeValueMemberBS:
+		writeSortedSet(sb, "BS", v.Value, base64.StdEncoding.EncodeToString)
+	case *types.AttributeValueMemberL:
+		sb.WriteString("L[")
+		for _, e := range v.Value {
+			writeAttributeValue(sb, e)
+			sb.WriteByte(',')
+		}
+		sb.WriteByte(']')
+	case *types.AttributeValueMemberM:
+		sb.WriteByte('M')
+		writeAttributeMap(sb, v.Value)
+	}
+}
+
+func writeSortedSet[T any](sb *strings.Builder, typ string, set []T, enc func(T) string) {
+	vals := make([]string, len(set))
+	for i, v := range set {
+		vals[i] = enc(v)
+	}
+	sort.Strings(vals)
+	sb.WriteString(typ + "[" + strings.Join(vals, ",") + "]")
+}
@@ -1,6 +1,11 @@
 package dorm
 
-import "github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+import (
+	"reflect"
+
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
 
 type Item interface {
 	isItem()
@@ -18,3 +23,60 @@ func checkEmptyResp(m map[string]types.AttributeValue) bool {
 func checkEmptyRespList(m []map[string]types.AttributeValue) bool {
 	return len(m) == 0
 }
+
+// unmarshalAttributes unmarshals the attributes returned by a write. It returns nil if there are none.
+func unmarshalAttributes[V ItemType](m map[string]types.AttributeValue) (*V, error) {
+	if checkEmptyResp(m) {
+		return nil, nil
+	}
+
+	var val V
+	if err := unmarshalItem(m, &val); err != nil {
+		return nil, err
+	}
+
+	return &val, nil
+}
+
+// marshalItem marshals item, composing the attributes of key templates, converting the attribute tagged with
+// `dorm:"ttl"` into epoch seconds and setting the attribute tagged with `dorm:"entity=name"` to the name of the entity type.
+func marshalItem[V ItemType](item V) (map[string]types.AttributeValue, error) {
+	s, err := schemaOf[V]()
+	if err != nil {
+		return nil, err
+	}
+	av, err := attributevalue.MarshalMap(item)
+	if err != nil {
+		return nil, err
+	}
+	s.composeTemplates(reflect.ValueOf(item), av)
+	if err := s.ttl.encode(av); err != nil {
+		return nil, err
+	}
+	s.entity.stamp(av)
+	return av, nil
+}
+
+// unmarshalItem unmarshals m into v, converting the attribute tagged with `dorm:"ttl"` from epoch seconds
+// and splitting the attributes of key templates into their fields.
+func unmarshalItem[V ItemType](m map[string]types.AttributeValue, v *V) error {
+	s, err := schemaOf[V]()
+	if err != nil {
+		return err
+	}
+	if err := attributevalue.UnmarshalMap(s.ttl.decode(m), v); err != nil {
+		return err
+	}
+	return s.splitTemplates(reflect.ValueOf(v), m)
+}
+
+// unmarshalItems unmarshals the items returned by Query and Scan.
+func unmarshalItems[V ItemType](items []map[string]types.AttributeValue) ([]V, error) {
+	vals := make([]V, len(items))
+	for i, item := range items {
+		if err := unmarshalItem(item, &vals[i]); err != nil {
+			return nil, err
+		}
+	}
+	return vals, nil
+}
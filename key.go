@@ -0,0 +1,302 @@
+package dorm
+
+import (
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/cockroachdb/errors"
+)
+
+const (
+	hashKeyTagOption  = "hash"
+	rangeKeyTagOption = "range"
+	// gsiTagOption declares a key of a GSI, such as `dorm:"gsi=name:hash"`.
+	gsiTagOption = "gsi="
+	// lsiTagOption declares the range key of an LSI, such as `dorm:"lsi=name"`.
+	lsiTagOption = "lsi="
+)
+
+// indexKey is the attribute names of the keys of an index. rangeKey is empty if the index has no range key.
+type indexKey struct {
+	hashKey  string
+	rangeKey string
+}
+
+// names returns the attribute names of the keys.
+func (k indexKey) names() []string {
+	if k.rangeKey == "" {
+		return []string{k.hashKey}
+	}
+	return []string{k.hashKey, k.rangeKey}
+}
+
+// indexKeysOf parses the options tagged with `dorm:"gsi=name:hash"`, `dorm:"gsi=name:range"` and `dorm:"lsi=name"`
+// of a field into the keys of the indexes. The hash keys of the LSIs are set later, since they are the hash key of the table.
+func indexKeysOf(f attributeField, gsis, lsis map[string]indexKey) error {
+	for _, o := range strings.Split(f.Tag.Get(dormStructTag), ",") {
+		if name, ok := strings.CutPrefix(o, lsiTagOption); ok {
+			if name == "" {
+				return errors.Newf("invalid lsi option %q of field %s, want lsi=name", o, f.Name)
+			}
+			if _, ok := lsis[name]; ok {
+				return errors.Newf("lsi %s has more than one range key field", name)
+			}
+			lsis[name] = indexKey{rangeKey: f.name}
+			continue
+		}
+
+		spec, ok := strings.CutPrefix(o, gsiTagOption)
+		if !ok {
+			continue
+		}
+		name, role, _ := strings.Cut(spec, ":")
+		k := gsis[name]
+		var dst *string
+		switch role {
+		case hashKeyTagOption:
+			dst = &k.hashKey
+		case rangeKeyTagOption:
+			dst = &k.rangeKey
+		}
+		if name == "" || dst == nil {
+			return errors.Newf("invalid gsi option %q of field %s, want gsi=name:hash or gsi=name:range", o, f.Name)
+		}
+		if *dst != "" {
+			return errors.Newf("gsi %s has more than one %s key field", name, role)
+		}
+		*dst = f.name
+		gsis[name] = k
+	}
+	return nil
+}
+
+// Key is a PrimaryIndex that holds the key attributes as they are. It is returned by KeyOf.
+type Key map[string]types.AttributeValue
+
+func (Key) isIndex()        {}
+func (Key) isPrimaryIndex() {}
+
+// MarshalDynamoDBAttributeValue marshals the key into a map of the key attributes.
+func (k Key) MarshalDynamoDBAttributeValue() (types.AttributeValue, error) {
+	return &types.AttributeValueMemberM{Value: k}, nil
+}
+
+// GSIKey is a GlobalSecondaryIndex that holds the key attributes as they are. It is returned by GSIKeyOf.
+type GSIKey map[string]types.AttributeValue
+
+func (GSIKey) isIndex()                {}
+func (GSIKey) isGlobalSecondaryIndex() {}
+
+// MarshalDynamoDBAttributeValue marshals the key into a map of the key attributes.
+func (k GSIKey) MarshalDynamoDBAttributeValue() (types.AttributeValue, error) {
+	return &types.AttributeValueMemberM{Value: k}, nil
+}
+
+// KeyOf returns the primary key of item, from the fields tagged with `dorm:"hash"` and `dorm:"range"`.
+// It returns ErrKeyMismatch if a key attribute is missing.
+func KeyOf[V ItemType](item V) (Key, error) {
+	s, err := schemaOf[V]()
+	if err != nil {
+		return nil, err
+	}
+	if s.primaryKey.hashKey == "" {
+		return nil, errors.Newf("%s has no field tagged with `dorm:\"hash\"`", reflect.TypeOf(item))
+	}
+	av, err := marshalItem(item)
+	if err != nil {
+		return nil, err
+	}
+	return keyFrom(av, s.primaryKey)
+}
+
+// GSIKeyOf returns the key of the GSI named name of item, from the fields tagged with `dorm:"gsi=name:hash"` and `dorm:"gsi=name:range"`.
+// It returns ErrKeyMismatch if a key attribute is missing.
+func GSIKeyOf[V ItemType](item V, name string) (GSIKey, error) {
+	s, err := schemaOf[V]()
+	if err != nil {
+		return nil, err
+	}
+	k, ok := s.gsis[name]
+	if !ok || k.hashKey == "" {
+		return nil, errors.Newf("%s has no gsi %s", reflect.TypeOf(item), name)
+	}
+	av, err := marshalItem(item)
+	if err != nil {
+		return nil, err
+	}
+	key, err := keyFrom(av, k)
+	return GSIKey(key), err
+}
+
+// keyFrom returns the attributes of k in the item av.
+func keyFrom(av map[string]types.AttributeValue, k indexKey) (Key, error) {
+	res := Key{}
+	for _, name := range k.names() {
+		v, ok := av[name]
+		if !ok || isEmptyKeyAttribute(v) {
+			return nil, errors.Wrapf(ErrKeyMismatch, "key attribute %s is missing", name)
+		}
+		res[name] = v
+	}
+	return res, nil
+}
+
+// isEmptyKeyAttribute reports whether av can't be a key, since it is NULL or an empty string or binary.
+func isEmptyKeyAttribute(av types.AttributeValue) bool {
+	switch v := av.(type) {
+	case *types.AttributeValueMemberS:
+		return v.Value == ""
+	case *types.AttributeValueMemberB:
+		return len(v.Value) == 0
+	}
+	return isNullAttribute(av)
+}
+
+// checkKey returns ErrKeyMismatch if the attributes of key are not the keys of k.
+func checkKey(key map[string]types.AttributeValue, k indexKey) error {
+	if err := checkKeyNames(sortedNames(key), k); err != nil {
+		return err
+	}
+	_, err := keyFrom(key, k)
+	return err
+}
+
+// checkKeyNames returns ErrKeyMismatch if names are not the attribute names of the keys of k.
+func checkKeyNames(names []string, k indexKey) error {
+	want := k.names()
+	ok := len(names) == len(want)
+	for _, n := range want {
+		found := false
+		for _, m := range names {
+			found = found || m == n
+		}
+		ok = ok && found
+	}
+	if !ok {
+		return errors.Wrapf(ErrKeyMismatch, "key has %s, want %s", strings.Join(names, ", "), strings.Join(want, ", "))
+	}
+	return nil
+}
+
+func sortedNames(m map[string]types.AttributeValue) []string {
+	names := make([]string, 0, len(m))
+	for k := range m {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// primaryKeyOf builds the key of idx for V. If V declares its keys with tags, the key must match them.
+func primaryKeyOf[V ItemType](idx PrimaryIndex) (map[string]types.AttributeValue, error) {
+	key, err := buildIndex(idx)
+	if err != nil {
+		return nil, err
+	}
+	s, err := schemaOf[V]()
+	if err != nil {
+		return nil, err
+	}
+	if s.primaryKey.hashKey == "" {
+		return key, nil
+	}
+	if err := checkKey(key, s.primaryKey); err != nil {
+		return nil, errors.Wrapf(err, "%s", tableNameOf[V]())
+	}
+	return key, nil
+}
+
+// itemKeyOf returns the primary key of the marshaled item av of V, or nil if V declares no keys with tags.
+// It returns ErrKeyMismatch if av lacks a key attribute.
+func itemKeyOf[V ItemType](av map[string]types.AttributeValue) (Key, error) {
+	s, err := schemaOf[V]()
+	if err != nil {
+		return nil, err
+	}
+	if s.primaryKey.hashKey == "" {
+		return nil, nil
+	}
+	key, err := keyFrom(av, s.primaryKey)
+	if err != nil {
+		return nil, errors.Wrapf(err, "%s", tableNameOf[V]())
+	}
+	return key, nil
+}
+
+// checkItemKey returns ErrKeyMismatch if the item av lacks a key attribute declared with tags on V.
+func checkItemKey[V ItemType](av map[string]types.AttributeValue) error {
+	_, err := itemKeyOf[V](av)
+	return err
+}
+
+// ValidateIndex returns ErrKeyMismatch if the attributes of the index struct I don't match the keys declared on V.
+// A PrimaryIndex must have the attributes tagged with `dorm:"hash"` and `dorm:"range"`,
+// a GlobalSecondaryIndex must have those of one of the GSIs tagged with `dorm:"gsi=name:hash"`,
+// and a LocalSecondaryIndex must have the hash key and the range key of one of the LSIs tagged with `dorm:"lsi=name"`.
+func ValidateIndex[V ItemType, I IndexType]() error {
+	s, err := schemaOf[V]()
+	if err != nil {
+		return err
+	}
+	rt := reflect.TypeOf((*I)(nil)).Elem()
+	is := schemaOfType(rt)
+	if is.err != nil {
+		return is.err
+	}
+	names := make([]string, 0, len(is.fields))
+	for _, f := range is.fields {
+		names = append(names, f.name)
+	}
+
+	switch any(*new(I)).(type) {
+	case PrimaryIndex:
+		if s.primaryKey.hashKey == "" {
+			return errors.Newf("%s has no field tagged with `dorm:\"hash\"`", tableNameOf[V]())
+		}
+		if err := checkKeyNames(names, s.primaryKey); err != nil {
+			return errors.Wrapf(err, "%s of %s", rt, tableNameOf[V]())
+		}
+	case GlobalSecondaryIndex:
+		for _, k := range s.gsis {
+			if checkKeyNames(names, k) == nil {
+				return nil
+			}
+		}
+		return errors.Wrapf(ErrKeyMismatch, "%s matches no gsi of %s", rt, tableNameOf[V]())
+	case LocalSecondaryIndex:
+		for _, k := range s.lsis {
+			if checkKeyNames(names, k) == nil {
+				return nil
+			}
+		}
+		return errors.Wrapf(ErrKeyMismatch, "%s matches no lsi of %s", rt, tableNameOf[V]())
+	}
+	return nil
+}
+
+// keyNames returns the attribute names of the fields of the PrimaryIndex P.
+func keyNames[P PrimaryIndex]() []string {
+	s := schemaOfType(reflect.TypeOf((*P)(nil)).Elem())
+
+	res := make([]string, 0, len(s.fields))
+	for _, f := range s.fields {
+		res = append(res, f.name)
+	}
+	return res
+}
+
+// SkipKeysOf returns a skipper for AttributeSetAll that skips the key attributes of the PrimaryIndex P.
+// It is useful for the items without key tags, since the key attributes can't be updated.
+func SkipKeysOf[P PrimaryIndex]() func(name string) bool {
+	names := keyNames[P]()
+	return func(name string) bool {
+		for _, n := range names {
+			if n == name {
+				return true
+			}
+		}
+		return false
+	}
+}
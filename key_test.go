@@ -0,0 +1,129 @@
+package dorm
+
+import (
+	"context"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/expression"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestKeyOf(t *testing.T) {
+	t.Parallel()
+
+	o := testItem{HashKey: "hash", GSIHashKey: "gsi_hash", GSIRangeKey: "gsi_range"}
+
+	key, err := KeyOf(o)
+	assert.NoError(t, err)
+	assert.Equal(t, Key{"hash_key": &types.AttributeValueMemberS{Value: "hash"}}, key)
+
+	built, err := buildIndex[PrimaryIndex](key)
+	assert.NoError(t, err)
+	want, err := buildIndex(testItemPrimaryIndex{HashKey: "hash"})
+	assert.NoError(t, err)
+	assert.Equal(t, want, built)
+
+	gsi, err := GSIKeyOf(o, testItemIndexName.GSI)
+	assert.NoError(t, err)
+	assert.Equal(t, GSIKey{
+		"gsi_hash_key":  &types.AttributeValueMemberS{Value: "gsi_hash"},
+		"gsi_range_key": &types.AttributeValueMemberS{Value: "gsi_range"},
+	}, gsi)
+
+	_, err = GSIKeyOf(o, "unknown")
+	assert.Error(t, err)
+
+	_, err = KeyOf(utilTestItem{})
+	assert.Error(t, err)
+}
+
+func TestValidateIndex(t *testing.T) {
+	t.Parallel()
+
+	assert.NoError(t, ValidateIndex[testItem, testItemPrimaryIndex]())
+	assert.NoError(t, ValidateIndex[testItem, testItemGSI]())
+	assert.ErrorIs(t, ValidateIndex[testItem, keyTestWrongPrimaryIndex](), ErrKeyMismatch)
+	assert.ErrorIs(t, ValidateIndex[testItem, keyTestWrongGSI](), ErrKeyMismatch)
+}
+
+func testtestItemKeyMismatch(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		op      func(ctx context.Context, db DynamoDBAPI, o testItem) error
+		wantErr error
+	}{
+		"get with KeyOf": {
+			op: func(ctx context.Context, db DynamoDBAPI, o testItem) error {
+				key, err := KeyOf(o)
+				assert.NoError(t, err)
+				got, err := GetItem[testItem](ctx, db, key, expression.Expression{})
+				if err == nil {
+					assert.Equal(t, o.Str, got.Str)
+				}
+				return err
+			},
+		},
+		"get with wrong index": {
+			op: func(ctx context.Context, db DynamoDBAPI, o testItem) error {
+				_, err := GetItem[testItem](ctx, db, keyTestWrongPrimaryIndex{Str: o.Str}, expression.Expression{})
+				return err
+			},
+			wantErr: ErrKeyMismatch,
+		},
+		"delete with extra attribute": {
+			op: func(ctx context.Context, db DynamoDBAPI, o testItem) error {
+				key, err := KeyOf(o)
+				assert.NoError(t, err)
+				key[testItemColumns.Str] = &types.AttributeValueMemberS{Value: o.Str}
+				_, err = DeleteItem[testItem](ctx, db, key, expression.Expression{})
+				return err
+			},
+			wantErr: ErrKeyMismatch,
+		},
+		"put without hash key": {
+			op: func(ctx context.Context, db DynamoDBAPI, o testItem) error {
+				o.HashKey = ""
+				_, err := PutItem(ctx, db, o, expression.Expression{})
+				return err
+			},
+			wantErr: ErrKeyMismatch,
+		},
+	}
+	for name, tt := range tests {
+		tt := tt
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			ctx := context.Background()
+
+			db, err := ddbMain.conn()
+			assert.NoError(t, err)
+
+			// randomize
+			o := testItem{}
+			err = RandomizeDDBStruct(&o)
+			assert.NoError(t, err)
+
+			_, err = PutItem(ctx, db, o, expression.Expression{})
+			assert.NoError(t, err)
+
+			err = tt.op(ctx, db, o)
+			if tt.wantErr == nil {
+				assert.NoError(t, err)
+				return
+			}
+			assert.ErrorIs(t, err, tt.wantErr)
+		})
+	}
+}
+
+type keyTestWrongPrimaryIndex struct {
+	PrimaryIndex `dynamodbav:"-"`
+	Str          string `dynamodbav:"str"`
+}
+
+type keyTestWrongGSI struct {
+	GlobalSecondaryIndex `dynamodbav:"-"`
+	GSIHashKey           string `dynamodbav:"gsi_hash_key"`
+}
@@ -0,0 +1,457 @@
+package dorm
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/expression"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/cockroachdb/errors"
+)
+
+// MigrationAPI is the subset of the DynamoDB API used by MigrateTable.
+//
+// *dynamodb.Client satisfies this interface.
+type MigrationAPI interface {
+	TableAPI
+	UpdateTable(ctx context.Context, params *dynamodb.UpdateTableInput, optFns ...func(*dynamodb.Options)) (*dynamodb.UpdateTableOutput, error)
+}
+
+var _ MigrationAPI = (*dynamodb.Client)(nil)
+
+// MigrationStep is a change of a table made by MigrateTable. Either UpdateTable or UpdateTimeToLive is set.
+type MigrationStep struct {
+	// Description describes the change, such as "create GSI by-email".
+	Description      string
+	UpdateTable      *dynamodb.UpdateTableInput
+	UpdateTimeToLive *dynamodb.UpdateTimeToLiveInput
+}
+
+// MigrationRecorder records the migrations applied by MigrateTable.
+type MigrationRecorder interface {
+	// Applied reports whether the migration id of the table has been applied.
+	Applied(ctx context.Context, tableName, id string) (bool, error)
+	// Record records that the migration id of the table has been applied with the steps.
+	Record(ctx context.Context, tableName, id string, steps []MigrationStep) error
+}
+
+// MigrateOptions options for MigrateTable and PlanMigration functions
+type MigrateOptions struct {
+	// BillingMode and ProvisionedThroughput are the settings of the table, as in CreateTableOptions.
+	// If BillingMode is not set and the TableConfigurer of V doesn't set it either, the billing mode and the throughput
+	// of the table are left as they are.
+	BillingMode           types.BillingMode
+	ProvisionedThroughput *types.ProvisionedThroughput
+	// TimeToLive is the attribute name of the TTL of the table. TTL is disabled if it is empty.
+	// If it is nil, it is the field tagged with `dorm:"ttl"`, and TTL is left as it is if there is no such field.
+	TimeToLive *string
+	// Recorder records the applied migrations. If it is nil, the migrations are not recorded.
+	Recorder MigrationRecorder
+	// WaitInterval is the interval of DescribeTable calls while waiting for the table to be ACTIVE.
+	WaitInterval time.Duration
+}
+
+// MigrateOptionFunc MigrateTable option function
+type MigrateOptionFunc func(*MigrateOptions)
+
+// WithMigrateBillingMode sets the BillingMode for MigrateOptions.
+func WithMigrateBillingMode(mode types.BillingMode) MigrateOptionFunc {
+	return func(opts *MigrateOptions) {
+		opts.BillingMode = mode
+	}
+}
+
+// WithMigrateProvisionedThroughput sets the BillingMode to PROVISIONED and the ProvisionedThroughput for MigrateOptions.
+func WithMigrateProvisionedThroughput(read, write int64) MigrateOptionFunc {
+	return func(opts *MigrateOptions) {
+		opts.BillingMode = types.BillingModeProvisioned
+		opts.ProvisionedThroughput = &types.ProvisionedThroughput{
+			ReadCapacityUnits:  aws.Int64(read),
+			WriteCapacityUnits: aws.Int64(write),
+		}
+	}
+}
+
+// WithMigrateTimeToLive sets the TimeToLive for MigrateOptions.
+func WithMigrateTimeToLive(attributeName string) MigrateOptionFunc {
+	return func(opts *MigrateOptions) {
+		opts.TimeToLive = &attributeName
+	}
+}
+
+// WithMigrateRecorder sets the Recorder for MigrateOptions.
+func WithMigrateRecorder(recorder MigrationRecorder) MigrateOptionFunc {
+	return func(opts *MigrateOptions) {
+		opts.Recorder = recorder
+	}
+}
+
+// WithMigrateWaitInterval sets the WaitInterval for MigrateOptions.
+func WithMigrateWaitInterval(interval time.Duration) MigrateOptionFunc {
+	return func(opts *MigrateOptions) {
+		opts.WaitInterval = interval
+	}
+}
+
+// migrateOptions applies opts to the default MigrateOptions of V.
+func migrateOptions[V ItemType](opts []MigrateOptionFunc) MigrateOptions {
+	o := MigrateOptions{}
+	for _, f := range opts {
+		f(&o)
+	}
+	if ttl := timeToLiveOf[V](); o.TimeToLive == nil && ttl != "" {
+		o.TimeToLive = &ttl
+	}
+	return o
+}
+
+func (o MigrateOptions) createTableOptions() []CreateTableOptionFunc {
+	return []CreateTableOptionFunc{func(opts *CreateTableOptions) {
+		opts.BillingMode = o.BillingMode
+		opts.ProvisionedThroughput = o.ProvisionedThroughput
+	}}
+}
+
+// PlanMigration returns the steps that MigrateTable would apply to move the table of V to the schema declared on V,
+// without changing the table.
+func PlanMigration[V ItemType](ctx context.Context, db TableAPI, opts ...MigrateOptionFunc) ([]MigrationStep, error) {
+	o := migrateOptions[V](opts)
+	input, err := declaredTableInput[V](o.createTableOptions()...)
+	if err != nil {
+		return nil, err
+	}
+	return planMigration[V](ctx, db, input, o.TimeToLive)
+}
+
+// MigrateTable moves the table of V to the schema that CreateTable builds from the dorm tags of V, and returns the applied steps.
+//
+// It drops the GSIs that are not declared, recreates the ones whose keys or projections have changed, creates the missing ones,
+// and updates the billing mode, the throughput, the stream and the TTL. The billing mode and the throughput are changed only if
+// they are given by the options or set by the TableConfigurer of V, and the stream only if the TableConfigurer sets StreamSpecification.
+// TTL is changed only if it is given by the options or V has a field tagged with `dorm:"ttl"`. Since DynamoDB allows only one GSI to be created or deleted at a time, it waits until the table and
+// all of its GSIs are ACTIVE before each step. The changes of the key schema and the LSIs can't be made by UpdateTable,
+// so it returns ErrSchemaDrift for them without changing the table.
+//
+// If a Recorder is set, the migration is recorded by the digest of the declared schema, and MigrateTable returns
+// without calling DynamoDB once the same schema has been applied. If a step fails, the migration is not recorded,
+// and the next run continues from the current state of the table.
+func MigrateTable[V ItemType](ctx context.Context, db MigrationAPI, opts ...MigrateOptionFunc) ([]MigrationStep, error) {
+	o := migrateOptions[V](opts)
+	input, err := declaredTableInput[V](o.createTableOptions()...)
+	if err != nil {
+		return nil, err
+	}
+	tableName := aws.ToString(input.TableName)
+	id, err := migrationID(input, o.TimeToLive)
+	if err != nil {
+		return nil, err
+	}
+
+	if o.Recorder != nil {
+		applied, err := o.Recorder.Applied(ctx, tableName, id)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to read the migrations of %s", tableName)
+		}
+		if applied {
+			return nil, nil
+		}
+	}
+
+	wait := func() error {
+		_, err := WaitUntilActive[V](ctx, db, WithWaitInterval(o.WaitInterval))
+		return err
+	}
+	if err := wait(); err != nil {
+		return nil, err
+	}
+	steps, err := planMigration[V](ctx, db, input, o.TimeToLive)
+	if err != nil {
+		return nil, err
+	}
+
+	for i, s := range steps {
+		if s.UpdateTable != nil {
+			if _, err := db.UpdateTable(ctx, s.UpdateTable); err != nil {
+				return steps[:i], errors.Wrapf(newOperationError("UpdateTable", input.TableName, nil, err), "failed to %s", s.Description)
+			}
+		}
+		if s.UpdateTimeToLive != nil {
+			if _, err := db.UpdateTimeToLive(ctx, s.UpdateTimeToLive); err != nil {
+				return steps[:i], errors.Wrapf(newOperationError("UpdateTimeToLive", input.TableName, nil, err), "failed to %s", s.Description)
+			}
+		}
+		if err := wait(); err != nil {
+			return steps[:i+1], err
+		}
+	}
+
+	if o.Recorder != nil {
+		if err := o.Recorder.Record(ctx, tableName, id, steps); err != nil {
+			return steps, errors.Wrapf(err, "failed to record the migration of %s", tableName)
+		}
+	}
+	return steps, nil
+}
+
+// migrationID returns the digest of the declared schema of a table.
+func migrationID(input *dynamodb.CreateTableInput, ttl *string) (string, error) {
+	b, err := json.Marshal(struct {
+		Table      *dynamodb.CreateTableInput
+		TimeToLive *string
+	}{input, ttl})
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// planMigration compares the table of V with input and ttl, and returns the steps to apply in order.
+// The billing mode is left as it is if input has none, and TTL if ttl is nil.
+func planMigration[V ItemType](ctx context.Context, db TableAPI, input *dynamodb.CreateTableInput, ttl *string) ([]MigrationStep, error) {
+	desc, err := DescribeTable[V](ctx, db)
+	if err != nil {
+		return nil, err
+	}
+	ttlResp, err := db.DescribeTimeToLive(ctx, &dynamodb.DescribeTimeToLiveInput{TableName: input.TableName})
+	if err != nil {
+		return nil, newOperationError("DescribeTimeToLive", input.TableName, nil, err)
+	}
+
+	// The key schema and the LSIs are fixed when the table is created
+	fixed := diffKeySchema("", input.KeySchema, desc.KeySchema)
+	fixed = append(fixed, diffAttributeTypes(input.AttributeDefinitions, desc.AttributeDefinitions)...)
+	fixed = append(fixed, diffIndexes(wantLocalIndexes(input), gotLocalIndexes(desc))...)
+	if len(fixed) > 0 {
+		return nil, errors.Wrap(&SchemaDriftError{TableName: aws.ToString(input.TableName), Differences: fixed}, "the table can't be migrated")
+	}
+
+	var steps []MigrationStep
+	update := func(description string, f func(*dynamodb.UpdateTableInput)) {
+		in := &dynamodb.UpdateTableInput{TableName: input.TableName}
+		f(in)
+		steps = append(steps, MigrationStep{Description: description, UpdateTable: in})
+	}
+
+	// Drop the undeclared GSIs and the changed ones first, so that they are recreated below
+	wantGSIs := map[string]types.GlobalSecondaryIndex{}
+	for _, g := range input.GlobalSecondaryIndexes {
+		wantGSIs[aws.ToString(g.IndexName)] = g
+	}
+	var dropped []string
+	for _, d := range diffIndexes(wantGlobalIndexes(input), gotGlobalIndexes(desc)) {
+		// A changed index may have differences of both its keys and its projection
+		if d.Kind != SchemaDriftMissingIndex && (len(dropped) == 0 || dropped[len(dropped)-1] != d.Index) {
+			dropped = append(dropped, d.Index)
+		}
+	}
+	kept := map[string]types.GlobalSecondaryIndexDescription{}
+	for _, g := range desc.GlobalSecondaryIndexes {
+		kept[aws.ToString(g.IndexName)] = g
+	}
+	for _, name := range dropped {
+		name := name
+		delete(kept, name)
+		update("delete GSI "+name, func(in *dynamodb.UpdateTableInput) {
+			in.GlobalSecondaryIndexUpdates = []types.GlobalSecondaryIndexUpdate{
+				{Delete: &types.DeleteGlobalSecondaryIndexAction{IndexName: aws.String(name)}},
+			}
+		})
+	}
+
+	// Billing mode and throughput of the table and the kept GSIs
+	gotMode := types.BillingModeProvisioned
+	if desc.BillingModeSummary != nil && desc.BillingModeSummary.BillingMode != "" {
+		gotMode = desc.BillingModeSummary.BillingMode
+	}
+	provisioned := input.BillingMode == types.BillingModeProvisioned
+	// New GSIs of a provisioned table get the throughput of the table, unless the billing mode is declared
+	newGSIThroughput := func(g types.GlobalSecondaryIndex) *types.ProvisionedThroughput {
+		if g.ProvisionedThroughput != nil || input.BillingMode != "" || gotMode != types.BillingModeProvisioned || desc.ProvisionedThroughput == nil {
+			return g.ProvisionedThroughput
+		}
+		return &types.ProvisionedThroughput{
+			ReadCapacityUnits:  desc.ProvisionedThroughput.ReadCapacityUnits,
+			WriteCapacityUnits: desc.ProvisionedThroughput.WriteCapacityUnits,
+		}
+	}
+	var gsiThroughput []types.GlobalSecondaryIndexUpdate
+	if provisioned {
+		for _, name := range sortedGSINames(kept) {
+			if gotMode != types.BillingModeProvisioned || !sameThroughput(wantGSIs[name].ProvisionedThroughput, kept[name].ProvisionedThroughput) {
+				gsiThroughput = append(gsiThroughput, types.GlobalSecondaryIndexUpdate{Update: &types.UpdateGlobalSecondaryIndexAction{
+					IndexName:             aws.String(name),
+					ProvisionedThroughput: wantGSIs[name].ProvisionedThroughput,
+				}})
+			}
+		}
+	}
+	switch {
+	case input.BillingMode != "" && input.BillingMode != gotMode:
+		update(fmt.Sprintf("change billing mode to %s", input.BillingMode), func(in *dynamodb.UpdateTableInput) {
+			in.BillingMode = input.BillingMode
+			in.ProvisionedThroughput = input.ProvisionedThroughput
+			in.GlobalSecondaryIndexUpdates = gsiThroughput
+		})
+	case provisioned && (!sameThroughput(input.ProvisionedThroughput, desc.ProvisionedThroughput) || len(gsiThroughput) > 0):
+		update("change provisioned throughput", func(in *dynamodb.UpdateTableInput) {
+			if !sameThroughput(input.ProvisionedThroughput, desc.ProvisionedThroughput) {
+				in.ProvisionedThroughput = input.ProvisionedThroughput
+			}
+			in.GlobalSecondaryIndexUpdates = gsiThroughput
+		})
+	}
+
+	// Create the missing GSIs one by one
+	for _, g := range input.GlobalSecondaryIndexes {
+		if _, ok := kept[aws.ToString(g.IndexName)]; ok {
+			continue
+		}
+		g := g
+		update("create GSI "+aws.ToString(g.IndexName), func(in *dynamodb.UpdateTableInput) {
+			in.AttributeDefinitions = attributeDefinitionsOf(input.AttributeDefinitions, input.KeySchema, g.KeySchema)
+			in.GlobalSecondaryIndexUpdates = []types.GlobalSecondaryIndexUpdate{{Create: &types.CreateGlobalSecondaryIndexAction{
+				IndexName:             g.IndexName,
+				KeySchema:             g.KeySchema,
+				Projection:            g.Projection,
+				ProvisionedThroughput: newGSIThroughput(g),
+			}}}
+		})
+	}
+
+	// The stream is changed only if it is declared
+	if want := input.StreamSpecification; want != nil {
+		got := desc.StreamSpecification
+		gotEnabled := got != nil && aws.ToBool(got.StreamEnabled)
+		wantEnabled := aws.ToBool(want.StreamEnabled)
+		if gotEnabled && (!wantEnabled || got.StreamViewType != want.StreamViewType) {
+			update("disable stream", func(in *dynamodb.UpdateTableInput) {
+				in.StreamSpecification = &types.StreamSpecification{StreamEnabled: aws.Bool(false)}
+			})
+		}
+		if wantEnabled && (!gotEnabled || got.StreamViewType != want.StreamViewType) {
+			update(fmt.Sprintf("enable stream of %s", want.StreamViewType), func(in *dynamodb.UpdateTableInput) {
+				in.StreamSpecification = want
+			})
+		}
+	}
+
+	if gotTTL := timeToLiveAttribute(ttlResp.TimeToLiveDescription); ttl != nil && gotTTL != *ttl {
+		if gotTTL != "" {
+			steps = append(steps, MigrationStep{
+				Description:      "disable TTL on " + gotTTL,
+				UpdateTimeToLive: timeToLiveInput(input.TableName, gotTTL, false),
+			})
+		}
+		if *ttl != "" {
+			steps = append(steps, MigrationStep{
+				Description:      "enable TTL on " + *ttl,
+				UpdateTimeToLive: timeToLiveInput(input.TableName, *ttl, true),
+			})
+		}
+	}
+
+	return steps, nil
+}
+
+func timeToLiveInput(tableName *string, attributeName string, enabled bool) *dynamodb.UpdateTimeToLiveInput {
+	return &dynamodb.UpdateTimeToLiveInput{
+		TableName: tableName,
+		TimeToLiveSpecification: &types.TimeToLiveSpecification{
+			AttributeName: aws.String(attributeName),
+			Enabled:       aws.Bool(enabled),
+		},
+	}
+}
+
+func sortedGSINames(m map[string]types.GlobalSecondaryIndexDescription) []string {
+	names := make([]string, 0, len(m))
+	for name := range m {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// sameThroughput reports whether the throughput of the table or an index is already want.
+func sameThroughput(want *types.ProvisionedThroughput, got *types.ProvisionedThroughputDescription) bool {
+	if want == nil {
+		return true
+	}
+	return got != nil &&
+		aws.ToInt64(want.ReadCapacityUnits) == aws.ToInt64(got.ReadCapacityUnits) &&
+		aws.ToInt64(want.WriteCapacityUnits) == aws.ToInt64(got.WriteCapacityUnits)
+}
+
+// attributeDefinitionsOf returns the definitions of the attributes in the key schemas.
+func attributeDefinitionsOf(defs []types.AttributeDefinition, keySchemas ...[]types.KeySchemaElement) []types.AttributeDefinition {
+	used := map[string]bool{}
+	for _, ks := range keySchemas {
+		for _, e := range ks {
+			used[aws.ToString(e.AttributeName)] = true
+		}
+	}
+	var res []types.AttributeDefinition
+	for _, d := range defs {
+		if used[aws.ToString(d.AttributeName)] {
+			res = append(res, d)
+		}
+	}
+	return res
+}
+
+// MigrationRecord is an applied migration stored by TableMigrationRecorder.
+// Create its table with CreateTable[MigrationRecord] before the first migration.
+type MigrationRecord struct {
+	Item `dynamodbav:"-"`
+	// Table is the name of the migrated table.
+	Table string `dynamodbav:"table" dorm:"hash"`
+	// ID is the digest of the schema the table was migrated to.
+	ID string `dynamodbav:"id" dorm:"range"`
+	// Steps are the descriptions of the applied steps.
+	Steps     []string  `dynamodbav:"steps,omitempty"`
+	AppliedAt time.Time `dynamodbav:"applied_at" dorm:"created_at"`
+}
+
+// TableName returns the name of the table of the migrations.
+func (MigrationRecord) TableName() string { return "dorm-migrations" }
+
+// TableMigrationRecorder is a MigrationRecorder that stores MigrationRecord items in DynamoDB.
+type TableMigrationRecorder struct {
+	db DynamoDBAPI
+}
+
+// NewTableMigrationRecorder creates a TableMigrationRecorder that stores the migrations with db.
+func NewTableMigrationRecorder(db DynamoDBAPI) *TableMigrationRecorder {
+	return &TableMigrationRecorder{db: db}
+}
+
+// Applied reports whether the MigrationRecord of the migration exists.
+func (r *TableMigrationRecorder) Applied(ctx context.Context, tableName, id string) (bool, error) {
+	key, err := KeyOf(MigrationRecord{Table: tableName, ID: id})
+	if err != nil {
+		return false, err
+	}
+	_, err = GetItem[MigrationRecord](ctx, r.db, key, expression.Expression{})
+	if errors.Is(err, ErrItemNotFound) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// Record puts the MigrationRecord of the migration.
+func (r *TableMigrationRecorder) Record(ctx context.Context, tableName, id string, steps []MigrationStep) error {
+	record := MigrationRecord{Table: tableName, ID: id}
+	for _, s := range steps {
+		record.Steps = append(record.Steps, s.Description)
+	}
+	_, err := PutItem(ctx, r.db, record, expression.Expression{})
+	return err
+}
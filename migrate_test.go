@@ -0,0 +1,132 @@
+package dorm
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func testMigrateTable(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+
+	db, err := ddbMain.conn()
+	assert.NoError(t, err)
+
+	_, err = CreateTable[migrateTestLegacyItem](ctx, db, WithCreateTableProvisionedThroughput(5, 5))
+	assert.NoError(t, err)
+	_, err = CreateTable[MigrationRecord](ctx, db)
+	assert.NoError(t, err)
+
+	steps, err := PlanMigration[migrateTestItem](ctx, db, WithMigrateTimeToLive("expires_at"))
+	assert.NoError(t, err)
+	assert.Equal(t, []string{
+		"delete GSI by-name",
+		"delete GSI by-status",
+		"create GSI by-email",
+		"create GSI by-status",
+		"enable stream of NEW_IMAGE",
+		"enable TTL on expires_at",
+	}, migrateTestDescriptions(steps))
+	// The billing mode is left as it is, so the new GSIs get the throughput of the table
+	assert.Equal(t, &types.ProvisionedThroughput{ReadCapacityUnits: aws.Int64(5), WriteCapacityUnits: aws.Int64(5)},
+		steps[2].UpdateTable.GlobalSecondaryIndexUpdates[0].Create.ProvisionedThroughput)
+
+	recorder := NewTableMigrationRecorder(db)
+	opts := []MigrateOptionFunc{
+		WithMigrateTimeToLive("expires_at"),
+		WithMigrateRecorder(recorder),
+		WithMigrateWaitInterval(10 * time.Millisecond),
+	}
+	applied, err := MigrateTable[migrateTestItem](ctx, db, opts...)
+	assert.NoError(t, err)
+	assert.Equal(t, migrateTestDescriptions(steps), migrateTestDescriptions(applied))
+
+	assert.NoError(t, CheckTable[migrateTestItem](ctx, db, WithCheckTableTimeToLive("expires_at")))
+	desc, err := DescribeTable[migrateTestItem](ctx, db)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(5), aws.ToInt64(desc.ProvisionedThroughput.ReadCapacityUnits))
+	assert.True(t, aws.ToBool(desc.StreamSpecification.StreamEnabled))
+
+	// The migration is recorded, so the rerun does nothing
+	applied, err = MigrateTable[migrateTestItem](ctx, db, opts...)
+	assert.NoError(t, err)
+	assert.Empty(t, applied)
+
+	// Without the recorder, the rerun finds nothing to change
+	applied, err = MigrateTable[migrateTestItem](ctx, db, WithMigrateTimeToLive("expires_at"), WithMigrateWaitInterval(10*time.Millisecond))
+	assert.NoError(t, err)
+	assert.Empty(t, applied)
+
+	// Neither the billing mode nor TTL is changed unless they are given or declared
+	steps, err = PlanMigration[migrateTestItem](ctx, db)
+	assert.NoError(t, err)
+	assert.Empty(t, steps)
+
+	steps, err = PlanMigration[migrateTestItem](ctx, db, WithMigrateBillingMode(types.BillingModePayPerRequest), WithMigrateTimeToLive(""))
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"change billing mode to PAY_PER_REQUEST", "disable TTL on expires_at"}, migrateTestDescriptions(steps))
+
+	steps, err = PlanMigration[migrateTestItem](ctx, db, WithMigrateProvisionedThroughput(10, 5))
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"change provisioned throughput"}, migrateTestDescriptions(steps))
+	assert.Len(t, steps[0].UpdateTable.GlobalSecondaryIndexUpdates, 2)
+
+	_, err = PlanMigration[migrateTestRekeyedItem](ctx, db)
+	assert.ErrorIs(t, err, ErrSchemaDrift)
+}
+
+func migrateTestDescriptions(steps []MigrationStep) []string {
+	res := make([]string, len(steps))
+	for i, s := range steps {
+		res[i] = s.Description
+	}
+	return res
+}
+
+// migrateTestLegacyItem is the item test-migrate is created from.
+type migrateTestLegacyItem struct {
+	Item    `dynamodbav:"-"`
+	UserID  string `dynamodbav:"user_id" dorm:"hash"`
+	Created int64  `dynamodbav:"created" dorm:"range"`
+	Name    string `dynamodbav:"name" dorm:"gsi=by-name:hash"`
+	Status  string `dynamodbav:"status" dorm:"gsi=by-status:hash"`
+}
+
+func (i migrateTestLegacyItem) TableName() string { return "test-migrate" }
+
+// migrateTestItem is the item test-migrate is migrated to.
+type migrateTestItem struct {
+	Item      `dynamodbav:"-"`
+	UserID    string `dynamodbav:"user_id" dorm:"hash"`
+	Created   int64  `dynamodbav:"created" dorm:"range"`
+	Email     string `dynamodbav:"email" dorm:"gsi=by-email:hash"`
+	Status    string `dynamodbav:"status" dorm:"gsi=by-status:hash"`
+	ExpiresAt int64  `dynamodbav:"expires_at"`
+}
+
+func (i migrateTestItem) TableName() string { return "test-migrate" }
+
+func (i migrateTestItem) ConfigureTable(input *dynamodb.CreateTableInput) {
+	input.StreamSpecification = &types.StreamSpecification{StreamEnabled: aws.Bool(true), StreamViewType: types.StreamViewTypeNewImage}
+	// by-status is recreated with the new projection
+	for j := range input.GlobalSecondaryIndexes {
+		if aws.ToString(input.GlobalSecondaryIndexes[j].IndexName) == "by-status" {
+			input.GlobalSecondaryIndexes[j].Projection = &types.Projection{ProjectionType: types.ProjectionTypeKeysOnly}
+		}
+	}
+}
+
+// migrateTestRekeyedItem changes the range key, which can't be migrated.
+type migrateTestRekeyedItem struct {
+	Item   `dynamodbav:"-"`
+	UserID string `dynamodbav:"user_id" dorm:"hash"`
+	SK     string `dynamodbav:"sk" dorm:"range"`
+}
+
+func (i migrateTestRekeyedItem) TableName() string { return "test-migrate" }
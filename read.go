@@ -2,12 +2,13 @@ package dorm
 
 import (
 	"context"
+	"sort"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
-	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
 	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/expression"
 	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
 	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/cockroachdb/errors"
 )
 
 const batchGetItemsMaxSize = 100
@@ -19,6 +20,9 @@ type QueryOptions struct {
 
 	Limit   *int32
 	Reverse bool
+	// SkipExpired filters out the items whose attribute tagged with `dorm:"ttl"` has passed,
+	// since DynamoDB deletes expired items lazily.
+	SkipExpired bool
 }
 
 // ScanOptions Scan options for Scan function
@@ -27,17 +31,34 @@ type ScanOptions struct {
 	ExclusiveStartKey map[string]types.AttributeValue
 
 	Limit *int32
+	// SkipExpired filters out the items whose attribute tagged with `dorm:"ttl"` has passed,
+	// since DynamoDB deletes expired items lazily.
+	SkipExpired bool
+}
+
+// GetItemOptions GetItem options for GetItem function
+type GetItemOptions struct {
+	// SkipExpired returns ErrItemNotFound if the attribute tagged with `dorm:"ttl"` has passed,
+	// since DynamoDB deletes expired items lazily. The projection must include the attribute.
+	SkipExpired bool
 }
 
 // BatchGetItemOptions BatchGetItem options for BatchGetItem function
 type BatchGetItemOptions struct {
 	Concurrency int
+	// MaxAttempts is the maximum number of BatchGetItem calls per batch while unprocessed keys remain.
+	// If it is 0 or less, 5 is used.
+	MaxAttempts int
+	// MissingKeys receives the requested keys whose items do not exist, if set.
+	MissingKeys *[]PrimaryIndex
 }
 
 // ScanOptionFunc Scan option function
 type ScanOptionFunc func(*ScanOptions)
 // QueryOptionFunc Query option function
 type QueryOptionFunc func(*QueryOptions)
+// GetItemOptionFunc GetItem option function
+type GetItemOptionFunc func(*GetItemOptions)
 // BatchGetItemOptionFunc BatchGetItem option function
 type BatchGetItemOptionFunc func(*BatchGetItemOptions)
 
@@ -69,6 +90,13 @@ func WithReverse(reverse bool) QueryOptionFunc {
     }
 }
 
+// WithSkipExpired sets the SkipExpired flag for QueryOptions.
+func WithSkipExpired() QueryOptionFunc {
+	return func(opts *QueryOptions) {
+		opts.SkipExpired = true
+	}
+}
+
 // WithScanIndexName sets the IndexName for ScanOptions.
 func WithScanIndexName(name string) ScanOptionFunc {
     return func(opts *ScanOptions) {
@@ -90,6 +118,20 @@ func WithScanLimit(limit int32) ScanOptionFunc {
     }
 }
 
+// WithScanSkipExpired sets the SkipExpired flag for ScanOptions.
+func WithScanSkipExpired() ScanOptionFunc {
+	return func(opts *ScanOptions) {
+		opts.SkipExpired = true
+	}
+}
+
+// WithGetSkipExpired sets the SkipExpired flag for GetItemOptions.
+func WithGetSkipExpired() GetItemOptionFunc {
+	return func(opts *GetItemOptions) {
+		opts.SkipExpired = true
+	}
+}
+
 // WithBatchGetConcurrency sets the concurrency for BatchGetItemOptions.
 func WithBatchGetConcurrency(concurrency int) BatchGetItemOptionFunc {
 	return func(opts *BatchGetItemOptions) {
@@ -97,13 +139,36 @@ func WithBatchGetConcurrency(concurrency int) BatchGetItemOptionFunc {
 	}
 }
 
+// WithBatchGetMaxAttempts sets the MaxAttempts for BatchGetItemOptions.
+func WithBatchGetMaxAttempts(attempts int) BatchGetItemOptionFunc {
+	return func(opts *BatchGetItemOptions) {
+		opts.MaxAttempts = attempts
+	}
+}
+
+// WithBatchGetMissingKeys sets the MissingKeys for BatchGetItemOptions.
+//
+// After BatchGetItems succeeds, missing holds the requested keys that were not found.
+// The projection must include the key attributes so that the items can be matched with the keys.
+func WithBatchGetMissingKeys(missing *[]PrimaryIndex) BatchGetItemOptionFunc {
+	return func(opts *BatchGetItemOptions) {
+		opts.MissingKeys = missing
+	}
+}
+
 // GetItem retrieves the specified item.
 //
+// If V has a field tagged with `dorm:"entity=name"` and the item is of another entity type, ErrItemNotFound is returned.
+//
 // https://pkg.go.dev/github.com/aws/aws-sdk-go-v2/service/dynamodb#Client.GetItem
 // https://docs.aws.amazon.com/en_us/amazondynamodb/latest/APIReference/API_GetItem.html
-func GetItem[V ItemType](ctx context.Context, db *dynamodb.Client, idx PrimaryIndex, expr expression.Expression) (*V, error) {
+func GetItem[V ItemType](ctx context.Context, db DynamoDBAPI, idx PrimaryIndex, expr expression.Expression, opts ...GetItemOptionFunc) (*V, error) {
+	o := GetItemOptions{}
+	for _, f := range opts {
+		f(&o)
+	}
 
-	key, err := buildIndex(idx)
+	key, err := primaryKeyOf[V](idx)
 	if err != nil {
 		return nil, err
 	}
@@ -118,15 +183,24 @@ func GetItem[V ItemType](ctx context.Context, db *dynamodb.Client, idx PrimaryIn
 	output, err := db.GetItem(ctx, input)
 
 	if err != nil {
-		return nil, err
+		return nil, newOperationError("GetItem", input.TableName, key, err)
 	}
 
 	if checkEmptyResp(output.Item) {
 		return nil, ErrItemNotFound
 	}
 
+	s, err := schemaOf[V]()
+	if err != nil {
+		return nil, err
+	}
+	// The key may belong to an item of another entity type sharing the table
+	if !s.entity.matches(output.Item) || (o.SkipExpired && s.ttl.expired(output.Item, now())) {
+		return nil, ErrItemNotFound
+	}
+
 	var val V
-	err = attributevalue.UnmarshalMap(output.Item, &val)
+	err = unmarshalItem(output.Item, &val)
 	if err != nil {
 		return nil, err
 	}
@@ -139,19 +213,124 @@ func GetItem[V ItemType](ctx context.Context, db *dynamodb.Client, idx PrimaryIn
 //
 // Although AWS allows accessing multiple tables, this function is limited to a single table.
 // The maximum number of items that can be requested at once is 100.
+// Duplicate keys are requested only once, and the items are returned in no particular order.
+// Unprocessed keys are resubmitted with exponential backoff up to MaxAttempts times.
+// If some keys are still unprocessed, an *UnprocessedKeysError listing them is returned with the items that were read.
+// The keys that don't exist are reported by MissingKeys, so they can be told from the unprocessed ones.
+// If the projection leaves out the key attributes, they are added to it to match the items with the keys.
 // https://pkg.go.dev/github.com/aws/aws-sdk-go-v2/service/dynamodb#Client.BatchGetItem
 // https://docs.aws.amazon.com/en_us/amazondynamodb/latest/APIReference/API_BatchGetItem.html
-func BatchGetItems[V ItemType](ctx context.Context, db *dynamodb.Client, idxs []PrimaryIndex, expr expression.Expression, opts ...BatchGetItemOptionFunc) ([]V, error) {
+func BatchGetItems[V ItemType](ctx context.Context, db DynamoDBAPI, idxs []PrimaryIndex, expr expression.Expression, opts ...BatchGetItemOptionFunc) ([]V, error) {
+	res, err := batchGetItemsAll[V](ctx, db, idxs, expr, opts...)
+
+	if err != nil && !errors.Is(err, ErrUnprocessedItems) {
+		return nil, err
+	}
+
+	return res.items, err
+}
+
+// BatchGetItemsOrdered retrieves multiple items in a batch, aligned with idxs.
+//
+// The i-th element of the result is the item for idxs[i], or nil if it does not exist or was not processed.
+// See BatchGetItems for the other behaviors.
+func BatchGetItemsOrdered[V ItemType](ctx context.Context, db DynamoDBAPI, idxs []PrimaryIndex, expr expression.Expression, opts ...BatchGetItemOptionFunc) ([]*V, error) {
+	res, resErr := batchGetItemsAll[V](ctx, db, idxs, expr, opts...)
+
+	if resErr != nil && !errors.Is(resErr, ErrUnprocessedItems) {
+		return nil, resErr
+	}
+
+	byKey := res.byKey()
+
+	vals := make([]*V, len(idxs))
+	for i, idx := range idxs {
+		key, err := KeyString(idx)
+		if err != nil {
+			return nil, err
+		}
+		if val, ok := byKey[key]; ok {
+			val := val
+			vals[i] = &val
+		}
+	}
+
+	return vals, resErr
+}
+
+// BatchGetItemsMap retrieves multiple items in a batch, keyed by KeyString of their primary key.
+//
+// Keys whose items do not exist or were not processed are absent from the map.
+// See BatchGetItems for the other behaviors.
+func BatchGetItemsMap[V ItemType](ctx context.Context, db DynamoDBAPI, idxs []PrimaryIndex, expr expression.Expression, opts ...BatchGetItemOptionFunc) (map[string]V, error) {
+	res, err := batchGetItemsAll[V](ctx, db, idxs, expr, opts...)
+
+	if err != nil && !errors.Is(err, ErrUnprocessedItems) {
+		return nil, err
+	}
+
+	return res.byKey(), err
+}
+
+// batchGetItemsAll requests the unique keys of idxs in batches and merges the results.
+func batchGetItemsAll[V ItemType](ctx context.Context, db DynamoDBAPI, idxs []PrimaryIndex, expr expression.Expression, opts ...BatchGetItemOptionFunc) (batchGetItemsResult[V], error) {
 	o := BatchGetItemOptions{}
 
 	for _, f := range opts {
 		f(&o)
 	}
 
-	res, err := splitThreadWithReturnValue(ctx, db, expr, batchGetItemsMaxSize, o.Concurrency, batchGetItems[V], idxs)
+	// DynamoDB rejects a batch that contains duplicate keys
+	uniq, err := uniqueIndexes(idxs)
 
 	if err != nil {
-		return nil, err
+		return batchGetItemsResult[V]{}, err
+	}
+
+	results, err := splitThreadWithReturnValue(ctx, db, expr, batchGetItemsMaxSize, o.Concurrency,
+		func(ctx context.Context, db DynamoDBAPI, expr expression.Expression, idxs []PrimaryIndex) ([]batchGetItemsResult[V], error) {
+			return batchGetItems[V](ctx, db, expr, o.MaxAttempts, idxs)
+		}, uniq)
+
+	if err != nil {
+		return batchGetItemsResult[V]{}, err
+	}
+
+	res := batchGetItemsResult[V]{items: make([]V, 0, len(uniq))}
+	for _, r := range results {
+		res.items = append(res.items, r.items...)
+		res.keys = append(res.keys, r.keys...)
+		res.missing = append(res.missing, r.missing...)
+		res.unprocessed = append(res.unprocessed, r.unprocessed...)
+	}
+
+	if o.MissingKeys != nil {
+		*o.MissingKeys = res.missing
+	}
+
+	// The items read so far are returned with the error, so that the unprocessed keys can be told from the missing ones
+	if len(res.unprocessed) > 0 {
+		return res, &UnprocessedKeysError{TableName: *getFullTableName[V](), Keys: res.unprocessed}
+	}
+
+	return res, nil
+}
+
+// uniqueIndexes removes the duplicate keys from idxs, keeping the first occurrence.
+func uniqueIndexes(idxs []PrimaryIndex) ([]PrimaryIndex, error) {
+	seen := make(map[string]bool, len(idxs))
+	res := make([]PrimaryIndex, 0, len(idxs))
+
+	for _, idx := range idxs {
+		key, err := KeyString(idx)
+		if err != nil {
+			return nil, err
+		}
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		res = append(res, idx)
 	}
 
 	return res, nil
@@ -159,10 +338,12 @@ func BatchGetItems[V ItemType](ctx context.Context, db *dynamodb.Client, idxs []
 
 // Query executes a query.
 //
+// If V has a field tagged with `dorm:"entity=name"`, only the items of the entity type are returned.
+//
 // Note: According to AWS specifications, KeyCondition => Limit => FilterExpression are executed in order.
 // https://pkg.go.dev/github.com/aws/aws-sdk-go-v2/service/dynamodb#Client.Query
 // https://docs.aws.amazon.com/en_us/amazondynamodb/latest/APIReference/API_Query.html
-func Query[V ItemType](ctx context.Context, db *dynamodb.Client, expr expression.Expression, opts ...QueryOptionFunc) ([]V, map[string]types.AttributeValue, error) {
+func Query[V ItemType](ctx context.Context, db DynamoDBAPI, expr expression.Expression, opts ...QueryOptionFunc) ([]V, map[string]types.AttributeValue, error) {
 
 	o := QueryOptions{}
 
@@ -170,32 +351,29 @@ func Query[V ItemType](ctx context.Context, db *dynamodb.Client, expr expression
 		f(&o)
 	}
 
-	input := &dynamodb.QueryInput{
-		TableName:                 getFullTableName[V](),
-		ExclusiveStartKey:         o.ExclusiveStartKey,
-		ExpressionAttributeNames:  expr.Names(),
-		ExpressionAttributeValues: expr.Values(),
-		FilterExpression:          expr.Filter(),
-		IndexName:                 o.IndexName,
-		Limit:                     o.Limit,
-		KeyConditionExpression:    expr.KeyCondition(),
-		ProjectionExpression:      expr.Projection(),
-		Select:                    types.SelectSpecificAttributes,
-		ScanIndexForward:          aws.Bool(o.Reverse),
+	parts, err := entityFiltered[V](partsOf(expr))
+	if err != nil {
+		return nil, nil, err
 	}
+	if o.SkipExpired {
+		if parts, err = skipExpired[V](parts); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	input := queryInput(getFullTableName[V](), parts, o)
 
 	output, err := db.Query(ctx, input)
 
 	if err != nil {
-		return nil, nil, err
+		return nil, nil, newOperationError("Query", input.TableName, nil, err)
 	}
 
 	if checkEmptyRespList(output.Items) {
 		return []V{}, nil, nil
 	}
 
-	var vals []V
-	err = attributevalue.UnmarshalListOfMaps(output.Items, &vals)
+	vals, err := unmarshalItems[V](output.Items)
 	if err != nil {
 		return nil, nil, err
 	}
@@ -204,12 +382,28 @@ func Query[V ItemType](ctx context.Context, db *dynamodb.Client, expr expression
 
 }
 
+func queryInput(tableName *string, parts exprParts, o QueryOptions) *dynamodb.QueryInput {
+	return &dynamodb.QueryInput{
+		TableName:                 tableName,
+		ExclusiveStartKey:         o.ExclusiveStartKey,
+		ExpressionAttributeNames:  parts.Names,
+		ExpressionAttributeValues: parts.Values,
+		FilterExpression:          parts.Filter,
+		IndexName:                 o.IndexName,
+		Limit:                     o.Limit,
+		KeyConditionExpression:    parts.KeyCondition,
+		ProjectionExpression:      parts.Projection,
+		Select:                    types.SelectSpecificAttributes,
+		ScanIndexForward:          aws.Bool(o.Reverse),
+	}
+}
+
 // QueryAll executes a query to retrieve all items.
 //
 // Note: According to AWS specifications, KeyCondition => Limit => FilterExpression are executed in order.
 // https://pkg.go.dev/github.com/aws/aws-sdk-go-v2/service/dynamodb#Client.Query
 // https://docs.aws.amazon.com/en_us/amazondynamodb/latest/APIReference/API_Query.html
-func QueryAll[V ItemType](ctx context.Context, db *dynamodb.Client, expr expression.Expression, opts ...QueryOptionFunc) ([]V, error) {
+func QueryAll[V ItemType](ctx context.Context, db DynamoDBAPI, expr expression.Expression, opts ...QueryOptionFunc) ([]V, error) {
 	var resp []V
 
 	iopts := opts
@@ -240,40 +434,41 @@ func QueryAll[V ItemType](ctx context.Context, db *dynamodb.Client, expr express
 
 // Scan performs a table scan.
 //
+// If V has a field tagged with `dorm:"entity=name"`, only the items of the entity type are returned.
+//
 // Note: According to AWS specifications, Limit => FilterExpression are executed in order.
 // https://pkg.go.dev/github.com/aws/aws-sdk-go-v2/service/dynamodb#Client.Scan
 // https://docs.aws.amazon.com/en_us/amazondynamodb/latest/APIReference/API_Scan.html
-func Scan[V ItemType](ctx context.Context, db *dynamodb.Client, expr expression.Expression, opts ...ScanOptionFunc) ([]V, map[string]types.AttributeValue, error) {
+func Scan[V ItemType](ctx context.Context, db DynamoDBAPI, expr expression.Expression, opts ...ScanOptionFunc) ([]V, map[string]types.AttributeValue, error) {
 	o := ScanOptions{}
 
 	for _, f := range opts {
 		f(&o)
 	}
 
-	input := &dynamodb.ScanInput{
-		TableName:                 getFullTableName[V](),
-		ExclusiveStartKey:         o.ExclusiveStartKey,
-		ExpressionAttributeNames:  expr.Names(),
-		ExpressionAttributeValues: expr.Values(),
-		FilterExpression:          expr.Filter(),
-		IndexName:                 o.IndexName,
-		Limit:                     o.Limit,
-		ProjectionExpression:      expr.Projection(),
-		Select:                    types.SelectSpecificAttributes,
+	parts, err := entityFiltered[V](partsOf(expr))
+	if err != nil {
+		return nil, nil, err
 	}
+	if o.SkipExpired {
+		if parts, err = skipExpired[V](parts); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	input := scanInput(getFullTableName[V](), parts, o)
 
 	output, err := db.Scan(ctx, input)
 
 	if err != nil {
-		return nil, nil, err
+		return nil, nil, newOperationError("Scan", input.TableName, nil, err)
 	}
 
 	if checkEmptyRespList(output.Items) {
 		return []V{}, nil, nil
 	}
 
-	var vals []V
-	err = attributevalue.UnmarshalListOfMaps(output.Items, &vals)
+	vals, err := unmarshalItems[V](output.Items)
 	if err != nil {
 		return nil, nil, err
 	}
@@ -281,12 +476,26 @@ func Scan[V ItemType](ctx context.Context, db *dynamodb.Client, expr expression.
 	return vals, output.LastEvaluatedKey, nil
 }
 
+func scanInput(tableName *string, parts exprParts, o ScanOptions) *dynamodb.ScanInput {
+	return &dynamodb.ScanInput{
+		TableName:                 tableName,
+		ExclusiveStartKey:         o.ExclusiveStartKey,
+		ExpressionAttributeNames:  parts.Names,
+		ExpressionAttributeValues: parts.Values,
+		FilterExpression:          parts.Filter,
+		IndexName:                 o.IndexName,
+		Limit:                     o.Limit,
+		ProjectionExpression:      parts.Projection,
+		Select:                    types.SelectSpecificAttributes,
+	}
+}
+
 // ScanAll performs a table scan to retrieve all items.
 //
 // Note: According to AWS specifications, Limit => FilterExpression are executed in order.
 // https://pkg.go.dev/github.com/aws/aws-sdk-go-v2/service/dynamodb#Client.Scan
 // https://docs.aws.amazon.com/en_us/amazondynamodb/latest/APIReference/API_Scan.html
-func ScanAll[V ItemType](ctx context.Context, db *dynamodb.Client, expr expression.Expression, opts ...ScanOptionFunc) ([]V, error) {
+func ScanAll[V ItemType](ctx context.Context, db DynamoDBAPI, expr expression.Expression, opts ...ScanOptionFunc) ([]V, error) {
 	var resp []V
 
 	iopts := opts
@@ -315,10 +524,58 @@ func ScanAll[V ItemType](ctx context.Context, db *dynamodb.Client, expr expressi
 	return resp, nil
 }
 
-func batchGetItems[V ItemType](ctx context.Context, db *dynamodb.Client, expr expression.Expression, idxs []PrimaryIndex) ([]V, error) {
+// batchGetItemsResult is the result of BatchGetItem batches.
+type batchGetItemsResult[V ItemType] struct {
+	items []V
+	// keys are the KeyString of each item
+	keys        []string
+	missing     []PrimaryIndex
+	unprocessed []PrimaryIndex
+}
+
+func (r batchGetItemsResult[V]) byKey() map[string]V {
+	res := make(map[string]V, len(r.items))
+	for i, item := range r.items {
+		res[r.keys[i]] = item
+	}
+	return res
+}
+
+// keyProjectionPrefix is the placeholder prefix of the key attributes added to a projection.
+const keyProjectionPrefix = "k"
+
+// keyProjected adds the attributes of key that the projection of p leaves out, so that the returned items
+// can be matched with the requested keys. p is returned as is if it has no projection, which returns all attributes.
+func keyProjected(p exprParts, key map[string]types.AttributeValue) (exprParts, error) {
+	if p.Projection == nil {
+		return p, nil
+	}
+	projected := map[string]bool{}
+	for _, placeholder := range placeholderRegexp.FindAllString(*p.Projection, -1) {
+		projected[p.Names[placeholder]] = true
+	}
+	var names []string
+	for name := range key {
+		if !projected[name] {
+			names = append(names, name)
+		}
+	}
+	if len(names) == 0 {
+		return p, nil
+	}
+	sort.Strings(names)
+
+	proj := expression.NamesList(expression.Name(names[0]))
+	for _, name := range names[1:] {
+		proj = proj.AddNames(expression.Name(name))
+	}
+	return p.merge(keyProjectionPrefix, expression.NewBuilder().WithProjection(proj))
+}
+
+func batchGetItems[V ItemType](ctx context.Context, db DynamoDBAPI, expr expression.Expression, maxAttempts int, idxs []PrimaryIndex) ([]batchGetItemsResult[V], error) {
 
 	if len(idxs) == 0 {
-		return []V{}, nil
+		return nil, nil
 	}
 
 	if len(idxs) > 100 {
@@ -326,43 +583,69 @@ func batchGetItems[V ItemType](ctx context.Context, db *dynamodb.Client, expr ex
 	}
 
 	var keys []map[string]types.AttributeValue
+	// The returned items and the unprocessed keys are matched with the requested keys by their content
+	byKey := make(map[string]PrimaryIndex, len(idxs))
 	for _, idx := range idxs {
-		key, err := buildIndex(idx)
+		key, err := primaryKeyOf[V](idx)
 		if err != nil {
 			return nil, err
 		}
 		keys = append(keys, key)
+		byKey[attributeMapKey(key)] = idx
 	}
 
-	req := make(map[string]types.KeysAndAttributes, 0)
-	req[*getFullTableName[V]()] = types.KeysAndAttributes{
-		Keys:                     keys,
-		ExpressionAttributeNames: expr.Names(),
-		ProjectionExpression:     expr.Projection(),
+	parts, err := keyProjected(partsOf(expr), keys[0])
+	if err != nil {
+		return nil, err
 	}
 
-	input := &dynamodb.BatchGetItemInput{RequestItems: req}
-
-	output, err := db.BatchGetItem(ctx, input)
+	tableName := *getFullTableName[V]()
+	items, unprocessedKeys, err := batchGetItem(ctx, db, tableName, types.KeysAndAttributes{
+		Keys:                     keys,
+		ExpressionAttributeNames: parts.Names,
+		ProjectionExpression:     parts.Projection,
+	}, maxAttempts)
 
 	if err != nil {
 		return nil, err
 	}
 
-	if checkEmptyRespList(output.Responses[*getFullTableName[V]()]) {
-		return []V{}, nil
-	}
+	res := batchGetItemsResult[V]{items: make([]V, 0, len(items))}
+	found := make(map[string]bool, len(items))
 
-	var res []V
-
-	for _, item := range output.Responses[*getFullTableName[V]()] {
+	s, err := schemaOf[V]()
+	if err != nil {
+		return nil, err
+	}
+	for _, item := range items {
+		// The items of another entity type sharing the table are missing
+		if !s.entity.matches(item) {
+			continue
+		}
 		var val V
-		err = attributevalue.UnmarshalMap(item, &val)
+		err = unmarshalItem(item, &val)
 		if err != nil {
 			return nil, err
 		}
-		res = append(res, val)
+		key := attributeMapKey(pickAttributes(item, keys[0]))
+		res.items = append(res.items, val)
+		res.keys = append(res.keys, key)
+		found[key] = true
 	}
 
-	return res, nil
+	unprocessed := make(map[string]bool, len(unprocessedKeys))
+	for _, key := range unprocessedKeys {
+		k := attributeMapKey(key)
+		unprocessed[k] = true
+		res.unprocessed = append(res.unprocessed, byKey[k])
+	}
+
+	for _, key := range keys {
+		k := attributeMapKey(key)
+		if !found[k] && !unprocessed[k] {
+			res.missing = append(res.missing, byKey[k])
+		}
+	}
+
+	return []batchGetItemsResult[V]{res}, nil
 }
@@ -4,6 +4,8 @@ import (
 	"context"
 	"testing"
 
+	"github.com/cockroachdb/errors"
+
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/expression"
 	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
@@ -47,7 +49,7 @@ func testtestItemGetItem(t *testing.T) {
 				assert.NoError(t, err)
 
 				// put item
-				err = PutItem(args.ctx, args.db, o, expression.Expression{})
+				_, err = PutItem(args.ctx, args.db, o, expression.Expression{})
 				assert.NoError(t, err)
 
 				// set args
@@ -55,7 +57,8 @@ func testtestItemGetItem(t *testing.T) {
 					HashKey: o.HashKey,
 				}
 
-				proj := ProjectionAll[testItem]()
+				proj, err := ProjectionAll[testItem]()
+				assert.NoError(t, err)
 				args.expr, err = expression.NewBuilder().WithProjection(proj).Build()
 				assert.NoError(t, err)
 
@@ -89,7 +92,8 @@ func testtestItemGetItem(t *testing.T) {
 					HashKey: o.HashKey,
 				}
 
-				proj := ProjectionAll[testItem]()
+				proj, err := ProjectionAll[testItem]()
+				assert.NoError(t, err)
 				args.expr, err = expression.NewBuilder().WithProjection(proj).Build()
 				assert.NoError(t, err)
 				return nil
@@ -124,10 +128,11 @@ func testtestItemBatchGetItems(t *testing.T) {
 
 	type args struct {
 		ctx  context.Context
-		db   *dynamodb.Client
+		db   DynamoDBAPI
 		idxs []PrimaryIndex
 
 		expr expression.Expression
+		opts []BatchGetItemOptionFunc
 	}
 	tests := map[string]struct {
 		args args
@@ -136,7 +141,7 @@ func testtestItemBatchGetItems(t *testing.T) {
 		wantErr    bool
 		setup      func(t *testing.T, args *args) []testItem
 		opts       []cmp.Option
-		selfAssert []func(t *testing.T, args args, want []testItem)
+		selfAssert []func(t *testing.T, args args, want []testItem, err error)
 	}{
 		"success": {
 			args: args{
@@ -159,7 +164,7 @@ func testtestItemBatchGetItems(t *testing.T) {
 					assert.NoError(t, err)
 
 					// put item
-					err = PutItem(args.ctx, args.db, o, expression.Expression{})
+					_, err = PutItem(args.ctx, args.db, o, expression.Expression{})
 					assert.NoError(t, err)
 
 					// add indices
@@ -174,7 +179,8 @@ func testtestItemBatchGetItems(t *testing.T) {
 
 				args.idxs = indices
 
-				proj := ProjectionAll[testItem]()
+				proj, err := ProjectionAll[testItem]()
+				assert.NoError(t, err)
 				args.expr, err = expression.NewBuilder().WithProjection(proj).Build()
 				assert.NoError(t, err)
 				return want
@@ -207,7 +213,8 @@ func testtestItemBatchGetItems(t *testing.T) {
 					testItemPrimaryIndex{HashKey: o.HashKey},
 				}
 
-				proj := ProjectionAll[testItem]()
+				proj, err := ProjectionAll[testItem]()
+				assert.NoError(t, err)
 				args.expr, err = expression.NewBuilder().WithProjection(proj).Build()
 				assert.NoError(t, err)
 
@@ -221,13 +228,189 @@ func testtestItemBatchGetItems(t *testing.T) {
 			},
 			wantErr: false,
 		},
+		"success with unprocessed keys": {
+			args: args{
+				ctx: context.Background(),
+			},
+			setup: func(t *testing.T, args *args) (want []testItem) {
+				db, err := ddbMain.conn()
+				assert.NoError(t, err)
+
+				want = make([]testItem, 10)
+				args.idxs = make([]PrimaryIndex, len(want))
+				for i := range want {
+					// randomize
+					o := testItem{}
+					err = RandomizeDDBStruct(&o)
+					assert.NoError(t, err)
+					want[i] = o
+					args.idxs[i] = testItemPrimaryIndex{HashKey: o.HashKey}
+				}
+				err = BatchPutItem(args.ctx, db, want)
+				assert.NoError(t, err)
+
+				// the first two calls leave half of the keys unprocessed
+				args.db = &unprocessedClient{DynamoDBAPI: db, failures: 2}
+
+				return want
+			},
+			opts: []cmp.Option{
+				cmpopts.SortSlices(func(x, y testItem) bool {
+					return x.HashKey < y.HashKey
+				}),
+				cmpopts.IgnoreUnexported(testItem{}),
+			},
+		},
+		"unprocessed keys remain": {
+			args: args{
+				ctx:  context.Background(),
+				opts: []BatchGetItemOptionFunc{WithBatchGetMaxAttempts(2)},
+			},
+			setup: func(t *testing.T, args *args) (want []testItem) {
+				db, err := ddbMain.conn()
+				assert.NoError(t, err)
+
+				items := make([]testItem, 8)
+				args.idxs = make([]PrimaryIndex, len(items))
+				for i := range items {
+					// randomize
+					o := testItem{}
+					err = RandomizeDDBStruct(&o)
+					assert.NoError(t, err)
+					items[i] = o
+					args.idxs[i] = testItemPrimaryIndex{HashKey: o.HashKey}
+				}
+				err = BatchPutItem(args.ctx, db, items)
+				assert.NoError(t, err)
+
+				args.db = &unprocessedClient{DynamoDBAPI: db, failures: -1}
+
+				// the items read before the attempts run out are returned with the error
+				return items[:6]
+			},
+			opts: []cmp.Option{
+				cmpopts.SortSlices(func(x, y testItem) bool {
+					return x.HashKey < y.HashKey
+				}),
+				cmpopts.IgnoreUnexported(testItem{}),
+			},
+			wantErr: true,
+			selfAssert: []func(t *testing.T, args args, want []testItem, err error){
+				func(t *testing.T, args args, want []testItem, err error) {
+					assert.ErrorIs(t, err, ErrUnprocessedItems)
+
+					var uerr *UnprocessedKeysError
+					assert.True(t, errors.As(err, &uerr))
+					// 8 => 4 => 2 keys are left unprocessed
+					assert.ElementsMatch(t, args.idxs[6:], uerr.Keys)
+				},
+			},
+		},
+		"projection without key attributes": {
+			args: args{
+				ctx: context.Background(),
+			},
+			setup: func(t *testing.T, args *args) (want []testItem) {
+				var err error
+
+				// init db
+				args.db, err = ddbMain.conn()
+				assert.NoError(t, err)
+
+				want = make([]testItem, 2)
+				args.idxs = make([]PrimaryIndex, 4)
+				items := make([]testItem, 0, len(want))
+				for i := range args.idxs {
+					// randomize
+					o := testItem{}
+					err = RandomizeDDBStruct(&o)
+					assert.NoError(t, err)
+					args.idxs[i] = testItemPrimaryIndex{HashKey: o.HashKey}
+					// only the even keys exist
+					if i%2 == 0 {
+						items = append(items, o)
+						// the key attributes are projected to tell the missing keys
+						want[i/2] = testItem{HashKey: o.HashKey, Str: o.Str}
+					}
+				}
+				err = BatchPutItem(args.ctx, args.db, items)
+				assert.NoError(t, err)
+
+				args.expr, err = expression.NewBuilder().WithProjection(expression.NamesList(expression.Name("str"))).Build()
+				assert.NoError(t, err)
+
+				return want
+			},
+			opts: []cmp.Option{
+				cmpopts.SortSlices(func(x, y testItem) bool {
+					return x.HashKey < y.HashKey
+				}),
+				cmpopts.IgnoreUnexported(testItem{}),
+			},
+			selfAssert: []func(t *testing.T, args args, want []testItem, err error){
+				func(t *testing.T, args args, want []testItem, err error) {
+					var missing []PrimaryIndex
+					_, err = BatchGetItems[testItem](args.ctx, args.db, args.idxs, args.expr, WithBatchGetMissingKeys(&missing))
+					assert.NoError(t, err)
+					assert.ElementsMatch(t, []PrimaryIndex{args.idxs[1], args.idxs[3]}, missing)
+
+					got, err := BatchGetItemsOrdered[testItem](args.ctx, args.db, args.idxs, args.expr)
+					assert.NoError(t, err)
+					assert.Equal(t, []*testItem{&want[0], nil, &want[1], nil}, got)
+				},
+			},
+		},
+		"success with missing keys": {
+			args: args{
+				ctx: context.Background(),
+			},
+			setup: func(t *testing.T, args *args) (want []testItem) {
+				var err error
+
+				// init db
+				args.db, err = ddbMain.conn()
+				assert.NoError(t, err)
+
+				want = make([]testItem, 3)
+				args.idxs = make([]PrimaryIndex, 6)
+				for i := range args.idxs {
+					// randomize
+					o := testItem{}
+					err = RandomizeDDBStruct(&o)
+					assert.NoError(t, err)
+					args.idxs[i] = testItemPrimaryIndex{HashKey: o.HashKey}
+					// only the even keys exist
+					if i%2 == 0 {
+						want[i/2] = o
+					}
+				}
+				err = BatchPutItem(args.ctx, args.db, want)
+				assert.NoError(t, err)
+
+				return want
+			},
+			opts: []cmp.Option{
+				cmpopts.SortSlices(func(x, y testItem) bool {
+					return x.HashKey < y.HashKey
+				}),
+				cmpopts.IgnoreUnexported(testItem{}),
+			},
+			selfAssert: []func(t *testing.T, args args, want []testItem, err error){
+				func(t *testing.T, args args, want []testItem, err error) {
+					var missing []PrimaryIndex
+					_, err = BatchGetItems[testItem](args.ctx, args.db, args.idxs, args.expr, WithBatchGetMissingKeys(&missing))
+					assert.NoError(t, err)
+					assert.ElementsMatch(t, []PrimaryIndex{args.idxs[1], args.idxs[3], args.idxs[5]}, missing)
+				},
+			},
+		},
 	}
 	for name, tt := range tests {
 		tt := tt
 		t.Run(name, func(t *testing.T) {
 			t.Parallel()
 			tt.want = tt.setup(t, &tt.args)
-			got, err := BatchGetItems[testItem](tt.args.ctx, tt.args.db, tt.args.idxs, tt.args.expr)
+			got, err := BatchGetItems[testItem](tt.args.ctx, tt.args.db, tt.args.idxs, tt.args.expr, tt.args.opts...)
 			if (err != nil) != tt.wantErr {
 				t.Errorf("wantErr is %t, but err is %v", tt.wantErr, err)
 			}
@@ -235,7 +418,175 @@ func testtestItemBatchGetItems(t *testing.T) {
 				t.Errorf("Compare value is mismatch (-want +got):%s\n", diff)
 			}
 			for _, fn := range tt.selfAssert {
-				fn(t, tt.args, tt.want)
+				fn(t, tt.args, tt.want, err)
+			}
+		})
+	}
+}
+
+func testtestItemBatchGetItemsOrdered(t *testing.T) {
+	t.Parallel()
+
+	type args struct {
+		ctx  context.Context
+		db   DynamoDBAPI
+		idxs []PrimaryIndex
+
+		expr expression.Expression
+	}
+	tests := map[string]struct {
+		args args
+		want []*testItem
+
+		wantErr bool
+		setup   func(t *testing.T, args *args) []*testItem
+		opts    []cmp.Option
+	}{
+		"success with missing and duplicate keys": {
+			args: args{
+				ctx: context.Background(),
+			},
+			setup: func(t *testing.T, args *args) (want []*testItem) {
+				var err error
+
+				// init db
+				args.db, err = ddbMain.conn()
+				assert.NoError(t, err)
+
+				items := make([]testItem, 150)
+				for i := range items {
+					// randomize
+					o := testItem{}
+					err = RandomizeDDBStruct(&o)
+					assert.NoError(t, err)
+					items[i] = o
+				}
+				err = BatchPutItem(args.ctx, args.db, items)
+				assert.NoError(t, err)
+
+				// a missing key
+				missing := testItem{}
+				err = RandomizeDDBStruct(&missing)
+				assert.NoError(t, err)
+
+				for i := range items {
+					args.idxs = append(args.idxs, testItemPrimaryIndex{HashKey: items[i].HashKey})
+					want = append(want, &items[i])
+					if i%50 == 0 {
+						args.idxs = append(args.idxs, testItemPrimaryIndex{HashKey: missing.HashKey}, testItemPrimaryIndex{HashKey: items[0].HashKey})
+						want = append(want, nil, &items[0])
+					}
+				}
+
+				proj, err := ProjectionAll[testItem]()
+				assert.NoError(t, err)
+				args.expr, err = expression.NewBuilder().WithProjection(proj).Build()
+				assert.NoError(t, err)
+				return want
+			},
+			opts: []cmp.Option{
+				cmpopts.IgnoreUnexported(testItem{}),
+			},
+		},
+		"empty": {
+			args: args{
+				ctx: context.Background(),
+			},
+			setup: func(t *testing.T, args *args) (want []*testItem) {
+				var err error
+
+				// init db
+				args.db, err = ddbMain.conn()
+				assert.NoError(t, err)
+
+				return []*testItem{}
+			},
+		},
+	}
+	for name, tt := range tests {
+		tt := tt
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			tt.want = tt.setup(t, &tt.args)
+			got, err := BatchGetItemsOrdered[testItem](tt.args.ctx, tt.args.db, tt.args.idxs, tt.args.expr)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("wantErr is %t, but err is %v", tt.wantErr, err)
+			}
+			if diff := cmp.Diff(tt.want, got, tt.opts...); len(diff) > 0 {
+				t.Errorf("Compare value is mismatch (-want +got):%s\n", diff)
+			}
+		})
+	}
+}
+
+func testtestItemBatchGetItemsMap(t *testing.T) {
+	t.Parallel()
+
+	type args struct {
+		ctx  context.Context
+		db   DynamoDBAPI
+		idxs []PrimaryIndex
+
+		expr expression.Expression
+	}
+	tests := map[string]struct {
+		args args
+		want map[string]testItem
+
+		wantErr bool
+		setup   func(t *testing.T, args *args) map[string]testItem
+		opts    []cmp.Option
+	}{
+		"success": {
+			args: args{
+				ctx: context.Background(),
+			},
+			setup: func(t *testing.T, args *args) (want map[string]testItem) {
+				var err error
+
+				// init db
+				args.db, err = ddbMain.conn()
+				assert.NoError(t, err)
+
+				want = map[string]testItem{}
+				for i := 0; i < 5; i++ {
+					// randomize
+					o := testItem{}
+					err = RandomizeDDBStruct(&o)
+					assert.NoError(t, err)
+
+					idx := testItemPrimaryIndex{HashKey: o.HashKey}
+					args.idxs = append(args.idxs, idx, idx)
+
+					// only the first 3 items exist
+					if i < 3 {
+						_, err = PutItem(args.ctx, args.db, o, expression.Expression{})
+						assert.NoError(t, err)
+
+						key, err := KeyString(idx)
+						assert.NoError(t, err)
+						want[key] = o
+					}
+				}
+
+				return want
+			},
+			opts: []cmp.Option{
+				cmpopts.IgnoreUnexported(testItem{}),
+			},
+		},
+	}
+	for name, tt := range tests {
+		tt := tt
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			tt.want = tt.setup(t, &tt.args)
+			got, err := BatchGetItemsMap[testItem](tt.args.ctx, tt.args.db, tt.args.idxs, tt.args.expr)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("wantErr is %t, but err is %v", tt.wantErr, err)
+			}
+			if diff := cmp.Diff(tt.want, got, tt.opts...); len(diff) > 0 {
+				t.Errorf("Compare value is mismatch (-want +got):%s\n", diff)
 			}
 		})
 	}
@@ -287,13 +638,14 @@ func testtestItemQuery(t *testing.T) {
 					}
 
 					// put item
-					err = PutItem(args.ctx, args.db, o, expression.Expression{})
+					_, err = PutItem(args.ctx, args.db, o, expression.Expression{})
 					assert.NoError(t, err)
 
 				}
 				// set args
 
-				proj := ProjectionAll[testItem]()
+				proj, err := ProjectionAll[testItem]()
+				assert.NoError(t, err)
 				keycond := expression.Key(testItemColumns.HashKey).Equal(expression.Value(id))
 				args.expr, err = expression.NewBuilder().WithProjection(proj).WithKeyCondition(keycond).Build()
 				assert.NoError(t, err)
@@ -342,7 +694,7 @@ func testtestItemQuery(t *testing.T) {
 					}
 
 					// put item
-					err = PutItem(args.ctx, args.db, o, expression.Expression{})
+					_, err = PutItem(args.ctx, args.db, o, expression.Expression{})
 					assert.NoError(t, err)
 
 				}
@@ -354,7 +706,8 @@ func testtestItemQuery(t *testing.T) {
 					},
 				}
 
-				proj := ProjectionAll[testItem]()
+				proj, err := ProjectionAll[testItem]()
+				assert.NoError(t, err)
 				keycond := expression.Key(testItemColumns.GSIHashKey).Equal(expression.Value(hashkey)).And(expression.Key(testItemColumns.GSIRangeKey).Equal(expression.Value(rangekey)))
 				args.expr, err = expression.NewBuilder().WithProjection(proj).WithKeyCondition(keycond).Build()
 				assert.NoError(t, err)
@@ -419,7 +772,7 @@ func testtestItemQuery(t *testing.T) {
 					}
 
 					// put item
-					err = PutItem(args.ctx, args.db, o, expression.Expression{})
+					_, err = PutItem(args.ctx, args.db, o, expression.Expression{})
 					assert.NoError(t, err)
 
 				}
@@ -431,7 +784,8 @@ func testtestItemQuery(t *testing.T) {
 					},
 				}
 
-				proj := ProjectionAll[testItem]()
+				proj, err := ProjectionAll[testItem]()
+				assert.NoError(t, err)
 				keycond := expression.Key(testItemColumns.GSIHashKey).Equal(expression.Value(hashkey)).And(expression.Key(testItemColumns.GSIRangeKey).Equal(expression.Value(rangekey)))
 				filter := expression.Name(testItemColumns.FilterKey).Equal(expression.Value(filt))
 				args.expr, err = expression.NewBuilder().WithProjection(proj).WithKeyCondition(keycond).WithFilter(filter).Build()
@@ -466,7 +820,8 @@ func testtestItemQuery(t *testing.T) {
 
 				// set args
 
-				proj := ProjectionAll[testItem]()
+				proj, err := ProjectionAll[testItem]()
+				assert.NoError(t, err)
 				keycond := expression.Key(testItemColumns.HashKey).Equal(expression.Value(hashKey))
 				args.expr, err = expression.NewBuilder().WithProjection(proj).WithKeyCondition(keycond).Build()
 				assert.NoError(t, err)
@@ -549,13 +904,14 @@ func testtestItemQueryAll(t *testing.T) {
 					}
 
 					// put item
-					err = PutItem(args.ctx, args.db, o, expression.Expression{})
+					_, err = PutItem(args.ctx, args.db, o, expression.Expression{})
 					assert.NoError(t, err)
 
 				}
 				// set args
 
-				proj := ProjectionAll[testItem]()
+				proj, err := ProjectionAll[testItem]()
+				assert.NoError(t, err)
 				keycond := expression.Key(testItemColumns.HashKey).Equal(expression.Value(id))
 				args.expr, err = expression.NewBuilder().WithProjection(proj).WithKeyCondition(keycond).Build()
 				args.opts = []QueryOptionFunc{
@@ -586,7 +942,8 @@ func testtestItemQueryAll(t *testing.T) {
 				id := "testId"
 				// set args
 
-				proj := ProjectionAll[testItem]()
+				proj, err := ProjectionAll[testItem]()
+				assert.NoError(t, err)
 				keycond := expression.Key(testItemColumns.HashKey).Equal(expression.Value(id))
 				args.expr, err = expression.NewBuilder().WithProjection(proj).WithKeyCondition(keycond).Build()
 				args.opts = []QueryOptionFunc{
@@ -641,7 +998,7 @@ func testtestItemQueryAll(t *testing.T) {
 					}
 
 					// put item
-					err = PutItem(args.ctx, args.db, o, expression.Expression{})
+					_, err = PutItem(args.ctx, args.db, o, expression.Expression{})
 					assert.NoError(t, err)
 
 				}
@@ -654,7 +1011,8 @@ func testtestItemQueryAll(t *testing.T) {
 					},
 				}
 
-				proj := ProjectionAll[testItem]()
+				proj, err := ProjectionAll[testItem]()
+				assert.NoError(t, err)
 				keycond := expression.Key(testItemColumns.GSIHashKey).Equal(expression.Value(hashkey)).And(expression.Key(testItemColumns.GSIRangeKey).Equal(expression.Value(rangekey)))
 				args.expr, err = expression.NewBuilder().WithProjection(proj).WithKeyCondition(keycond).Build()
 				assert.NoError(t, err)
@@ -719,7 +1077,7 @@ func testtestItemQueryAll(t *testing.T) {
 					}
 
 					// put item
-					err = PutItem(args.ctx, args.db, o, expression.Expression{})
+					_, err = PutItem(args.ctx, args.db, o, expression.Expression{})
 					assert.NoError(t, err)
 
 				}
@@ -732,7 +1090,8 @@ func testtestItemQueryAll(t *testing.T) {
 					},
 				}
 
-				proj := ProjectionAll[testItem]()
+				proj, err := ProjectionAll[testItem]()
+				assert.NoError(t, err)
 				keycond := expression.Key(testItemColumns.GSIHashKey).Equal(expression.Value(hashkey)).And(expression.Key(testItemColumns.GSIRangeKey).Equal(expression.Value(rangekey)))
 				filter := expression.Name(testItemColumns.FilterKey).Equal(expression.Value(filt))
 				args.expr, err = expression.NewBuilder().WithProjection(proj).WithKeyCondition(keycond).WithFilter(filter).Build()
@@ -767,7 +1126,8 @@ func testtestItemQueryAll(t *testing.T) {
 
 				// set args
 
-				proj := ProjectionAll[testItem]()
+				proj, err := ProjectionAll[testItem]()
+				assert.NoError(t, err)
 				keycond := expression.Key(testItemColumns.HashKey).Equal(expression.Value(hashKey))
 				args.expr, err = expression.NewBuilder().WithProjection(proj).WithKeyCondition(keycond).Build()
 				assert.NoError(t, err)
@@ -844,13 +1204,14 @@ func testtestItemScan(t *testing.T) {
 					want = append(want, o)
 
 					// put item
-					err = PutItem(args.ctx, args.db, o, expression.Expression{})
+					_, err = PutItem(args.ctx, args.db, o, expression.Expression{})
 					assert.NoError(t, err)
 
 				}
 				// set args
 
-				proj := ProjectionAll[testItem]()
+				proj, err := ProjectionAll[testItem]()
+				assert.NoError(t, err)
 				args.expr, err = expression.NewBuilder().WithProjection(proj).Build()
 				assert.NoError(t, err)
 				return want, nil
@@ -894,13 +1255,14 @@ func testtestItemScan(t *testing.T) {
 					}
 
 					// put item
-					err = PutItem(args.ctx, args.db, o, expression.Expression{})
+					_, err = PutItem(args.ctx, args.db, o, expression.Expression{})
 					assert.NoError(t, err)
 
 				}
 				// set args
 
-				proj := ProjectionAll[testItem]()
+				proj, err := ProjectionAll[testItem]()
+				assert.NoError(t, err)
 				filter := expression.Name(testItemColumns.FilterKey).Equal(expression.Value(filt))
 				args.expr, err = expression.NewBuilder().WithProjection(proj).WithFilter(filter).Build()
 				assert.NoError(t, err)
@@ -934,7 +1296,8 @@ func testtestItemScan(t *testing.T) {
 
 				// set args
 
-				proj := ProjectionAll[testItem]()
+				proj, err := ProjectionAll[testItem]()
+				assert.NoError(t, err)
 				keycond := expression.Key(testItemColumns.HashKey).Equal(expression.Value(hashkey))
 				args.expr, err = expression.NewBuilder().WithProjection(proj).WithKeyCondition(keycond).Build()
 				assert.NoError(t, err)
@@ -1014,13 +1377,14 @@ func testtestItemScanAll(t *testing.T) {
 					want = append(want, o)
 
 					// put item
-					err = PutItem(args.ctx, args.db, o, expression.Expression{})
+					_, err = PutItem(args.ctx, args.db, o, expression.Expression{})
 					assert.NoError(t, err)
 
 				}
 				// set args
 
-				proj := ProjectionAll[testItem]()
+				proj, err := ProjectionAll[testItem]()
+				assert.NoError(t, err)
 				args.expr, err = expression.NewBuilder().WithProjection(proj).Build()
 				assert.NoError(t, err)
 				return want
@@ -1064,14 +1428,15 @@ func testtestItemScanAll(t *testing.T) {
 					}
 
 					// put item
-					err = PutItem(args.ctx, args.db, o, expression.Expression{})
+					_, err = PutItem(args.ctx, args.db, o, expression.Expression{})
 					assert.NoError(t, err)
 
 				}
 
 				// set args
 
-				proj := ProjectionAll[testItem]()
+				proj, err := ProjectionAll[testItem]()
+				assert.NoError(t, err)
 				filter := expression.Name(testItemColumns.FilterKey).Equal(expression.Value(filt))
 				args.expr, err = expression.NewBuilder().WithProjection(proj).WithFilter(filter).Build()
 				assert.NoError(t, err)
@@ -1105,7 +1470,8 @@ func testtestItemScanAll(t *testing.T) {
 
 				// set args
 
-				proj := ProjectionAll[testItem]()
+				proj, err := ProjectionAll[testItem]()
+				assert.NoError(t, err)
 				keycond := expression.Key(testItemColumns.HashKey).Equal(expression.Value(hashkey))
 				args.expr, err = expression.NewBuilder().WithProjection(proj).WithKeyCondition(keycond).Build()
 				args.opts = []ScanOptionFunc{
@@ -1,9 +1,12 @@
 package dorm
 
 import (
+	"context"
+	"math/rand"
 	"net"
 	"net/url"
 	"strings"
+	"time"
 
 	"github.com/cockroachdb/errors"
 
@@ -61,3 +64,35 @@ func (r RetryableConnectionError) IsErrorRetryable(err error) aws.Ternary {
 	}
 	return aws.BoolTernary(retryable)
 }
+
+const (
+	// defaultBatchMaxAttempts is the default number of calls made for a batch request,
+	// including the first one, while unprocessed items remain.
+	defaultBatchMaxAttempts = 5
+	batchRetryBaseDelay     = 50 * time.Millisecond
+	batchRetryMaxDelay      = 5 * time.Second
+)
+
+// backoffDelay returns the delay before the given retry attempt, using exponential backoff with full jitter.
+func backoffDelay(attempt int) time.Duration {
+	d := batchRetryMaxDelay
+	if attempt < 30 {
+		d = batchRetryBaseDelay << attempt
+		if d > batchRetryMaxDelay {
+			d = batchRetryMaxDelay
+		}
+	}
+	return time.Duration(rand.Int63n(int64(d) + 1))
+}
+
+// sleepWithContext waits for d or until ctx is done.
+func sleepWithContext(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
@@ -0,0 +1,223 @@
+package dorm
+
+import (
+	"reflect"
+	"sync"
+
+	"github.com/cockroachdb/errors"
+)
+
+// fieldRole is the role of a field given by the dorm tag.
+type fieldRole int
+
+const (
+	roleNone fieldRole = iota
+	roleHash
+	roleRange
+	roleVersion
+	roleCreatedAt
+	roleUpdatedAt
+	roleTTL
+	roleEntity
+)
+
+// roleOf returns the role of a field from its dorm tag.
+func roleOf(f reflect.StructField) fieldRole {
+	tag := f.Tag.Get(dormStructTag)
+	switch {
+	case hasTagOption(tag, hashKeyTagOption):
+		return roleHash
+	case hasTagOption(tag, rangeKeyTagOption):
+		return roleRange
+	case hasTagOption(tag, versionTagOption):
+		return roleVersion
+	case hasTagOption(tag, createdAtTagOption):
+		return roleCreatedAt
+	case hasTagOption(tag, updatedAtTagOption):
+		return roleUpdatedAt
+	case hasTagOption(tag, ttlTagOption):
+		return roleTTL
+	}
+	if _, ok := tagOptionValue(tag, entityTagOption); ok {
+		return roleEntity
+	}
+	return roleNone
+}
+
+// isKey reports whether the field is a key, which can't be updated.
+func (r fieldRole) isKey() bool {
+	return r == roleHash || r == roleRange
+}
+
+// isTimestamp reports whether the field is set to the current time on write.
+func (r fieldRole) isTimestamp() bool {
+	return r == roleCreatedAt || r == roleUpdatedAt
+}
+
+// schema is the metadata of a struct type. It is parsed once per type by schemaOfType.
+type schema struct {
+	// fields are the attributes of the struct.
+	fields []attributeField
+	// version is nil if there is no field tagged with `dorm:"version"`.
+	version    *versionField
+	timestamps timestampFields
+	// ttl is nil if there is no field tagged with `dorm:"ttl"`.
+	ttl *ttlField
+	// entity is nil if there is no field tagged with `dorm:"entity=name"`.
+	entity *entityField
+	// primaryKey is the keys tagged with `dorm:"hash"` and `dorm:"range"`. It is empty if there are no such fields.
+	primaryKey indexKey
+	// gsis are the keys tagged with `dorm:"gsi=name:hash"` and `dorm:"gsi=name:range"` by the index names.
+	gsis map[string]indexKey
+	// lsis are the keys of the LSIs by the index names. The range keys are tagged with `dorm:"lsi=name"`.
+	lsis map[string]indexKey
+	// err is the error found while parsing the struct.
+	err error
+}
+
+var (
+	schemas    sync.Map // map[reflect.Type]*schema
+	tableNames sync.Map // map[reflect.Type]string
+)
+
+// schemaOf returns the schema of V.
+func schemaOf[V ItemType]() (*schema, error) {
+	s := schemaOfType(reflect.TypeOf((*V)(nil)).Elem())
+	return s, s.err
+}
+
+// schemaOfType returns the schema of rt, which may be a pointer to a struct.
+func schemaOfType(rt reflect.Type) *schema {
+	if s, ok := schemas.Load(rt); ok {
+		return s.(*schema)
+	}
+	s, _ := schemas.LoadOrStore(rt, parseSchema(rt))
+	return s.(*schema)
+}
+
+func parseSchema(rt reflect.Type) *schema {
+	fields, err := attributeFieldsOf(rt)
+	if err != nil {
+		return &schema{err: err}
+	}
+
+	s := &schema{fields: fields, gsis: map[string]indexKey{}, lsis: map[string]indexKey{}}
+	name := func() string { return rt.String() }
+	for i := range fields {
+		f := &fields[i]
+		if err := indexKeysOf(*f, s.gsis, s.lsis); err != nil {
+			return &schema{err: errors.Wrapf(err, "%s", name())}
+		}
+		if spec, ok := tagOptionValue(f.Tag.Get(dormStructTag), templateTagOption); ok {
+			if f.Type.Kind() != reflect.String {
+				return &schema{err: errors.Newf("template field %s of %s must be a string", f.Name, name())}
+			}
+			t, err := parseKeyTemplate(rt, spec)
+			if err != nil {
+				return &schema{err: errors.Wrapf(err, "%s", name())}
+			}
+			f.template = t
+		}
+		switch f.role {
+		case roleHash, roleRange:
+			dst := &s.primaryKey.hashKey
+			if f.role == roleRange {
+				dst = &s.primaryKey.rangeKey
+			}
+			if *dst != "" {
+				role := hashKeyTagOption
+				if f.role == roleRange {
+					role = rangeKeyTagOption
+				}
+				return &schema{err: errors.Newf("%s has more than one %s key field", name(), role)}
+			}
+			*dst = f.name
+		case roleVersion:
+			if s.version != nil {
+				return &schema{err: errors.Newf("%s has more than one version field", name())}
+			}
+			switch f.Type.Kind() {
+			case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+				reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			default:
+				return &schema{err: errors.Newf("version field %s of %s must be an integer", f.Name, name())}
+			}
+			s.version = &versionField{name: f.name, index: f.index}
+		case roleCreatedAt, roleUpdatedAt:
+			dst := &s.timestamps.createdAt
+			if f.role == roleUpdatedAt {
+				dst = &s.timestamps.updatedAt
+			}
+			if *dst != nil {
+				return &schema{err: errors.Newf("%s has more than one %s field", name(), f.Tag.Get(dormStructTag))}
+			}
+			if f.Type != timeType && !(f.Type.Kind() == reflect.Pointer && f.Type.Elem() == timeType) {
+				return &schema{err: errors.Newf("timestamp field %s of %s must be time.Time or *time.Time", f.Name, name())}
+			}
+			*dst = f
+		case roleTTL:
+			if s.ttl != nil {
+				return &schema{err: errors.Newf("%s has more than one ttl field", name())}
+			}
+			ttl, err := newTTLField(f)
+			if err != nil {
+				return &schema{err: errors.Wrapf(err, "%s", name())}
+			}
+			s.ttl = ttl
+		case roleEntity:
+			if s.entity != nil {
+				return &schema{err: errors.Newf("%s has more than one entity field", name())}
+			}
+			entity, err := newEntityField(f)
+			if err != nil {
+				return &schema{err: errors.Wrapf(err, "%s", name())}
+			}
+			s.entity = entity
+		}
+	}
+
+	if s.primaryKey.rangeKey != "" && s.primaryKey.hashKey == "" {
+		return &schema{err: errors.Newf("%s has a range key but no hash key", name())}
+	}
+	for n, k := range s.gsis {
+		if k.hashKey == "" {
+			return &schema{err: errors.Newf("gsi %s of %s has no hash key", n, name())}
+		}
+	}
+	for n, k := range s.lsis {
+		if s.primaryKey.rangeKey == "" {
+			return &schema{err: errors.Newf("lsi %s of %s requires a range key of the table", n, name())}
+		}
+		k.hashKey = s.primaryKey.hashKey
+		s.lsis[n] = k
+	}
+
+	return s
+}
+
+// field returns the top-level field of the attribute name, or nil if there is none.
+func (s *schema) field(name string) *attributeField {
+	for i := range s.fields {
+		if s.fields[i].name == name {
+			return &s.fields[i]
+		}
+	}
+	return nil
+}
+
+// tableNameOf returns the table name of V. It is cached, so TableName must return a constant.
+func tableNameOf[V ItemType]() string {
+	rt := reflect.TypeOf((*V)(nil)).Elem()
+	if n, ok := tableNames.Load(rt); ok {
+		return n.(string)
+	}
+
+	v := *new(V)
+	// The zero value of a pointer is nil, so TableName is called on a new value instead.
+	if rt.Kind() == reflect.Pointer {
+		v = reflect.New(rt.Elem()).Interface().(V)
+	}
+	n := v.TableName()
+	tableNames.Store(rt, n)
+	return n
+}
@@ -0,0 +1,101 @@
+package dorm
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSchemaOf(t *testing.T) {
+	t.Parallel()
+
+	t.Run("cached", func(t *testing.T) {
+		t.Parallel()
+
+		s1, err := schemaOf[testVersionedItem]()
+		assert.NoError(t, err)
+		s2, err := schemaOf[testVersionedItem]()
+		assert.NoError(t, err)
+		assert.Same(t, s1, s2)
+	})
+
+	t.Run("roles", func(t *testing.T) {
+		t.Parallel()
+
+		s, err := schemaOf[*schemaTestItem]()
+		assert.NoError(t, err)
+		assert.Equal(t, indexKey{hashKey: "hash_key", rangeKey: "range_key"}, s.primaryKey)
+		if assert.NotNil(t, s.version) {
+			assert.Equal(t, "version", s.version.name)
+			assert.Equal(t, []int{4, 0}, s.version.index)
+		}
+		if assert.NotNil(t, s.timestamps.createdAt) {
+			assert.Equal(t, "created_at", s.timestamps.createdAt.name)
+		}
+		assert.Nil(t, s.timestamps.updatedAt)
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		t.Parallel()
+
+		_, err := schemaOf[schemaTestInvalidVersion]()
+		assert.Error(t, err)
+		_, err = schemaOf[schemaTestInvalidTimestamp]()
+		assert.Error(t, err)
+		_, err = schemaOf[schemaTestInvalidEntity]()
+		assert.Error(t, err)
+		_, err = schemaOf[schemaTestInvalidTemplate]()
+		assert.Error(t, err)
+	})
+
+	t.Run("table name", func(t *testing.T) {
+		t.Parallel()
+
+		assert.Equal(t, "schema-test", tableNameOf[schemaTestItem]())
+		assert.Equal(t, "schema-test", tableNameOf[*schemaTestItem]())
+	})
+}
+
+type schemaTestVersion struct {
+	Version int64 `dynamodbav:"version" dorm:"version"`
+}
+
+type schemaTestItem struct {
+	Item      `dynamodbav:"-"`
+	HashKey   string    `dynamodbav:"hash_key" dorm:"hash"`
+	RangeKey  string    `dynamodbav:"range_key" dorm:"range"`
+	CreatedAt time.Time `dynamodbav:"created_at" dorm:"created_at"`
+	*schemaTestVersion
+}
+
+func (i schemaTestItem) TableName() string { return "schema-test" }
+
+type schemaTestInvalidVersion struct {
+	Item    `dynamodbav:"-"`
+	Version string `dynamodbav:"version" dorm:"version"`
+}
+
+func (i schemaTestInvalidVersion) TableName() string { return "schema-test" }
+
+type schemaTestInvalidTimestamp struct {
+	Item      `dynamodbav:"-"`
+	CreatedAt int64 `dynamodbav:"created_at" dorm:"created_at"`
+}
+
+func (i schemaTestInvalidTimestamp) TableName() string { return "schema-test" }
+
+type schemaTestInvalidEntity struct {
+	Item `dynamodbav:"-"`
+	Type int `dynamodbav:"type" dorm:"entity=user"`
+}
+
+func (i schemaTestInvalidEntity) TableName() string { return "schema-test" }
+
+type schemaTestInvalidTemplate struct {
+	Item `dynamodbav:"-"`
+	PK   int    `dynamodbav:"pk" dorm:"hash,template=USER#{ID}"`
+	ID   string `dynamodbav:"-"`
+}
+
+func (i schemaTestInvalidTemplate) TableName() string { return "schema-test" }
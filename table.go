@@ -1,9 +1,323 @@
 package dorm
 
-import "github.com/aws/aws-sdk-go-v2/aws"
+import (
+	"context"
+	"reflect"
+	"sort"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/cockroachdb/errors"
+)
 
 func getFullTableName[T ItemType]() *string {
-	v := *new(T)
+	return aws.String(tableNameOf[T]())
+}
+
+// TableAPI is the subset of the DynamoDB API used by dorm to manage tables.
+//
+// *dynamodb.Client satisfies this interface.
+type TableAPI interface {
+	CreateTable(ctx context.Context, params *dynamodb.CreateTableInput, optFns ...func(*dynamodb.Options)) (*dynamodb.CreateTableOutput, error)
+	DeleteTable(ctx context.Context, params *dynamodb.DeleteTableInput, optFns ...func(*dynamodb.Options)) (*dynamodb.DeleteTableOutput, error)
+	DescribeTable(ctx context.Context, params *dynamodb.DescribeTableInput, optFns ...func(*dynamodb.Options)) (*dynamodb.DescribeTableOutput, error)
+	DescribeTimeToLive(ctx context.Context, params *dynamodb.DescribeTimeToLiveInput, optFns ...func(*dynamodb.Options)) (*dynamodb.DescribeTimeToLiveOutput, error)
+	UpdateTimeToLive(ctx context.Context, params *dynamodb.UpdateTimeToLiveInput, optFns ...func(*dynamodb.Options)) (*dynamodb.UpdateTimeToLiveOutput, error)
+}
+
+var _ TableAPI = (*dynamodb.Client)(nil)
+
+// TableConfigurer can be implemented by an ItemType to adjust the input of CreateTable built from its key schema,
+// for example to set the projections of the indexes or the stream specification.
+type TableConfigurer interface {
+	ConfigureTable(input *dynamodb.CreateTableInput)
+}
+
+// CreateTableOptions CreateTable options for CreateTable function
+type CreateTableOptions struct {
+	// BillingMode is PAY_PER_REQUEST if it is not set.
+	BillingMode types.BillingMode
+	// ProvisionedThroughput is used for the table and the GSIs if BillingMode is PROVISIONED.
+	ProvisionedThroughput *types.ProvisionedThroughput
+}
+
+// CreateTableOptionFunc CreateTable option function
+type CreateTableOptionFunc func(*CreateTableOptions)
+
+// WithCreateTableBillingMode sets the BillingMode for CreateTableOptions.
+func WithCreateTableBillingMode(mode types.BillingMode) CreateTableOptionFunc {
+	return func(opts *CreateTableOptions) {
+		opts.BillingMode = mode
+	}
+}
+
+// WithCreateTableProvisionedThroughput sets the BillingMode to PROVISIONED and the ProvisionedThroughput for CreateTableOptions.
+func WithCreateTableProvisionedThroughput(read, write int64) CreateTableOptionFunc {
+	return func(opts *CreateTableOptions) {
+		opts.BillingMode = types.BillingModeProvisioned
+		opts.ProvisionedThroughput = &types.ProvisionedThroughput{
+			ReadCapacityUnits:  aws.Int64(read),
+			WriteCapacityUnits: aws.Int64(write),
+		}
+	}
+}
+
+// WaitOptions options for WaitUntilActive function
+type WaitOptions struct {
+	// Interval is the interval of DescribeTable calls. If it is 0 or less, 1 second is used.
+	Interval time.Duration
+}
+
+// WaitOptionFunc WaitUntilActive option function
+type WaitOptionFunc func(*WaitOptions)
+
+// WithWaitInterval sets the Interval for WaitOptions.
+func WithWaitInterval(interval time.Duration) WaitOptionFunc {
+	return func(opts *WaitOptions) {
+		opts.Interval = interval
+	}
+}
+
+// CreateTable creates the table of V from its TableName and the key schema declared with the dorm tags.
+//
+// The hash and range keys are tagged with `dorm:"hash"` and `dorm:"range"`, the keys of the GSIs with
+// `dorm:"gsi=name:hash"` and `dorm:"gsi=name:range"`, and the range keys of the LSIs with `dorm:"lsi=name"`.
+// The indexes project all attributes. If V implements TableConfigurer, it can adjust the input before the call.
+//
+// The table is being created when CreateTable returns. Use WaitUntilActive to wait until it can be used.
+// If V has a field tagged with `dorm:"ttl"`, CreateTable waits until the table is ACTIVE and enables TTL on the attribute,
+// and returns the description after that.
+//
+// https://pkg.go.dev/github.com/aws/aws-sdk-go-v2/service/dynamodb#Client.CreateTable
+// https://docs.aws.amazon.com/en_us/amazondynamodb/latest/APIReference/API_CreateTable.html
+func CreateTable[V ItemType](ctx context.Context, db TableAPI, opts ...CreateTableOptionFunc) (*types.TableDescription, error) {
+	input, err := createTableInput[V](opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := db.CreateTable(ctx, input)
+	if err != nil {
+		return nil, newOperationError("CreateTable", input.TableName, nil, err)
+	}
+
+	ttl := timeToLiveOf[V]()
+	if ttl == "" {
+		return resp.TableDescription, nil
+	}
+	// TTL can't be enabled while the table is being created
+	desc, err := WaitUntilActive[V](ctx, db)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := db.UpdateTimeToLive(ctx, timeToLiveInput(input.TableName, ttl, true)); err != nil {
+		return nil, newOperationError("UpdateTimeToLive", input.TableName, nil, err)
+	}
+	return desc, nil
+}
+
+// createTableInput builds the input of CreateTable for V.
+func createTableInput[V ItemType](opts ...CreateTableOptionFunc) (*dynamodb.CreateTableInput, error) {
+	input, err := declaredTableInput[V](opts...)
+	if err != nil {
+		return nil, err
+	}
+	if input.BillingMode == "" {
+		input.BillingMode = types.BillingModePayPerRequest
+	}
+	return input, nil
+}
+
+// declaredTableInput builds the input of CreateTable for V as createTableInput does,
+// but leaves BillingMode empty unless it is given by opts or set by the TableConfigurer of V.
+func declaredTableInput[V ItemType](opts ...CreateTableOptionFunc) (*dynamodb.CreateTableInput, error) {
+	o := CreateTableOptions{}
+	for _, f := range opts {
+		f(&o)
+	}
+	if o.BillingMode == types.BillingModeProvisioned && o.ProvisionedThroughput == nil {
+		return nil, errors.New("ProvisionedThroughput is required for PROVISIONED billing mode")
+	}
+
+	s, err := schemaOf[V]()
+	if err != nil {
+		return nil, err
+	}
+	if s.primaryKey.hashKey == "" {
+		return nil, errors.Newf("%s has no field tagged with `dorm:\"hash\"`", tableNameOf[V]())
+	}
+
+	// Every key attribute of the table and the indexes must be defined once
+	attrs := map[string]types.ScalarAttributeType{}
+	keySchema := func(k indexKey) ([]types.KeySchemaElement, error) {
+		res := []types.KeySchemaElement{{AttributeName: aws.String(k.hashKey), KeyType: types.KeyTypeHash}}
+		if k.rangeKey != "" {
+			res = append(res, types.KeySchemaElement{AttributeName: aws.String(k.rangeKey), KeyType: types.KeyTypeRange})
+		}
+		for _, name := range k.names() {
+			t, err := scalarAttributeType(s.field(name).Type)
+			if err != nil {
+				return nil, errors.Wrapf(err, "key attribute %s of %s", name, tableNameOf[V]())
+			}
+			attrs[name] = t
+		}
+		return res, nil
+	}
+
+	input := &dynamodb.CreateTableInput{
+		TableName:   getFullTableName[V](),
+		BillingMode: o.BillingMode,
+	}
+	if input.KeySchema, err = keySchema(s.primaryKey); err != nil {
+		return nil, err
+	}
+	if o.BillingMode == types.BillingModeProvisioned {
+		input.ProvisionedThroughput = o.ProvisionedThroughput
+	}
+
+	for _, name := range sortedIndexNames(s.gsis) {
+		ks, err := keySchema(s.gsis[name])
+		if err != nil {
+			return nil, err
+		}
+		gsi := types.GlobalSecondaryIndex{
+			IndexName:  aws.String(name),
+			KeySchema:  ks,
+			Projection: &types.Projection{ProjectionType: types.ProjectionTypeAll},
+		}
+		if o.BillingMode == types.BillingModeProvisioned {
+			gsi.ProvisionedThroughput = o.ProvisionedThroughput
+		}
+		input.GlobalSecondaryIndexes = append(input.GlobalSecondaryIndexes, gsi)
+	}
+	for _, name := range sortedIndexNames(s.lsis) {
+		ks, err := keySchema(s.lsis[name])
+		if err != nil {
+			return nil, err
+		}
+		input.LocalSecondaryIndexes = append(input.LocalSecondaryIndexes, types.LocalSecondaryIndex{
+			IndexName:  aws.String(name),
+			KeySchema:  ks,
+			Projection: &types.Projection{ProjectionType: types.ProjectionTypeAll},
+		})
+	}
+
+	names := make([]string, 0, len(attrs))
+	for name := range attrs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		input.AttributeDefinitions = append(input.AttributeDefinitions, types.AttributeDefinition{
+			AttributeName: aws.String(name),
+			AttributeType: attrs[name],
+		})
+	}
+
+	if c, ok := any(*new(V)).(TableConfigurer); ok {
+		c.ConfigureTable(input)
+	}
+
+	return input, nil
+}
+
+func sortedIndexNames(indexes map[string]indexKey) []string {
+	names := make([]string, 0, len(indexes))
+	for name := range indexes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// scalarAttributeType returns the type of a key attribute marshaled from a value of rt.
+func scalarAttributeType(rt reflect.Type) (types.ScalarAttributeType, error) {
+	for rt.Kind() == reflect.Pointer {
+		rt = rt.Elem()
+	}
+	switch {
+	case rt == timeType:
+		return types.ScalarAttributeTypeS, nil
+	case rt.Kind() == reflect.Slice && rt.Elem().Kind() == reflect.Uint8:
+		return types.ScalarAttributeTypeB, nil
+	}
+	switch rt.Kind() {
+	case reflect.String:
+		return types.ScalarAttributeTypeS, nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return types.ScalarAttributeTypeN, nil
+	}
+	return "", errors.Newf("%s can't be a key attribute", rt)
+}
+
+// DeleteTable deletes the table of V and all of its items.
+//
+// https://pkg.go.dev/github.com/aws/aws-sdk-go-v2/service/dynamodb#Client.DeleteTable
+// https://docs.aws.amazon.com/en_us/amazondynamodb/latest/APIReference/API_DeleteTable.html
+func DeleteTable[V ItemType](ctx context.Context, db TableAPI) error {
+	tableName := getFullTableName[V]()
+	if _, err := db.DeleteTable(ctx, &dynamodb.DeleteTableInput{TableName: tableName}); err != nil {
+		return newOperationError("DeleteTable", tableName, nil, err)
+	}
+	return nil
+}
+
+// DescribeTable returns the description of the table of V.
+// It returns ErrResourceNotFound if the table doesn't exist.
+//
+// https://pkg.go.dev/github.com/aws/aws-sdk-go-v2/service/dynamodb#Client.DescribeTable
+// https://docs.aws.amazon.com/en_us/amazondynamodb/latest/APIReference/API_DescribeTable.html
+func DescribeTable[V ItemType](ctx context.Context, db TableAPI) (*types.TableDescription, error) {
+	tableName := getFullTableName[V]()
+	resp, err := db.DescribeTable(ctx, &dynamodb.DescribeTableInput{TableName: tableName})
+	if err != nil {
+		return nil, newOperationError("DescribeTable", tableName, nil, err)
+	}
+	return resp.Table, nil
+}
+
+// WaitUntilActive waits until the table of V and all of its GSIs are ACTIVE, and returns the description.
+// It returns the error of ctx if ctx is done before that.
+func WaitUntilActive[V ItemType](ctx context.Context, db TableAPI, opts ...WaitOptionFunc) (*types.TableDescription, error) {
+	o := WaitOptions{}
+	for _, f := range opts {
+		f(&o)
+	}
+	if o.Interval <= 0 {
+		o.Interval = time.Second
+	}
+
+	for {
+		desc, err := DescribeTable[V](ctx, db)
+		// The table may not be visible yet right after CreateTable
+		if err != nil && !errors.Is(err, ErrResourceNotFound) {
+			return nil, err
+		}
+		if err == nil && isActive(desc) {
+			return desc, nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(o.Interval):
+		}
+	}
+}
 
-	return aws.String(v.TableName())
+// isActive reports whether the table and all of its GSIs are ACTIVE.
+func isActive(desc *types.TableDescription) bool {
+	if desc.TableStatus != types.TableStatusActive {
+		return false
+	}
+	for _, g := range desc.GlobalSecondaryIndexes {
+		if g.IndexStatus != types.IndexStatusActive {
+			return false
+		}
+	}
+	return true
 }
@@ -2,69 +2,101 @@ package dorm
 
 import (
 	"context"
+	"testing"
+	"time"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/expression"
 	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
 	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/stretchr/testify/assert"
 )
 
 func createtestItemTable(db *dynamodb.Client) error {
 	ctx := context.Background()
-	v := testItem{}
-	tn := v.TableName()
-	input := &dynamodb.CreateTableInput{
-		AttributeDefinitions: []types.AttributeDefinition{
-			{
-				AttributeName: aws.String(testItemColumns.HashKey),
-				AttributeType: types.ScalarAttributeTypeS,
-			},
-			{
-				AttributeName: aws.String(testItemColumns.GSIHashKey),
-				AttributeType: types.ScalarAttributeTypeS,
-			},
-			{
-				AttributeName: aws.String(testItemColumns.GSIRangeKey),
-				AttributeType: types.ScalarAttributeTypeS,
-			},
-		},
-		KeySchema: []types.KeySchemaElement{
-			{
-				AttributeName: aws.String(testItemColumns.HashKey),
-				KeyType:       types.KeyTypeHash,
-			},
-		},
-		TableName: aws.String(tn),
-		GlobalSecondaryIndexes: []types.GlobalSecondaryIndex{
-			{
-				IndexName: aws.String(testItemIndexName.GSI),
-				KeySchema: []types.KeySchemaElement{
-					{
-						AttributeName: aws.String(testItemColumns.GSIHashKey),
-						KeyType:       types.KeyTypeHash,
-					},
-					{
-						AttributeName: aws.String(testItemColumns.GSIRangeKey),
-						KeyType:       types.KeyTypeRange,
-					},
-				},
-				Projection: &types.Projection{
-					ProjectionType: types.ProjectionTypeAll,
-				},
-				ProvisionedThroughput: &types.ProvisionedThroughput{
-					ReadCapacityUnits:  aws.Int64(1000),
-					WriteCapacityUnits: aws.Int64(1000),
-				},
-			},
-		},
-		ProvisionedThroughput: &types.ProvisionedThroughput{
-			ReadCapacityUnits:  aws.Int64(1000),
-			WriteCapacityUnits: aws.Int64(1000),
-		},
-	}
+	_, err := CreateTable[testItem](ctx, db, WithCreateTableProvisionedThroughput(1000, 1000))
+
+	return err
+}
+
+func createtestVersionedItemTable(db *dynamodb.Client) error {
+	ctx := context.Background()
+	_, err := CreateTable[testVersionedItem](ctx, db, WithCreateTableProvisionedThroughput(1000, 1000))
+
+	return err
+}
 
-	// create table
-	_, err := db.CreateTable(ctx, input)
+func createtestTimestampedItemTable(db *dynamodb.Client) error {
+	ctx := context.Background()
+	_, err := CreateTable[testTimestampedItem](ctx, db, WithCreateTableProvisionedThroughput(1000, 1000))
 
 	return err
+}
+
+func testTableLifecycle(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+
+	db, err := ddbMain.conn()
+	assert.NoError(t, err)
 
+	_, err = CreateTable[tableTestItem](ctx, db)
+	assert.NoError(t, err)
+
+	desc, err := WaitUntilActive[tableTestItem](ctx, db, WithWaitInterval(10*time.Millisecond))
+	assert.NoError(t, err)
+	assert.Equal(t, types.BillingModePayPerRequest, desc.BillingModeSummary.BillingMode)
+	assert.Equal(t, []types.KeySchemaElement{
+		{AttributeName: aws.String("user_id"), KeyType: types.KeyTypeHash},
+		{AttributeName: aws.String("created"), KeyType: types.KeyTypeRange},
+	}, desc.KeySchema)
+	assert.Equal(t, []types.AttributeDefinition{
+		{AttributeName: aws.String("created"), AttributeType: types.ScalarAttributeTypeN},
+		{AttributeName: aws.String("email"), AttributeType: types.ScalarAttributeTypeS},
+		{AttributeName: aws.String("score"), AttributeType: types.ScalarAttributeTypeN},
+		{AttributeName: aws.String("user_id"), AttributeType: types.ScalarAttributeTypeS},
+	}, desc.AttributeDefinitions)
+	if assert.Len(t, desc.GlobalSecondaryIndexes, 1) {
+		assert.Equal(t, "by-email", aws.ToString(desc.GlobalSecondaryIndexes[0].IndexName))
+	}
+	if assert.Len(t, desc.LocalSecondaryIndexes, 1) {
+		assert.Equal(t, "by-score", aws.ToString(desc.LocalSecondaryIndexes[0].IndexName))
+	}
+
+	// The table can be used right away
+	_, err = PutItem(ctx, db, tableTestItem{UserID: "user", Created: 1, Email: "a@example.com", Score: 1}, expression.Expression{})
+	assert.NoError(t, err)
+
+	err = DeleteTable[tableTestItem](ctx, db)
+	assert.NoError(t, err)
+
+	_, err = DescribeTable[tableTestItem](ctx, db)
+	assert.ErrorIs(t, err, ErrResourceNotFound)
+}
+
+func TestCreateTableInput(t *testing.T) {
+	t.Parallel()
+
+	input, err := createTableInput[tableTestItem](WithCreateTableProvisionedThroughput(5, 10))
+	assert.NoError(t, err)
+	assert.Equal(t, types.BillingModeProvisioned, input.BillingMode)
+	assert.Equal(t, int64(5), aws.ToInt64(input.ProvisionedThroughput.ReadCapacityUnits))
+	assert.Equal(t, input.ProvisionedThroughput, input.GlobalSecondaryIndexes[0].ProvisionedThroughput)
+
+	_, err = createTableInput[utilTestItem]()
+	assert.Error(t, err)
+
+	_, err = createTableInput[tableTestItem](WithCreateTableBillingMode(types.BillingModeProvisioned))
+	assert.Error(t, err)
 }
+
+// tableTestItem is created and deleted by testTableLifecycle.
+type tableTestItem struct {
+	Item    `dynamodbav:"-"`
+	UserID  string `dynamodbav:"user_id" dorm:"hash"`
+	Created int64  `dynamodbav:"created" dorm:"range"`
+	Email   string `dynamodbav:"email" dorm:"gsi=by-email:hash"`
+	Score   int    `dynamodbav:"score" dorm:"lsi=by-score"`
+}
+
+func (i tableTestItem) TableName() string { return "test-table-lifecycle" }
@@ -0,0 +1,225 @@
+package dorm
+
+import (
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/expression"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/cockroachdb/errors"
+)
+
+// templateTagOption declares the template of a composite key, such as `dorm:"hash,template=USER#{ID}"`.
+const templateTagOption = "template="
+
+// keyTemplate composes the string attribute of a field from other fields, and splits it back into them.
+//
+// A template is literals and the names of Go fields in braces, such as "ORDER#{Date}#{ID}".
+// The fields must be strings or integers, and they don't need to be attributes themselves, so they can be tagged with
+// `dynamodbav:"-"`. Placeholders must be separated by literals, and a value must not contain the literal that follows it.
+type keyTemplate struct {
+	parts []templatePart
+	// suffix is the literal after the last placeholder.
+	suffix string
+}
+
+// templatePart is a placeholder of a keyTemplate with the literal before it.
+type templatePart struct {
+	literal string
+	field   reflect.StructField
+}
+
+// parseKeyTemplate parses the template of a field of the struct type rt.
+func parseKeyTemplate(rt reflect.Type, spec string) (*keyTemplate, error) {
+	rt, err := itemStructType(rt)
+	if err != nil {
+		return nil, err
+	}
+	t := &keyTemplate{}
+	rest := spec
+	for {
+		start := strings.IndexByte(rest, '{')
+		if start < 0 {
+			break
+		}
+		end := strings.IndexByte(rest[start:], '}')
+		if end < 0 {
+			return nil, errors.Newf("template %q has an unclosed placeholder", spec)
+		}
+		literal, name := rest[:start], rest[start+1:start+end]
+		if literal == "" && len(t.parts) > 0 {
+			return nil, errors.Newf("placeholders of template %q must be separated by literals", spec)
+		}
+		f, ok := rt.FieldByName(name)
+		if !ok || !f.IsExported() {
+			return nil, errors.Newf("template %q refers to unknown field %s", spec, name)
+		}
+		switch f.Type.Kind() {
+		case reflect.String,
+			reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+			reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		default:
+			return nil, errors.Newf("field %s of template %q must be a string or an integer", name, spec)
+		}
+		t.parts = append(t.parts, templatePart{literal: literal, field: f})
+		rest = rest[start+end+1:]
+	}
+	if len(t.parts) == 0 {
+		return nil, errors.Newf("template %q has no placeholders", spec)
+	}
+	t.suffix = rest
+	return t, nil
+}
+
+// compose returns the value of the template from the struct value v.
+// If partial is true, it stops at the first placeholder whose field is zero, after the literal before it.
+func (t *keyTemplate) compose(v reflect.Value, partial bool) string {
+	var sb strings.Builder
+	for _, p := range t.parts {
+		sb.WriteString(p.literal)
+		fv, ok := fieldValue(v, p.field.Index)
+		if partial && (!ok || fv.IsZero()) {
+			return sb.String()
+		}
+		if !ok {
+			fv = reflect.Zero(p.field.Type)
+		}
+		switch fv.Kind() {
+		case reflect.String:
+			sb.WriteString(fv.String())
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			sb.WriteString(strconv.FormatInt(fv.Int(), 10))
+		default:
+			sb.WriteString(strconv.FormatUint(fv.Uint(), 10))
+		}
+	}
+	sb.WriteString(t.suffix)
+	return sb.String()
+}
+
+// split sets the fields of the addressable struct value v from the value s of the template.
+func (t *keyTemplate) split(v reflect.Value, s string) error {
+	rest := s
+	for i, p := range t.parts {
+		if !strings.HasPrefix(rest, p.literal) {
+			return errors.Newf("%q doesn't match the template", s)
+		}
+		rest = rest[len(p.literal):]
+
+		var value string
+		if i == len(t.parts)-1 {
+			if !strings.HasSuffix(rest, t.suffix) {
+				return errors.Newf("%q doesn't match the template", s)
+			}
+			value, rest = rest[:len(rest)-len(t.suffix)], ""
+		} else {
+			j := strings.Index(rest, t.parts[i+1].literal)
+			if j < 0 {
+				return errors.Newf("%q doesn't match the template", s)
+			}
+			value, rest = rest[:j], rest[j:]
+		}
+
+		fv := settableField(v, p.field.Index)
+		switch fv.Kind() {
+		case reflect.String:
+			fv.SetString(value)
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			n, err := strconv.ParseInt(value, 10, fv.Type().Bits())
+			if err != nil {
+				return errors.Wrapf(err, "field %s of %q", p.field.Name, s)
+			}
+			fv.SetInt(n)
+		default:
+			n, err := strconv.ParseUint(value, 10, fv.Type().Bits())
+			if err != nil {
+				return errors.Wrapf(err, "field %s of %q", p.field.Name, s)
+			}
+			fv.SetUint(n)
+		}
+	}
+	return nil
+}
+
+// composeTemplates sets the attributes of the fields with templates in av, composed from the struct value v.
+func (s *schema) composeTemplates(v reflect.Value, av map[string]types.AttributeValue) {
+	sv, ok := structValue(v)
+	if !ok {
+		return
+	}
+	for _, f := range s.fields {
+		if f.template != nil {
+			av[f.name] = &types.AttributeValueMemberS{Value: f.template.compose(sv, false)}
+		}
+	}
+}
+
+// splitTemplates sets the fields of the templates of the struct value v from the attributes in av.
+// v must be addressable or a pointer. The attributes that are not in av are left as they are.
+func (s *schema) splitTemplates(v reflect.Value, av map[string]types.AttributeValue) error {
+	sv, ok := structValue(v)
+	if !ok {
+		return nil
+	}
+	for _, f := range s.fields {
+		if f.template == nil {
+			continue
+		}
+		a, ok := av[f.name].(*types.AttributeValueMemberS)
+		if !ok {
+			continue
+		}
+		if err := f.template.split(sv, a.Value); err != nil {
+			return errors.Wrapf(err, "attribute %s", f.name)
+		}
+	}
+	return nil
+}
+
+// templateOf returns the template of the attribute name of T.
+func templateOf[T any](name string) (*keyTemplate, error) {
+	rt := reflect.TypeOf((*T)(nil)).Elem()
+	s := schemaOfType(rt)
+	if s.err != nil {
+		return nil, s.err
+	}
+	f := s.field(name)
+	if f == nil || f.template == nil {
+		return nil, errors.Newf("attribute %s of %s has no template", name, rt)
+	}
+	return f.template, nil
+}
+
+// KeyEqual returns the key condition that the attribute name equals the value composed from item
+// by the template of the field, such as `dorm:"hash,template=USER#{ID}"`.
+// T is an ItemType or an index struct.
+func KeyEqual[T any](item T, name string) (expression.KeyConditionBuilder, error) {
+	t, err := templateOf[T](name)
+	if err != nil {
+		return expression.KeyConditionBuilder{}, err
+	}
+	sv, ok := structValue(reflect.ValueOf(item))
+	if !ok {
+		return expression.KeyConditionBuilder{}, errors.Newf("%T is nil", item)
+	}
+	return expression.Key(name).Equal(expression.Value(t.compose(sv, false))), nil
+}
+
+// KeyBeginsWith returns the key condition that the attribute name begins with the value composed from the partially
+// filled item by the template of the field. The value ends before the first field that is zero, after the literal before it.
+//
+// For example, with `dorm:"range,template=ORDER#{Date}#{ID}"`, an item with only Date set to "2024-01-02" gives
+// begins_with(sk, "ORDER#2024-01-02#"), and an empty item gives begins_with(sk, "ORDER#").
+// T is an ItemType or an index struct.
+func KeyBeginsWith[T any](item T, name string) (expression.KeyConditionBuilder, error) {
+	t, err := templateOf[T](name)
+	if err != nil {
+		return expression.KeyConditionBuilder{}, err
+	}
+	sv, ok := structValue(reflect.ValueOf(item))
+	if !ok {
+		return expression.KeyConditionBuilder{}, errors.Newf("%T is nil", item)
+	}
+	return expression.Key(name).BeginsWith(t.compose(sv, true)), nil
+}
@@ -0,0 +1,185 @@
+package dorm
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/expression"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func testKeyTemplates(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+
+	db, err := ddbMain.conn()
+	assert.NoError(t, err)
+
+	_, err = CreateTable[templateTestOrder](ctx, db)
+	assert.NoError(t, err)
+
+	err = BatchPutItem(ctx, db, []templateTestOrder{
+		{UserID: "1", Date: "2024-01-02", ID: 1, Total: 100},
+		{UserID: "1", Date: "2024-01-02", ID: 2, Total: 200},
+		{UserID: "1", Date: "2024-01-03", ID: 3, Total: 300},
+		{UserID: "2", Date: "2024-01-02", ID: 4, Total: 400},
+	})
+	assert.NoError(t, err)
+
+	// The keys are composed from the fields
+	raw, err := db.GetItem(ctx, &dynamodb.GetItemInput{
+		TableName: aws.String(templateTestOrder{}.TableName()),
+		Key: map[string]types.AttributeValue{
+			"pk": &types.AttributeValueMemberS{Value: "USER#1"},
+			"sk": &types.AttributeValueMemberS{Value: "ORDER#2024-01-02#1"},
+		},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, &types.AttributeValueMemberN{Value: "100"}, raw.Item["total"])
+
+	// The keys of index structs are composed, and the keys of items are split back into the fields
+	got, err := GetItem[templateTestOrder](ctx, db, templateTestKey{UserID: "1", Date: "2024-01-02", ID: 1}, expression.Expression{})
+	assert.NoError(t, err)
+	assert.Equal(t, &templateTestOrder{PK: "USER#1", SK: "ORDER#2024-01-02#1", UserID: "1", Date: "2024-01-02", ID: 1, Total: 100}, got)
+
+	hash, err := KeyEqual(templateTestOrder{UserID: "1"}, "pk")
+	assert.NoError(t, err)
+	prefix, err := KeyBeginsWith(templateTestOrder{UserID: "1", Date: "2024-01-02"}, "sk")
+	assert.NoError(t, err)
+	orders, err := QueryAll[templateTestOrder](ctx, db, mustBuildExpr(expression.NewBuilder().WithKeyCondition(hash.And(prefix))))
+	assert.NoError(t, err)
+	assert.ElementsMatch(t, []templateTestOrder{
+		{PK: "USER#1", SK: "ORDER#2024-01-02#1", UserID: "1", Date: "2024-01-02", ID: 1, Total: 100},
+		{PK: "USER#1", SK: "ORDER#2024-01-02#2", UserID: "1", Date: "2024-01-02", ID: 2, Total: 200},
+	}, orders)
+
+	prefix, err = KeyBeginsWith(templateTestOrder{}, "sk")
+	assert.NoError(t, err)
+	orders, err = QueryAll[templateTestOrder](ctx, db, mustBuildExpr(expression.NewBuilder().WithKeyCondition(hash.And(prefix))))
+	assert.NoError(t, err)
+	assert.Len(t, orders, 3)
+}
+
+func TestKeyTemplate(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		spec    string
+		item    templateTestParts
+		value   string
+		partial string
+		invalid string
+		err     bool
+	}{
+		"single": {
+			spec:    "USER#{Name}",
+			item:    templateTestParts{Name: "alice"},
+			value:   "USER#alice",
+			partial: "USER#alice",
+			invalid: "ORDER#alice",
+		},
+		"several": {
+			spec:    "ORDER#{Name}#{Num}",
+			item:    templateTestParts{Name: "alice", Num: -3},
+			value:   "ORDER#alice#-3",
+			partial: "ORDER#alice#-3",
+			invalid: "ORDER#alice",
+		},
+		"partial": {
+			spec:    "{Name}#{Seq}#{Num}",
+			item:    templateTestParts{Name: "alice", Num: 1},
+			value:   "alice#0#1",
+			partial: "alice#",
+			invalid: "alice#x#1",
+		},
+		"suffix": {
+			spec:    "A#{Seq}!",
+			item:    templateTestParts{Seq: 7},
+			value:   "A#7!",
+			partial: "A#7!",
+			invalid: "A#7",
+		},
+		"no placeholders":   {spec: "USER", err: true},
+		"unclosed":          {spec: "USER#{Name", err: true},
+		"adjacent":          {spec: "{Name}{Num}", err: true},
+		"unknown field":     {spec: "USER#{Unknown}", err: true},
+		"unsupported field": {spec: "USER#{Tags}", err: true},
+	}
+
+	for name, tt := range tests {
+		tt := tt
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			tmpl, err := parseKeyTemplate(reflect.TypeOf(templateTestParts{}), tt.spec)
+			if tt.err {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.value, tmpl.compose(reflect.ValueOf(tt.item), false))
+			assert.Equal(t, tt.partial, tmpl.compose(reflect.ValueOf(tt.item), true))
+
+			var got templateTestParts
+			assert.NoError(t, tmpl.split(reflect.ValueOf(&got).Elem(), tt.value))
+			assert.Equal(t, tt.item, got)
+
+			assert.Error(t, tmpl.split(reflect.ValueOf(&got).Elem(), tt.invalid))
+		})
+	}
+}
+
+func TestKeyTemplateConditions(t *testing.T) {
+	t.Parallel()
+
+	hash, err := KeyEqual(templateTestKey{UserID: "1"}, "pk")
+	assert.NoError(t, err)
+	prefix, err := KeyBeginsWith(&templateTestOrder{UserID: "1", ID: 2}, "sk")
+	assert.NoError(t, err)
+	expr := mustBuildExpr(expression.NewBuilder().WithKeyCondition(hash.And(prefix)))
+	assert.Equal(t, "(#0 = :0) AND (begins_with (#1, :1))", *expr.KeyCondition())
+	assert.Equal(t, map[string]types.AttributeValue{
+		":0": &types.AttributeValueMemberS{Value: "USER#1"},
+		":1": &types.AttributeValueMemberS{Value: "ORDER#"},
+	}, expr.Values())
+
+	_, err = KeyBeginsWith(templateTestOrder{}, "total")
+	assert.Error(t, err)
+
+	_, err = KeyEqual((*templateTestOrder)(nil), "pk")
+	assert.Error(t, err)
+	_, err = KeyBeginsWith((*templateTestOrder)(nil), "sk")
+	assert.Error(t, err)
+}
+
+type templateTestOrder struct {
+	Item   `dynamodbav:"-"`
+	PK     string `dynamodbav:"pk" dorm:"hash,template=USER#{UserID}"`
+	SK     string `dynamodbav:"sk" dorm:"range,template=ORDER#{Date}#{ID}"`
+	UserID string `dynamodbav:"-"`
+	Date   string `dynamodbav:"date"`
+	ID     int    `dynamodbav:"-"`
+	Total  int    `dynamodbav:"total"`
+}
+
+func (i templateTestOrder) TableName() string { return "test-key-template" }
+
+type templateTestKey struct {
+	PrimaryIndex `dynamodbav:"-"`
+	PK           string `dynamodbav:"pk" dorm:"template=USER#{UserID}"`
+	SK           string `dynamodbav:"sk" dorm:"template=ORDER#{Date}#{ID}"`
+	UserID       string `dynamodbav:"-"`
+	Date         string `dynamodbav:"-"`
+	ID           int    `dynamodbav:"-"`
+}
+
+type templateTestParts struct {
+	Name string
+	Num  int64
+	Seq  uint8
+	Tags []string
+}
@@ -0,0 +1,183 @@
+package dorm
+
+import (
+	"reflect"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/expression"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+const (
+	createdAtTagOption = "created_at"
+	updatedAtTagOption = "updated_at"
+)
+
+// timestampPrefix is the placeholder prefix of the expressions added for timestamps.
+const timestampPrefix = "t"
+
+// Clock returns the current time.
+type Clock func() time.Time
+
+var (
+	clockMu sync.RWMutex
+	clock   Clock = time.Now
+)
+
+// SetClock replaces the clock used for the fields tagged with `dorm:"created_at"` and `dorm:"updated_at"`
+// and for the expiry of the field tagged with `dorm:"ttl"`, and returns a function that restores the previous clock.
+// It is intended for tests.
+func SetClock(c Clock) (restore func()) {
+	clockMu.Lock()
+	defer clockMu.Unlock()
+
+	prev := clock
+	clock = c
+	return func() {
+		clockMu.Lock()
+		defer clockMu.Unlock()
+		clock = prev
+	}
+}
+
+func now() time.Time {
+	clockMu.RLock()
+	defer clockMu.RUnlock()
+	return clock()
+}
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// timestampFields are the fields of an ItemType tagged with `dorm:"created_at"` and `dorm:"updated_at"`.
+// They must be time.Time or *time.Time.
+//
+// PutItem fills created_at if it is zero and always sets updated_at.
+// UpdateItem sets updated_at, and sets created_at only if the item doesn't have it yet.
+type timestampFields struct {
+	// createdAt and updatedAt are nil if there is no such field.
+	createdAt *attributeField
+	updatedAt *attributeField
+}
+
+func (f timestampFields) empty() bool {
+	return f.createdAt == nil && f.updatedAt == nil
+}
+
+// timestampFieldsOf returns the timestamp fields of V.
+func timestampFieldsOf[V ItemType]() (timestampFields, error) {
+	s, err := schemaOf[V]()
+	if err != nil {
+		return timestampFields{}, err
+	}
+	return s.timestamps, nil
+}
+
+func setTime(v reflect.Value, t time.Time) {
+	if v.Kind() == reflect.Pointer {
+		v.Set(reflect.ValueOf(&t))
+		return
+	}
+	v.Set(reflect.ValueOf(t))
+}
+
+func isZeroTime(v reflect.Value) bool {
+	if v.Kind() == reflect.Pointer {
+		return v.IsNil() || v.Interface().(*time.Time).IsZero()
+	}
+	return v.Interface().(time.Time).IsZero()
+}
+
+// stamp sets the timestamps of item for a put. item must be an addressable struct.
+func (f timestampFields) stamp(item reflect.Value, t time.Time) {
+	if f.createdAt != nil {
+		if v := settableField(item, f.createdAt.index); isZeroTime(v) {
+			setTime(v, t)
+		}
+	}
+	if f.updatedAt != nil {
+		setTime(settableField(item, f.updatedAt.index), t)
+	}
+}
+
+// timestampValue marshals t as the field f of V, so that the encoding follows the tags of the field.
+func timestampValue[V ItemType](f *attributeField, t time.Time) (types.AttributeValue, error) {
+	rt, err := itemStructType(reflect.TypeOf((*V)(nil)).Elem())
+	if err != nil {
+		return nil, err
+	}
+	v := reflect.New(rt).Elem()
+	setTime(settableField(v, f.index), t)
+	av, err := attributevalue.MarshalMap(v.Interface())
+	if err != nil {
+		return nil, err
+	}
+	return av[f.name], nil
+}
+
+// timestampUpdate sets updated_at to t, and created_at to t if it doesn't exist.
+// Fields for which skip returns true are left out. It returns nil if there is nothing to set.
+func timestampUpdate[V ItemType](f timestampFields, t time.Time, skip func(name string) bool) (*expression.UpdateBuilder, error) {
+	var res *expression.UpdateBuilder
+	if f.createdAt != nil && !skip(f.createdAt.name) {
+		av, err := timestampValue[V](f.createdAt, t)
+		if err != nil {
+			return nil, err
+		}
+		name := expression.Name(f.createdAt.name)
+		upd := expression.Set(name, expression.IfNotExists(name, expression.Value(av)))
+		res = &upd
+	}
+	if f.updatedAt != nil && !skip(f.updatedAt.name) {
+		av, err := timestampValue[V](f.updatedAt, t)
+		if err != nil {
+			return nil, err
+		}
+		name := expression.Name(f.updatedAt.name)
+		if res == nil {
+			upd := expression.Set(name, expression.Value(av))
+			res = &upd
+		} else {
+			upd := res.Set(name, expression.Value(av))
+			res = &upd
+		}
+	}
+	return res, nil
+}
+
+// stamped returns a copy of item with the timestamps of V set for a put.
+func stamped[V ItemType](item V) (V, error) {
+	f, err := timestampFieldsOf[V]()
+	if err != nil || f.empty() {
+		return item, err
+	}
+	v := reflect.ValueOf(&item).Elem()
+	if v.Kind() == reflect.Pointer {
+		// Stamp a copy, so that the item of the caller is not changed.
+		if v.IsNil() {
+			return item, nil
+		}
+		c := reflect.New(v.Type().Elem())
+		c.Elem().Set(v.Elem())
+		v.Set(c)
+		v = c.Elem()
+	}
+	f.stamp(v, now())
+	return item, nil
+}
+
+// timestamped adds the timestamps of V to the update of parts,
+// except for the attributes that the update already sets. It does nothing if V has no timestamp fields.
+func timestamped[V ItemType](parts exprParts) (exprParts, error) {
+	f, err := timestampFieldsOf[V]()
+	if err != nil || f.empty() {
+		return parts, err
+	}
+
+	upd, err := timestampUpdate[V](f, now(), parts.updates)
+	if err != nil || upd == nil {
+		return parts, err
+	}
+	return parts.merge(timestampPrefix, expression.NewBuilder().WithUpdate(*upd))
+}
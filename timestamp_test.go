@@ -0,0 +1,176 @@
+package dorm
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/expression"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func testtestTimestampedItemTimestamps(t *testing.T) {
+	created := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	updated := created.Add(time.Hour)
+
+	current := created
+	restore := SetClock(func() time.Time { return current })
+	defer restore()
+
+	tests := map[string]struct {
+		// stored is whether the item is put at created before write.
+		stored bool
+		write  func(t *testing.T, ctx context.Context, db DynamoDBAPI, o testTimestampedItem) error
+
+		wantCreatedAt time.Time
+		wantUpdatedAt time.Time
+	}{
+		"put new item": {
+			write: func(t *testing.T, ctx context.Context, db DynamoDBAPI, o testTimestampedItem) error {
+				_, err := PutItem(ctx, db, o, expression.Expression{})
+				return err
+			},
+			wantCreatedAt: updated,
+			wantUpdatedAt: updated,
+		},
+		"put keeps created_at": {
+			write: func(t *testing.T, ctx context.Context, db DynamoDBAPI, o testTimestampedItem) error {
+				o.CreatedAt = created
+				_, err := PutItem(ctx, db, o, expression.Expression{})
+				return err
+			},
+			wantCreatedAt: created,
+			wantUpdatedAt: updated,
+		},
+		"batch put": {
+			write: func(t *testing.T, ctx context.Context, db DynamoDBAPI, o testTimestampedItem) error {
+				return BatchPutItem(ctx, db, []testTimestampedItem{o})
+			},
+			wantCreatedAt: updated,
+			wantUpdatedAt: updated,
+		},
+		"update stored item": {
+			stored: true,
+			write: func(t *testing.T, ctx context.Context, db DynamoDBAPI, o testTimestampedItem) error {
+				expr, err := expression.NewBuilder().
+					WithUpdate(expression.Set(expression.Name("str"), expression.Value("updated"))).
+					Build()
+				assert.NoError(t, err)
+				got, err := UpdateItem[testTimestampedItem](ctx, db, testTimestampedItemPrimaryIndex{HashKey: o.HashKey}, expr)
+				assert.NoError(t, err)
+				assert.True(t, created.Equal(got.CreatedAt))
+				assert.True(t, updated.Equal(*got.UpdatedAt))
+				return err
+			},
+			wantCreatedAt: created,
+			wantUpdatedAt: updated,
+		},
+		"update new item": {
+			write: func(t *testing.T, ctx context.Context, db DynamoDBAPI, o testTimestampedItem) error {
+				expr, err := expression.NewBuilder().
+					WithUpdate(expression.Set(expression.Name("str"), expression.Value("updated"))).
+					Build()
+				assert.NoError(t, err)
+				_, err = UpdateItem[testTimestampedItem](ctx, db, testTimestampedItemPrimaryIndex{HashKey: o.HashKey}, expr)
+				return err
+			},
+			wantCreatedAt: updated,
+			wantUpdatedAt: updated,
+		},
+		"update with explicit updated_at": {
+			stored: true,
+			write: func(t *testing.T, ctx context.Context, db DynamoDBAPI, o testTimestampedItem) error {
+				expr, err := expression.NewBuilder().
+					WithUpdate(expression.Set(expression.Name("updated_at"), expression.Value(created))).
+					Build()
+				assert.NoError(t, err)
+				_, err = UpdateItem[testTimestampedItem](ctx, db, testTimestampedItemPrimaryIndex{HashKey: o.HashKey}, expr)
+				return err
+			},
+			wantCreatedAt: created,
+			wantUpdatedAt: created,
+		},
+		"update with AttributeSetAll": {
+			stored: true,
+			write: func(t *testing.T, ctx context.Context, db DynamoDBAPI, o testTimestampedItem) error {
+				upd, err := AttributeSetAll(o)
+				assert.NoError(t, err)
+				expr, err := expression.NewBuilder().WithUpdate(upd).Build()
+				assert.NoError(t, err)
+				_, err = UpdateItem[testTimestampedItem](ctx, db, testTimestampedItemPrimaryIndex{HashKey: o.HashKey}, expr)
+				return err
+			},
+			wantCreatedAt: created,
+			wantUpdatedAt: updated,
+		},
+		"transaction update": {
+			stored: true,
+			write: func(t *testing.T, ctx context.Context, db DynamoDBAPI, o testTimestampedItem) error {
+				expr, err := expression.NewBuilder().
+					WithUpdate(expression.Set(expression.Name("str"), expression.Value("updated"))).
+					Build()
+				assert.NoError(t, err)
+				return TransactWriteItems(ctx, db, NewTransactWriteBuilder().Add(
+					TransactUpdate[testTimestampedItem](testTimestampedItemPrimaryIndex{HashKey: o.HashKey}, expr),
+				))
+			},
+			wantCreatedAt: created,
+			wantUpdatedAt: updated,
+		},
+	}
+	for name, tt := range tests {
+		tt := tt
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+
+			db, err := ddbMain.conn()
+			assert.NoError(t, err)
+
+			// randomize
+			hashKey, err := NewRandomEngStr(32)
+			assert.NoError(t, err)
+			o := testTimestampedItem{HashKey: hashKey}
+
+			current = created
+			if tt.stored {
+				_, err = PutItem(ctx, db, o, expression.Expression{})
+				assert.NoError(t, err)
+			}
+
+			current = updated
+			err = tt.write(t, ctx, db, o)
+			assert.NoError(t, err)
+
+			got, err := GetItem[testTimestampedItem](ctx, db, testTimestampedItemPrimaryIndex{HashKey: o.HashKey}, expression.Expression{})
+			assert.NoError(t, err)
+			assert.True(t, tt.wantCreatedAt.Equal(got.CreatedAt), "created_at: %v", got.CreatedAt)
+			if assert.NotNil(t, got.UpdatedAt) {
+				assert.True(t, tt.wantUpdatedAt.Equal(*got.UpdatedAt), "updated_at: %v", got.UpdatedAt)
+			}
+		})
+	}
+}
+
+func TestAttributeSetAllUnixTimeTimestamps(t *testing.T) {
+	t.Parallel()
+
+	upd, err := AttributeSetAll(timestampTestUnixTimeItem{HashKey: "a"})
+	assert.NoError(t, err)
+	expr := mustBuildExpr(expression.NewBuilder().WithUpdate(upd))
+
+	// The timestamps are numbers, as PutItem and UpdateItem write them
+	assert.Len(t, expr.Values(), 2)
+	for name, v := range expr.Values() {
+		assert.IsType(t, &types.AttributeValueMemberN{}, v, name)
+	}
+}
+
+type timestampTestUnixTimeItem struct {
+	Item      `dynamodbav:"-"`
+	HashKey   string     `dynamodbav:"hash_key" dorm:"hash"`
+	CreatedAt time.Time  `dynamodbav:"created_at,unixtime" dorm:"created_at"`
+	UpdatedAt *time.Time `dynamodbav:"updated_at,unixtime" dorm:"updated_at"`
+}
+
+func (i timestampTestUnixTimeItem) TableName() string { return "test-timestamp-unixtime" }
@@ -0,0 +1,460 @@
+package dorm
+
+import (
+	"context"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/expression"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/cockroachdb/errors"
+)
+
+const maxTransactItemSize = 100
+
+// TransactWriteOptions options for an action of TransactWriteItems
+type TransactWriteOptions struct {
+	// ReturnValuesOnConditionCheckFailure is set to ALL_OLD to return the item when the condition fails.
+	// The item is then available in the CancellationReason of TransactionCanceledError.
+	ReturnValuesOnConditionCheckFailure types.ReturnValuesOnConditionCheckFailure
+	// ExpectedVersion is the version the item must have, if V has a version field.
+	// It is used by TransactUpdate, TransactDelete and TransactConditionCheck, and required by TransactUpdate and TransactDelete.
+	ExpectedVersion *int64
+}
+
+// TransactWriteOptionFunc TransactWriteItems action option function
+type TransactWriteOptionFunc func(*TransactWriteOptions)
+
+// WithTransactReturnValuesOnConditionCheckFailure sets the ReturnValuesOnConditionCheckFailure for TransactWriteOptions.
+func WithTransactReturnValuesOnConditionCheckFailure(rv types.ReturnValuesOnConditionCheckFailure) TransactWriteOptionFunc {
+	return func(opts *TransactWriteOptions) {
+		opts.ReturnValuesOnConditionCheckFailure = rv
+	}
+}
+
+// WithTransactExpectedVersion sets the ExpectedVersion for TransactWriteOptions.
+func WithTransactExpectedVersion(version int64) TransactWriteOptionFunc {
+	return func(opts *TransactWriteOptions) {
+		opts.ExpectedVersion = &version
+	}
+}
+
+func newTransactWriteOptions(opts []TransactWriteOptionFunc) TransactWriteOptions {
+	o := TransactWriteOptions{}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o
+}
+
+// TransactWriteOperation is an action of TransactWriteItems.
+// Use TransactPut, TransactUpdate, TransactDelete or TransactConditionCheck to create it.
+type TransactWriteOperation interface {
+	transactWriteItem() (types.TransactWriteItem, error)
+	unmarshalItem(item map[string]types.AttributeValue) (any, error)
+	versionConflict(item map[string]types.AttributeValue) bool
+}
+
+// transactTarget unmarshals the items returned for an action on V, and detects version conflicts.
+type transactTarget[V ItemType] struct {
+	// check is set by transactWriteItem if the version is checked.
+	check *versionCheck
+}
+
+func (transactTarget[V]) unmarshalItem(item map[string]types.AttributeValue) (any, error) {
+	var val V
+	if err := unmarshalItem(item, &val); err != nil {
+		return nil, err
+	}
+	return &val, nil
+}
+
+func (t transactTarget[V]) versionConflict(item map[string]types.AttributeValue) bool {
+	return t.check.conflict(item)
+}
+
+type transactPut[V ItemType] struct {
+	transactTarget[V]
+
+	item V
+	expr expression.Expression
+	opts TransactWriteOptions
+}
+
+func (op *transactPut[V]) transactWriteItem() (types.TransactWriteItem, error) {
+	item, err := stamped(op.item)
+	if err != nil {
+		return types.TransactWriteItem{}, err
+	}
+	av, err := marshalItem(item)
+	if err != nil {
+		return types.TransactWriteItem{}, err
+	}
+	if err := checkItemKey[V](av); err != nil {
+		return types.TransactWriteItem{}, err
+	}
+	parts, check, err := versionedPut(item, av, partsOf(op.expr))
+	if err != nil {
+		return types.TransactWriteItem{}, err
+	}
+	op.check = check
+	return types.TransactWriteItem{
+		Put: &types.Put{
+			Item:                      av,
+			TableName:                 getFullTableName[V](),
+			ConditionExpression:       parts.Condition,
+			ExpressionAttributeNames:  parts.Names,
+			ExpressionAttributeValues: parts.Values,
+
+			ReturnValuesOnConditionCheckFailure: returnValuesOnConditionCheckFailure(op.opts.ReturnValuesOnConditionCheckFailure, check),
+		},
+	}, nil
+}
+
+// TransactPut adds an item if it doesn't exist, or replaces it if it does, as PutItem does.
+// The version is checked and incremented, and the timestamps are set, as PutItem does.
+func TransactPut[V ItemType](item V, expr expression.Expression, opts ...TransactWriteOptionFunc) TransactWriteOperation {
+	return &transactPut[V]{item: item, expr: expr, opts: newTransactWriteOptions(opts)}
+}
+
+type transactUpdate[V ItemType] struct {
+	transactTarget[V]
+
+	idx  PrimaryIndex
+	expr expression.Expression
+	opts TransactWriteOptions
+}
+
+func (op *transactUpdate[V]) transactWriteItem() (types.TransactWriteItem, error) {
+	key, err := primaryKeyOf[V](op.idx)
+	if err != nil {
+		return types.TransactWriteItem{}, err
+	}
+	if err := requireExpectedVersion[V]("TransactUpdate", "WithTransactExpectedVersion", op.opts.ExpectedVersion); err != nil {
+		return types.TransactWriteItem{}, err
+	}
+	parts, check, err := versioned[V](partsOf(op.expr), op.opts.ExpectedVersion, true)
+	if err != nil {
+		return types.TransactWriteItem{}, err
+	}
+	parts, err = timestamped[V](parts)
+	if err != nil {
+		return types.TransactWriteItem{}, err
+	}
+	parts, err = entityStamped[V](parts)
+	if err != nil {
+		return types.TransactWriteItem{}, err
+	}
+	op.check = check
+	return types.TransactWriteItem{
+		Update: &types.Update{
+			Key:                       key,
+			TableName:                 getFullTableName[V](),
+			ConditionExpression:       parts.Condition,
+			ExpressionAttributeNames:  parts.Names,
+			ExpressionAttributeValues: parts.Values,
+			UpdateExpression:          parts.Update,
+
+			ReturnValuesOnConditionCheckFailure: returnValuesOnConditionCheckFailure(op.opts.ReturnValuesOnConditionCheckFailure, check),
+		},
+	}, nil
+}
+
+// TransactUpdate updates an item, as UpdateItem does. The expression must have an update.
+// The version is checked and incremented, and the timestamps are set, as UpdateItem does.
+func TransactUpdate[V ItemType](idx PrimaryIndex, expr expression.Expression, opts ...TransactWriteOptionFunc) TransactWriteOperation {
+	return &transactUpdate[V]{idx: idx, expr: expr, opts: newTransactWriteOptions(opts)}
+}
+
+type transactDelete[V ItemType] struct {
+	transactTarget[V]
+
+	idx  PrimaryIndex
+	expr expression.Expression
+	opts TransactWriteOptions
+}
+
+func (op *transactDelete[V]) transactWriteItem() (types.TransactWriteItem, error) {
+	key, err := primaryKeyOf[V](op.idx)
+	if err != nil {
+		return types.TransactWriteItem{}, err
+	}
+	if err := requireExpectedVersion[V]("TransactDelete", "WithTransactExpectedVersion", op.opts.ExpectedVersion); err != nil {
+		return types.TransactWriteItem{}, err
+	}
+	parts, check, err := versioned[V](partsOf(op.expr), op.opts.ExpectedVersion, false)
+	if err != nil {
+		return types.TransactWriteItem{}, err
+	}
+	parts, err = entityGuarded[V](parts)
+	if err != nil {
+		return types.TransactWriteItem{}, err
+	}
+	op.check = check
+	return types.TransactWriteItem{
+		Delete: &types.Delete{
+			Key:                       key,
+			TableName:                 getFullTableName[V](),
+			ConditionExpression:       parts.Condition,
+			ExpressionAttributeNames:  parts.Names,
+			ExpressionAttributeValues: parts.Values,
+
+			ReturnValuesOnConditionCheckFailure: returnValuesOnConditionCheckFailure(op.opts.ReturnValuesOnConditionCheckFailure, check),
+		},
+	}, nil
+}
+
+// TransactDelete deletes an item, as DeleteItem does.
+func TransactDelete[V ItemType](idx PrimaryIndex, expr expression.Expression, opts ...TransactWriteOptionFunc) TransactWriteOperation {
+	return &transactDelete[V]{idx: idx, expr: expr, opts: newTransactWriteOptions(opts)}
+}
+
+type transactConditionCheck[V ItemType] struct {
+	transactTarget[V]
+
+	idx  PrimaryIndex
+	expr expression.Expression
+	opts TransactWriteOptions
+}
+
+func (op *transactConditionCheck[V]) transactWriteItem() (types.TransactWriteItem, error) {
+	key, err := primaryKeyOf[V](op.idx)
+	if err != nil {
+		return types.TransactWriteItem{}, err
+	}
+	parts, check, err := versioned[V](partsOf(op.expr), op.opts.ExpectedVersion, false)
+	if err != nil {
+		return types.TransactWriteItem{}, err
+	}
+	op.check = check
+	return types.TransactWriteItem{
+		ConditionCheck: &types.ConditionCheck{
+			Key:                       key,
+			TableName:                 getFullTableName[V](),
+			ConditionExpression:       parts.Condition,
+			ExpressionAttributeNames:  parts.Names,
+			ExpressionAttributeValues: parts.Values,
+
+			ReturnValuesOnConditionCheckFailure: returnValuesOnConditionCheckFailure(op.opts.ReturnValuesOnConditionCheckFailure, check),
+		},
+	}, nil
+}
+
+// TransactConditionCheck checks that the condition holds for an item without changing it.
+// The expression must have a condition, unless ExpectedVersion is set.
+func TransactConditionCheck[V ItemType](idx PrimaryIndex, expr expression.Expression, opts ...TransactWriteOptionFunc) TransactWriteOperation {
+	return &transactConditionCheck[V]{idx: idx, expr: expr, opts: newTransactWriteOptions(opts)}
+}
+
+// TransactWriteBuilder builds a TransactWriteItems request.
+type TransactWriteBuilder struct {
+	ops                []TransactWriteOperation
+	clientRequestToken *string
+}
+
+// NewTransactWriteBuilder creates an empty TransactWriteBuilder.
+func NewTransactWriteBuilder() *TransactWriteBuilder {
+	return &TransactWriteBuilder{}
+}
+
+// Add appends operations to the transaction.
+func (b *TransactWriteBuilder) Add(ops ...TransactWriteOperation) *TransactWriteBuilder {
+	b.ops = append(b.ops, ops...)
+	return b
+}
+
+// WithClientRequestToken sets the ClientRequestToken, which makes retries of the same transaction idempotent.
+func (b *TransactWriteBuilder) WithClientRequestToken(token string) *TransactWriteBuilder {
+	b.clientRequestToken = aws.String(token)
+	return b
+}
+
+// Len returns the number of operations in the transaction.
+func (b *TransactWriteBuilder) Len() int {
+	return len(b.ops)
+}
+
+// Build builds the TransactWriteItemsInput.
+func (b *TransactWriteBuilder) Build() (*dynamodb.TransactWriteItemsInput, error) {
+	if len(b.ops) == 0 {
+		return nil, ErrEmptyTransaction
+	}
+	if len(b.ops) > maxTransactItemSize {
+		return nil, ErrMaxTransactItemsExceeded
+	}
+
+	items := make([]types.TransactWriteItem, len(b.ops))
+	for i, op := range b.ops {
+		item, err := op.transactWriteItem()
+		if err != nil {
+			return nil, err
+		}
+		items[i] = item
+	}
+
+	return &dynamodb.TransactWriteItemsInput{
+		TransactItems:      items,
+		ClientRequestToken: b.clientRequestToken,
+	}, nil
+}
+
+// TransactWriteItems applies all operations of the builder atomically.
+// If any of them fails, none of them are applied.
+//
+// https://pkg.go.dev/github.com/aws/aws-sdk-go-v2/service/dynamodb#Client.TransactWriteItems
+// https://docs.aws.amazon.com/en_us/amazondynamodb/latest/APIReference/API_TransactWriteItems.html
+func TransactWriteItems(ctx context.Context, db DynamoDBAPI, b *TransactWriteBuilder) error {
+
+	input, err := b.Build()
+	if err != nil {
+		return err
+	}
+
+	_, err = db.TransactWriteItems(ctx, input)
+	if err != nil {
+		return newOperationError("TransactWriteItems", nil, nil, transactionCanceled(err, b.ops))
+	}
+
+	return nil
+}
+
+// TransactGetOperation is a read of TransactGetItems. Use TransactGet to create it.
+type TransactGetOperation interface {
+	transactGetItem() (types.TransactGetItem, error)
+	setResponse(item map[string]types.AttributeValue) error
+}
+
+// TransactGetResult is a typed read of TransactGetItems.
+// Its result is available after TransactGetItems returns successfully.
+type TransactGetResult[V ItemType] struct {
+	idx  PrimaryIndex
+	expr expression.Expression
+
+	item *V
+}
+
+// TransactGet reads an item, as GetItem does. Only the projection of the expression is used.
+func TransactGet[V ItemType](idx PrimaryIndex, expr expression.Expression) *TransactGetResult[V] {
+	return &TransactGetResult[V]{idx: idx, expr: expr}
+}
+
+func (r *TransactGetResult[V]) transactGetItem() (types.TransactGetItem, error) {
+	key, err := primaryKeyOf[V](r.idx)
+	if err != nil {
+		return types.TransactGetItem{}, err
+	}
+	return types.TransactGetItem{
+		Get: &types.Get{
+			Key:                      key,
+			TableName:                getFullTableName[V](),
+			ExpressionAttributeNames: r.expr.Names(),
+			ProjectionExpression:     r.expr.Projection(),
+		},
+	}, nil
+}
+
+func (r *TransactGetResult[V]) setResponse(item map[string]types.AttributeValue) error {
+	r.item = nil
+	if checkEmptyResp(item) {
+		return nil
+	}
+
+	s, err := schemaOf[V]()
+	if err != nil {
+		return err
+	}
+	// The key may belong to an item of another entity type sharing the table
+	if !s.entity.matches(item) {
+		return nil
+	}
+
+	var val V
+	if err := unmarshalItem(item, &val); err != nil {
+		return err
+	}
+	r.item = &val
+	return nil
+}
+
+// Result returns the item that was read. It returns ErrItemNotFound if the item does not exist
+// or is of another entity type.
+func (r *TransactGetResult[V]) Result() (*V, error) {
+	if r.item == nil {
+		return nil, ErrItemNotFound
+	}
+	return r.item, nil
+}
+
+// TransactGetItems reads all items as a single snapshot, which may span several tables.
+// Each result is stored in the TransactGetResult passed as gets.
+//
+// https://pkg.go.dev/github.com/aws/aws-sdk-go-v2/service/dynamodb#Client.TransactGetItems
+// https://docs.aws.amazon.com/en_us/amazondynamodb/latest/APIReference/API_TransactGetItems.html
+func TransactGetItems(ctx context.Context, db DynamoDBAPI, gets ...TransactGetOperation) error {
+
+	if len(gets) == 0 {
+		return ErrEmptyTransaction
+	}
+	if len(gets) > maxTransactItemSize {
+		return ErrMaxTransactItemsExceeded
+	}
+
+	items := make([]types.TransactGetItem, len(gets))
+	for i, g := range gets {
+		item, err := g.transactGetItem()
+		if err != nil {
+			return err
+		}
+		items[i] = item
+	}
+
+	output, err := db.TransactGetItems(ctx, &dynamodb.TransactGetItemsInput{TransactItems: items})
+	if err != nil {
+		return newOperationError("TransactGetItems", nil, nil, transactionCanceled(err, nil))
+	}
+
+	for i, g := range gets {
+		var item map[string]types.AttributeValue
+		if i < len(output.Responses) {
+			item = output.Responses[i].Item
+		}
+		if err := g.setResponse(item); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// transactionCanceled converts a TransactionCanceledException into TransactionCanceledError,
+// decoding the returned items with the actions in ops. Other errors are returned as is.
+func transactionCanceled(err error, ops []TransactWriteOperation) error {
+	var tce *types.TransactionCanceledException
+	if !errors.As(err, &tce) {
+		return err
+	}
+
+	cerr := &TransactionCanceledError{
+		Reasons: make([]CancellationReason, len(tce.CancellationReasons)),
+		err:     err,
+	}
+	var decodeErr error
+	for i, r := range tce.CancellationReasons {
+		reason := CancellationReason{
+			Index:   i,
+			Code:    aws.ToString(r.Code),
+			Message: aws.ToString(r.Message),
+		}
+		if len(r.Item) > 0 && i < len(ops) {
+			item, err := ops[i].unmarshalItem(r.Item)
+			if err != nil {
+				decodeErr = errors.CombineErrors(decodeErr, errors.Wrapf(err, "failed to decode the item of action %d", i))
+			}
+			reason.Item = item
+			reason.VersionConflict = ops[i].versionConflict(r.Item)
+		}
+		cerr.Reasons[i] = reason
+	}
+
+	return errors.CombineErrors(errors.WithStack(cerr), decodeErr)
+}
@@ -0,0 +1,372 @@
+package dorm
+
+import (
+	"context"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/expression"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/cockroachdb/errors"
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+	"github.com/stretchr/testify/assert"
+)
+
+func testtestItemTransactWriteItems(t *testing.T) {
+	t.Parallel()
+	type args struct {
+		ctx context.Context
+		db  DynamoDBAPI
+
+		builder *TransactWriteBuilder
+		// items are the items written in setup.
+		items []testItem
+	}
+	tests := map[string]struct {
+		args       args
+		setup      func(*testing.T, *args)
+		wantErr    bool
+		selfAssert []func(t *testing.T, args *args, err error)
+	}{
+		"success": {
+			args: args{
+				ctx: context.Background(),
+			},
+			setup: func(t *testing.T, args *args) {
+				var err error
+
+				// init db
+				args.db, err = ddbMain.conn()
+				assert.NoError(t, err)
+
+				// randomize
+				args.items = make([]testItem, 4)
+				for i := range args.items {
+					err = RandomizeDDBStruct(&args.items[i])
+					assert.NoError(t, err)
+				}
+				err = BatchPutItem(args.ctx, args.db, args.items[1:])
+				assert.NoError(t, err)
+
+				upd, err := expression.NewBuilder().
+					WithUpdate(expression.Set(expression.Name(testItemColumns.Str), expression.Value("updated"))).
+					WithCondition(expression.AttributeExists(expression.Name(testItemColumns.HashKey))).
+					Build()
+				assert.NoError(t, err)
+				check, err := expression.NewBuilder().
+					WithCondition(expression.Equal(expression.Name(testItemColumns.Str), expression.Value(args.items[3].Str))).
+					Build()
+				assert.NoError(t, err)
+
+				args.builder = NewTransactWriteBuilder().Add(
+					TransactPut(args.items[0], expression.Expression{}),
+					TransactUpdate[testItem](testItemPrimaryIndex{HashKey: args.items[1].HashKey}, upd),
+					TransactDelete[testItem](testItemPrimaryIndex{HashKey: args.items[2].HashKey}, expression.Expression{}),
+					TransactConditionCheck[testItem](testItemPrimaryIndex{HashKey: args.items[3].HashKey}, check),
+				)
+			},
+			selfAssert: []func(t *testing.T, args *args, err error){
+				func(t *testing.T, args *args, err error) {
+					got, err := GetItem[testItem](args.ctx, args.db, testItemPrimaryIndex{HashKey: args.items[0].HashKey}, expression.Expression{})
+					assert.NoError(t, err)
+					if diff := cmp.Diff(&args.items[0], got, cmpopts.IgnoreUnexported(testItem{})); len(diff) > 0 {
+						t.Errorf("Compare value is mismatch (-want +got):%s\n", diff)
+					}
+
+					got, err = GetItem[testItem](args.ctx, args.db, testItemPrimaryIndex{HashKey: args.items[1].HashKey}, expression.Expression{})
+					assert.NoError(t, err)
+					assert.Equal(t, "updated", got.Str)
+
+					_, err = GetItem[testItem](args.ctx, args.db, testItemPrimaryIndex{HashKey: args.items[2].HashKey}, expression.Expression{})
+					assert.True(t, errors.Is(err, ErrItemNotFound))
+				},
+			},
+		},
+		"condition failed": {
+			args: args{
+				ctx: context.Background(),
+			},
+			setup: func(t *testing.T, args *args) {
+				var err error
+
+				// init db
+				args.db, err = ddbMain.conn()
+				assert.NoError(t, err)
+
+				// randomize
+				args.items = make([]testItem, 2)
+				for i := range args.items {
+					err = RandomizeDDBStruct(&args.items[i])
+					assert.NoError(t, err)
+				}
+				_, err = PutItem(args.ctx, args.db, args.items[1], expression.Expression{})
+				assert.NoError(t, err)
+
+				check, err := expression.NewBuilder().
+					WithCondition(expression.AttributeNotExists(expression.Name(testItemColumns.HashKey))).
+					Build()
+				assert.NoError(t, err)
+
+				args.builder = NewTransactWriteBuilder().Add(
+					TransactPut(args.items[0], expression.Expression{}),
+					TransactConditionCheck[testItem](testItemPrimaryIndex{HashKey: args.items[1].HashKey}, check),
+				)
+			},
+			wantErr: true,
+			selfAssert: []func(t *testing.T, args *args, err error){
+				func(t *testing.T, args *args, err error) {
+					assert.ErrorIs(t, err, ErrTransactionCanceled)
+
+					var cerr *TransactionCanceledError
+					assert.True(t, errors.As(err, &cerr))
+					assert.Len(t, cerr.Reasons, 2)
+					assert.Equal(t, CancellationReasonNone, cerr.Reasons[0].Code)
+					assert.Equal(t, CancellationReasonConditionalCheckFailed, cerr.Reasons[1].Code)
+					assert.Nil(t, cerr.Reasons[1].Item)
+					assert.Equal(t, cerr.Reasons[1:], cerr.Failed())
+				},
+				func(t *testing.T, args *args, err error) {
+					// nothing is written
+					_, err = GetItem[testItem](args.ctx, args.db, testItemPrimaryIndex{HashKey: args.items[0].HashKey}, expression.Expression{})
+					assert.True(t, errors.Is(err, ErrItemNotFound))
+				},
+			},
+		},
+		"condition failed with item": {
+			args: args{
+				ctx: context.Background(),
+			},
+			setup: func(t *testing.T, args *args) {
+				var err error
+
+				// init db
+				args.db, err = ddbMain.conn()
+				assert.NoError(t, err)
+
+				// randomize
+				args.items = make([]testItem, 1)
+				err = RandomizeDDBStruct(&args.items[0])
+				assert.NoError(t, err)
+				_, err = PutItem(args.ctx, args.db, args.items[0], expression.Expression{})
+				assert.NoError(t, err)
+
+				cond, err := expression.NewBuilder().
+					WithCondition(expression.AttributeNotExists(expression.Name(testItemColumns.HashKey))).
+					Build()
+				assert.NoError(t, err)
+
+				args.builder = NewTransactWriteBuilder().Add(
+					TransactPut(args.items[0], cond, WithTransactReturnValuesOnConditionCheckFailure(types.ReturnValuesOnConditionCheckFailureAllOld)),
+				)
+			},
+			wantErr: true,
+			selfAssert: []func(t *testing.T, args *args, err error){
+				func(t *testing.T, args *args, err error) {
+					var cerr *TransactionCanceledError
+					assert.True(t, errors.As(err, &cerr))
+					assert.Len(t, cerr.Reasons, 1)
+					assert.Equal(t, CancellationReasonConditionalCheckFailed, cerr.Reasons[0].Code)
+
+					got, ok := cerr.Reasons[0].Item.(*testItem)
+					assert.True(t, ok)
+					if diff := cmp.Diff(&args.items[0], got, cmpopts.IgnoreUnexported(testItem{})); len(diff) > 0 {
+						t.Errorf("Compare value is mismatch (-want +got):%s\n", diff)
+					}
+				},
+			},
+		},
+		"client request token": {
+			args: args{
+				ctx: context.Background(),
+			},
+			setup: func(t *testing.T, args *args) {
+				var err error
+
+				// init db
+				args.db, err = ddbMain.conn()
+				assert.NoError(t, err)
+
+				// randomize
+				args.items = make([]testItem, 1)
+				err = RandomizeDDBStruct(&args.items[0])
+				assert.NoError(t, err)
+
+				cond, err := expression.NewBuilder().
+					WithCondition(expression.AttributeNotExists(expression.Name(testItemColumns.HashKey))).
+					Build()
+				assert.NoError(t, err)
+
+				args.builder = NewTransactWriteBuilder().
+					Add(TransactPut(args.items[0], cond)).
+					WithClientRequestToken(args.items[0].HashKey)
+
+				// the retry with the same token succeeds even though the condition no longer holds
+				err = TransactWriteItems(args.ctx, args.db, args.builder)
+				assert.NoError(t, err)
+			},
+		},
+		"max items exceeded": {
+			args: args{
+				ctx: context.Background(),
+			},
+			setup: func(t *testing.T, args *args) {
+				var err error
+
+				// init db
+				args.db, err = ddbMain.conn()
+				assert.NoError(t, err)
+
+				args.builder = NewTransactWriteBuilder()
+				for i := 0; i < maxTransactItemSize+1; i++ {
+					o := testItem{}
+					err = RandomizeDDBStruct(&o)
+					assert.NoError(t, err)
+					args.builder.Add(TransactPut(o, expression.Expression{}))
+				}
+			},
+			wantErr: true,
+			selfAssert: []func(t *testing.T, args *args, err error){
+				func(t *testing.T, args *args, err error) {
+					assert.ErrorIs(t, err, ErrMaxTransactItemsExceeded)
+				},
+			},
+		},
+		"empty": {
+			args: args{
+				ctx:     context.Background(),
+				builder: NewTransactWriteBuilder(),
+			},
+			setup: func(t *testing.T, args *args) {
+				var err error
+
+				// init db
+				args.db, err = ddbMain.conn()
+				assert.NoError(t, err)
+			},
+			wantErr: true,
+			selfAssert: []func(t *testing.T, args *args, err error){
+				func(t *testing.T, args *args, err error) {
+					assert.ErrorIs(t, err, ErrEmptyTransaction)
+				},
+			},
+		},
+	}
+	for name, tt := range tests {
+		tt := tt
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			tt.setup(t, &tt.args)
+			err := TransactWriteItems(tt.args.ctx, tt.args.db, tt.args.builder)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("wantErr is %t, but err is %v", tt.wantErr, err)
+			}
+			for _, fn := range tt.selfAssert {
+				fn(t, &tt.args, err)
+			}
+		})
+	}
+}
+
+func testtestItemTransactGetItems(t *testing.T) {
+	t.Parallel()
+	type args struct {
+		ctx context.Context
+		db  DynamoDBAPI
+
+		gets []TransactGetOperation
+	}
+	tests := map[string]struct {
+		args       args
+		setup      func(*testing.T, *args) []testItem
+		wantErr    bool
+		selfAssert []func(t *testing.T, args *args, want []testItem)
+	}{
+		"success": {
+			args: args{
+				ctx: context.Background(),
+			},
+			setup: func(t *testing.T, args *args) []testItem {
+				var err error
+
+				// init db
+				args.db, err = ddbMain.conn()
+				assert.NoError(t, err)
+
+				// randomize
+				items := make([]testItem, 3)
+				for i := range items {
+					err = RandomizeDDBStruct(&items[i])
+					assert.NoError(t, err)
+				}
+				err = BatchPutItem(args.ctx, args.db, items[:2])
+				assert.NoError(t, err)
+
+				projAll, err := ProjectionAll[testItem]()
+				assert.NoError(t, err)
+				proj, err := expression.NewBuilder().WithProjection(projAll).Build()
+				assert.NoError(t, err)
+				args.gets = []TransactGetOperation{
+					TransactGet[testItem](testItemPrimaryIndex{HashKey: items[0].HashKey}, expression.Expression{}),
+					TransactGet[testItem](testItemPrimaryIndex{HashKey: items[1].HashKey}, proj),
+					TransactGet[testItem](testItemPrimaryIndex{HashKey: items[2].HashKey}, expression.Expression{}),
+				}
+				return items
+			},
+			selfAssert: []func(t *testing.T, args *args, want []testItem){
+				func(t *testing.T, args *args, want []testItem) {
+					for i, g := range args.gets[:2] {
+						got, err := g.(*TransactGetResult[testItem]).Result()
+						assert.NoError(t, err)
+						if diff := cmp.Diff(&want[i], got, cmpopts.IgnoreUnexported(testItem{})); len(diff) > 0 {
+							t.Errorf("Compare value is mismatch (-want +got):%s\n", diff)
+						}
+					}
+
+					_, err := args.gets[2].(*TransactGetResult[testItem]).Result()
+					assert.True(t, errors.Is(err, ErrItemNotFound))
+				},
+			},
+		},
+		"max items exceeded": {
+			args: args{
+				ctx: context.Background(),
+			},
+			setup: func(t *testing.T, args *args) []testItem {
+				var err error
+
+				// init db
+				args.db, err = ddbMain.conn()
+				assert.NoError(t, err)
+
+				args.gets = make([]TransactGetOperation, maxTransactItemSize+1)
+				for i := range args.gets {
+					hashKey, err := NewRandomEngStr(32)
+					assert.NoError(t, err)
+					args.gets[i] = TransactGet[testItem](testItemPrimaryIndex{HashKey: hashKey}, expression.Expression{})
+				}
+				return nil
+			},
+			wantErr: true,
+			selfAssert: []func(t *testing.T, args *args, want []testItem){
+				func(t *testing.T, args *args, want []testItem) {
+					err := TransactGetItems(args.ctx, args.db, args.gets...)
+					assert.ErrorIs(t, err, ErrMaxTransactItemsExceeded)
+				},
+			},
+		},
+	}
+	for name, tt := range tests {
+		tt := tt
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			want := tt.setup(t, &tt.args)
+			err := TransactGetItems(tt.args.ctx, tt.args.db, tt.args.gets...)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("wantErr is %t, but err is %v", tt.wantErr, err)
+			}
+			for _, fn := range tt.selfAssert {
+				fn(t, &tt.args, want)
+			}
+		})
+	}
+}
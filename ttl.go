@@ -0,0 +1,145 @@
+package dorm
+
+import (
+	"reflect"
+	"strconv"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/expression"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/cockroachdb/errors"
+)
+
+const (
+	ttlTagOption = "ttl"
+	// unixTimeTagOption is the dynamodbav option that marshals time.Time into epoch seconds by itself.
+	unixTimeTagOption = "unixtime"
+)
+
+// ttlPrefix is the placeholder prefix of the expressions added to skip expired items.
+const ttlPrefix = "e"
+
+// ttlField is the field of an ItemType tagged with `dorm:"ttl"`.
+// It must be time.Time, *time.Time or an integer of epoch seconds.
+//
+// attributevalue marshals time.Time into a string, while DynamoDB TTL requires a number of epoch seconds,
+// so the attribute is converted when the item is marshaled and unmarshaled. The zero time is not stored,
+// so that the item never expires.
+type ttlField struct {
+	*attributeField
+	// convert is true if the field is time.Time or *time.Time without the unixtime option.
+	convert bool
+}
+
+func newTTLField(f *attributeField) (*ttlField, error) {
+	switch f.Type.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return &ttlField{attributeField: f}, nil
+	}
+	if f.Type != timeType && !(f.Type.Kind() == reflect.Pointer && f.Type.Elem() == timeType) {
+		return nil, errors.Newf("ttl field %s must be time.Time, *time.Time or an integer", f.Name)
+	}
+	return &ttlField{attributeField: f, convert: !hasTagOption(f.Tag.Get(structTag), unixTimeTagOption)}, nil
+}
+
+// attributeName returns the attribute name, or empty if f is nil.
+func (f *ttlField) attributeName() string {
+	if f == nil {
+		return ""
+	}
+	return f.name
+}
+
+// encode converts the TTL attribute of the marshaled item av into epoch seconds.
+func (f *ttlField) encode(av map[string]types.AttributeValue) error {
+	if f == nil || !f.convert {
+		return nil
+	}
+	s, ok := av[f.name].(*types.AttributeValueMemberS)
+	if !ok {
+		return nil
+	}
+	t, err := time.Parse(time.RFC3339Nano, s.Value)
+	if err != nil {
+		return errors.Wrapf(err, "invalid ttl attribute %s", f.name)
+	}
+	if t.IsZero() {
+		delete(av, f.name)
+		return nil
+	}
+	av[f.name] = &types.AttributeValueMemberN{Value: strconv.FormatInt(t.Unix(), 10)}
+	return nil
+}
+
+// decode returns av with the TTL attribute converted from epoch seconds into the string attributevalue unmarshals time.Time from.
+// av is not modified.
+func (f *ttlField) decode(av map[string]types.AttributeValue) map[string]types.AttributeValue {
+	if f == nil || !f.convert {
+		return av
+	}
+	n, ok := av[f.name].(*types.AttributeValueMemberN)
+	if !ok {
+		return av
+	}
+	sec, err := strconv.ParseInt(n.Value, 10, 64)
+	if err != nil {
+		// attributevalue reports the invalid value
+		return av
+	}
+	res := make(map[string]types.AttributeValue, len(av))
+	for k, v := range av {
+		res[k] = v
+	}
+	res[f.name] = &types.AttributeValueMemberS{Value: time.Unix(sec, 0).UTC().Format(time.RFC3339Nano)}
+	return res
+}
+
+// expired reports whether the item av has expired at t. The items without the TTL attribute never expire.
+func (f *ttlField) expired(av map[string]types.AttributeValue, t time.Time) bool {
+	if f == nil {
+		return false
+	}
+	n, ok := av[f.name].(*types.AttributeValueMemberN)
+	if !ok {
+		return false
+	}
+	sec, err := strconv.ParseInt(n.Value, 10, 64)
+	return err == nil && sec <= t.Unix()
+}
+
+// epoch returns the epoch seconds of the value v of the field. It returns false if v is the zero time or nil.
+func (f *ttlField) epoch(v reflect.Value) (int64, bool) {
+	for v.Kind() == reflect.Pointer {
+		if v.IsNil() {
+			return 0, false
+		}
+		v = v.Elem()
+	}
+	t := v.Interface().(time.Time)
+	return t.Unix(), !t.IsZero()
+}
+
+// timeToLiveOf returns the attribute name of the field of V tagged with `dorm:"ttl"`, or empty if there is none.
+func timeToLiveOf[V ItemType]() string {
+	s, err := schemaOf[V]()
+	if err != nil {
+		return ""
+	}
+	return s.ttl.attributeName()
+}
+
+// skipExpired adds the filter that skips the items of V which have expired at the current time to p.
+// p is returned as is if V has no TTL attribute.
+func skipExpired[V ItemType](p exprParts) (exprParts, error) {
+	s, err := schemaOf[V]()
+	if err != nil {
+		return p, err
+	}
+	if s.ttl == nil {
+		return p, nil
+	}
+	name := expression.Name(s.ttl.name)
+	filter := name.AttributeNotExists().Or(name.GreaterThan(expression.Value(now().Unix())))
+	return p.merge(ttlPrefix, expression.NewBuilder().WithFilter(filter))
+}
@@ -0,0 +1,171 @@
+package dorm
+
+import (
+	"context"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/expression"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func testTimeToLive(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+
+	db, err := ddbMain.conn()
+	assert.NoError(t, err)
+
+	_, err = CreateTable[ttlTestItem](ctx, db)
+	assert.NoError(t, err)
+	ttl, err := db.DescribeTimeToLive(ctx, &dynamodb.DescribeTimeToLiveInput{TableName: aws.String(ttlTestItem{}.TableName())})
+	assert.NoError(t, err)
+	assert.Equal(t, "expires_at", aws.ToString(ttl.TimeToLiveDescription.AttributeName))
+	assert.NoError(t, CheckTable[ttlTestItem](ctx, db))
+
+	expiresAt := time.Now().Add(time.Hour).Truncate(time.Second).UTC()
+	items := []ttlTestItem{
+		{ID: "live", ExpiresAt: expiresAt},
+		{ID: "expired", ExpiresAt: time.Now().Add(-time.Hour)},
+		{ID: "forever"},
+	}
+	for _, item := range items {
+		_, err := PutItem(ctx, db, item, expression.Expression{})
+		assert.NoError(t, err)
+	}
+
+	// The attribute is stored in epoch seconds, and the zero time is not stored
+	raw, err := db.GetItem(ctx, &dynamodb.GetItemInput{
+		TableName: aws.String(ttlTestItem{}.TableName()),
+		Key:       map[string]types.AttributeValue{"id": &types.AttributeValueMemberS{Value: "live"}},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, &types.AttributeValueMemberN{Value: strconv.FormatInt(expiresAt.Unix(), 10)}, raw.Item["expires_at"])
+	raw, err = db.GetItem(ctx, &dynamodb.GetItemInput{
+		TableName: aws.String(ttlTestItem{}.TableName()),
+		Key:       map[string]types.AttributeValue{"id": &types.AttributeValueMemberS{Value: "forever"}},
+	})
+	assert.NoError(t, err)
+	assert.NotContains(t, raw.Item, "expires_at")
+
+	got, err := GetItem[ttlTestItem](ctx, db, ttlTestPrimaryIndex{ID: "live"}, expression.Expression{}, WithGetSkipExpired())
+	assert.NoError(t, err)
+	assert.True(t, expiresAt.Equal(got.ExpiresAt))
+
+	got, err = GetItem[ttlTestItem](ctx, db, ttlTestPrimaryIndex{ID: "expired"}, expression.Expression{})
+	assert.NoError(t, err)
+	assert.Equal(t, "expired", got.ID)
+	_, err = GetItem[ttlTestItem](ctx, db, ttlTestPrimaryIndex{ID: "expired"}, expression.Expression{}, WithGetSkipExpired())
+	assert.ErrorIs(t, err, ErrItemNotFound)
+
+	all, err := ScanAll[ttlTestItem](ctx, db, expression.Expression{})
+	assert.NoError(t, err)
+	assert.Len(t, all, 3)
+	live, err := ScanAll[ttlTestItem](ctx, db, expression.Expression{}, WithScanSkipExpired())
+	assert.NoError(t, err)
+	assert.ElementsMatch(t, []string{"live", "forever"}, ttlTestIDs(live))
+
+	expr := mustBuildExpr(expression.NewBuilder().WithKeyCondition(expression.Key("id").Equal(expression.Value("expired"))))
+	found, err := QueryAll[ttlTestItem](ctx, db, expr, WithSkipExpired())
+	assert.NoError(t, err)
+	assert.Empty(t, found)
+
+	// AttributeSetAll sets the attribute in epoch seconds as well
+	update, err := AttributeSetAll(ttlTestItem{ID: "expired", ExpiresAt: expiresAt})
+	assert.NoError(t, err)
+	_, err = UpdateItem[ttlTestItem](ctx, db, ttlTestPrimaryIndex{ID: "expired"}, mustBuildExpr(expression.NewBuilder().WithUpdate(update)))
+	assert.NoError(t, err)
+	got, err = GetItem[ttlTestItem](ctx, db, ttlTestPrimaryIndex{ID: "expired"}, expression.Expression{}, WithGetSkipExpired())
+	assert.NoError(t, err)
+	assert.True(t, expiresAt.Equal(got.ExpiresAt))
+}
+
+func TestTTLField(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		schema func() (*schema, error)
+		want   string
+		err    bool
+	}{
+		"time":     {schema: schemaOf[ttlTestItem], want: "expires_at"},
+		"integer":  {schema: schemaOf[ttlTestEpochItem], want: "expires_at"},
+		"invalid":  {schema: schemaOf[ttlTestInvalidItem], err: true},
+		"no field": {schema: schemaOf[testItem]},
+	}
+
+	for name, tt := range tests {
+		tt := tt
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			s, err := tt.schema()
+			if tt.err {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, s.ttl.attributeName())
+		})
+	}
+
+	t.Run("round trip", func(t *testing.T) {
+		t.Parallel()
+
+		at := time.Date(2030, 1, 2, 3, 4, 5, 0, time.UTC)
+		av, err := marshalItem(ttlTestItem{ID: "id", ExpiresAt: at})
+		assert.NoError(t, err)
+		assert.Equal(t, &types.AttributeValueMemberN{Value: "1893553445"}, av["expires_at"])
+
+		var got ttlTestItem
+		assert.NoError(t, unmarshalItem(av, &got))
+		assert.True(t, at.Equal(got.ExpiresAt))
+
+		// The integer field is stored as it is
+		av, err = marshalItem(ttlTestEpochItem{ID: "id", ExpiresAt: 1893553445})
+		assert.NoError(t, err)
+		assert.Equal(t, &types.AttributeValueMemberN{Value: "1893553445"}, av["expires_at"])
+	})
+}
+
+func ttlTestIDs(items []ttlTestItem) []string {
+	res := make([]string, len(items))
+	for i, item := range items {
+		res[i] = item.ID
+	}
+	return res
+}
+
+// ttlTestItem expires at ExpiresAt.
+type ttlTestItem struct {
+	Item      `dynamodbav:"-"`
+	ID        string    `dynamodbav:"id" dorm:"hash"`
+	ExpiresAt time.Time `dynamodbav:"expires_at" dorm:"ttl"`
+}
+
+func (i ttlTestItem) TableName() string { return "test-ttl" }
+
+type ttlTestPrimaryIndex struct {
+	PrimaryIndex `dynamodbav:"-"`
+	ID           string `dynamodbav:"id"`
+}
+
+type ttlTestEpochItem struct {
+	Item      `dynamodbav:"-"`
+	ID        string `dynamodbav:"id" dorm:"hash"`
+	ExpiresAt int64  `dynamodbav:"expires_at" dorm:"ttl"`
+}
+
+func (i ttlTestEpochItem) TableName() string { return "test-ttl" }
+
+type ttlTestInvalidItem struct {
+	Item      `dynamodbav:"-"`
+	ID        string `dynamodbav:"id" dorm:"hash"`
+	ExpiresAt string `dynamodbav:"expires_at" dorm:"ttl"`
+}
+
+func (i ttlTestInvalidItem) TableName() string { return "test-ttl" }
@@ -1,5 +1,7 @@
 package dorm
 
+import "time"
+
 // testItemTableName Name of test Table
 const testItemTableName = "test-item"
 
@@ -72,9 +74,9 @@ var testItemColumns = struct {
 // testItem testItem Table structure
 type testItem struct {
 	Item         `dynamodbav:"-"`
-	HashKey      string    `dynamodbav:"hash_key"`
-	GSIHashKey   string    `dynamodbav:"gsi_hash_key"`
-	GSIRangeKey  string    `dynamodbav:"gsi_range_key"`
+	HashKey      string    `dynamodbav:"hash_key" dorm:"hash"`
+	GSIHashKey   string    `dynamodbav:"gsi_hash_key" dorm:"gsi=global-secondary-index:hash"`
+	GSIRangeKey  string    `dynamodbav:"gsi_range_key" dorm:"gsi=global-secondary-index:range"`
 	FilterKey    string    `dynamodbav:"filter_key"`
 	Str          string    `dynamodbav:"str"`
 	Int8         int8      `dynamodbav:"int8"`
@@ -118,3 +120,46 @@ type testItemGSI struct {
 func (e testItem) TableName() string {
 	return testItemTableName
 }
+
+// testVersionedItemTableName Name of test Table with a version field
+const testVersionedItemTableName = "test-versioned-item"
+
+// testVersionedItem testVersionedItem Table structure
+type testVersionedItem struct {
+	Item    `dynamodbav:"-"`
+	HashKey string `dynamodbav:"hash_key" dorm:"hash"`
+	Str     string `dynamodbav:"str"`
+	Version int64  `dynamodbav:"version" dorm:"version"`
+}
+
+// testVersionedItemPrimaryIndex PrimaryIndex of testVersionedItem table
+type testVersionedItemPrimaryIndex struct {
+	PrimaryIndex `dynamodbav:"-"`
+	HashKey      string `dynamodbav:"hash_key"`
+}
+
+func (e testVersionedItem) TableName() string {
+	return testVersionedItemTableName
+}
+
+// testTimestampedItemTableName Name of test Table with timestamp fields
+const testTimestampedItemTableName = "test-timestamped-item"
+
+// testTimestampedItem testTimestampedItem Table structure
+type testTimestampedItem struct {
+	Item      `dynamodbav:"-"`
+	HashKey   string     `dynamodbav:"hash_key" dorm:"hash"`
+	Str       string     `dynamodbav:"str"`
+	CreatedAt time.Time  `dynamodbav:"created_at" dorm:"created_at"`
+	UpdatedAt *time.Time `dynamodbav:"updated_at" dorm:"updated_at"`
+}
+
+// testTimestampedItemPrimaryIndex PrimaryIndex of testTimestampedItem table
+type testTimestampedItemPrimaryIndex struct {
+	PrimaryIndex `dynamodbav:"-"`
+	HashKey      string `dynamodbav:"hash_key"`
+}
+
+func (e testTimestampedItem) TableName() string {
+	return testTimestampedItemTableName
+}
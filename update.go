@@ -3,45 +3,111 @@ package dorm
 import (
 	"context"
 
-	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
 	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/expression"
 	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
 	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
 )
 
+// UpdateItemOptions UpdateItem options for UpdateItem function
+type UpdateItemOptions struct {
+	// ReturnValues chooses the attributes UpdateItem returns. The default is ALL_NEW.
+	// With UPDATED_OLD or UPDATED_NEW, only the updated attributes of the returned item are set.
+	ReturnValues types.ReturnValue
+	// ReturnValuesOnConditionCheckFailure is set to ALL_OLD to get the current item from ConditionFailedError.
+	ReturnValuesOnConditionCheckFailure types.ReturnValuesOnConditionCheckFailure
+	// ExpectedVersion is the version the item must have to be updated, if V has a version field.
+	ExpectedVersion *int64
+}
+
+// UpdateOptionFunc UpdateItem option function
+type UpdateOptionFunc func(*UpdateItemOptions)
+
+// WithUpdateReturnValues sets the ReturnValues for UpdateItemOptions.
+func WithUpdateReturnValues(rv types.ReturnValue) UpdateOptionFunc {
+	return func(opts *UpdateItemOptions) {
+		opts.ReturnValues = rv
+	}
+}
+
+// WithUpdateReturnValuesOnConditionCheckFailure sets the ReturnValuesOnConditionCheckFailure for UpdateItemOptions.
+func WithUpdateReturnValuesOnConditionCheckFailure(rv types.ReturnValuesOnConditionCheckFailure) UpdateOptionFunc {
+	return func(opts *UpdateItemOptions) {
+		opts.ReturnValuesOnConditionCheckFailure = rv
+	}
+}
+
+// WithUpdateExpectedVersion sets the ExpectedVersion for UpdateItemOptions.
+func WithUpdateExpectedVersion(version int64) UpdateOptionFunc {
+	return func(opts *UpdateItemOptions) {
+		opts.ExpectedVersion = &version
+	}
+}
+
 // UpdateItem Update an item
 //
+// It returns the item chosen by ReturnValues, or nil if there are no such attributes.
+//
+// If V has a field tagged with `dorm:"version"`, ExpectedVersion is required and the stored version is incremented.
+// If the stored version is different, ErrVersionConflict is returned. An item that doesn't exist yet is created.
+// The field tagged with `dorm:"updated_at"` is set to the current time, and the field tagged with `dorm:"created_at"`
+// is set only if the item doesn't have it yet, unless the expression already updates them.
+// The field tagged with `dorm:"entity=name"` is set to the name of the entity type as well,
+// and an item of another entity type is not updated and ErrConditionFailed is returned.
+//
 // https://pkg.go.dev/github.com/aws/aws-sdk-go-v2/service/dynamodb#Client.UpdateItem
 // https://docs.aws.amazon.com/en_us/amazondynamodb/latest/APIReference/API_UpdateItem.html
-func UpdateItem[V ItemType](ctx context.Context, db *dynamodb.Client, idx PrimaryIndex, expr expression.Expression) (*V, error) {
+func UpdateItem[V ItemType](ctx context.Context, db DynamoDBAPI, idx PrimaryIndex, expr expression.Expression, opts ...UpdateOptionFunc) (*V, error) {
 
-	key, err := buildIndex(idx)
+	o := UpdateItemOptions{
+		ReturnValues: types.ReturnValueAllNew,
+	}
+
+	for _, f := range opts {
+		f(&o)
+	}
+
+	key, err := primaryKeyOf[V](idx)
 	if err != nil {
 		return nil, err
 	}
 
-	input := &dynamodb.UpdateItemInput{
-		Key:                       key,
-		TableName:                 getFullTableName[V](),
-		ConditionExpression:       expr.Condition(),
-		ExpressionAttributeNames:  expr.Names(),
-		ExpressionAttributeValues: expr.Values(),
-		UpdateExpression:          expr.Update(),
-		ReturnValues:              types.ReturnValueAllNew,
+	if err := requireExpectedVersion[V]("UpdateItem", "WithUpdateExpectedVersion", o.ExpectedVersion); err != nil {
+		return nil, err
 	}
 
-	output, err := db.UpdateItem(ctx, input)
+	parts, check, err := versioned[V](partsOf(expr), o.ExpectedVersion, true)
+	if err != nil {
+		return nil, err
+	}
 
+	parts, err = timestamped[V](parts)
 	if err != nil {
 		return nil, err
 	}
 
-	var val V
-	err = attributevalue.UnmarshalMap(output.Attributes, &val)
+	parts, err = entityStamped[V](parts)
 	if err != nil {
 		return nil, err
 	}
 
-	return &val, nil
+	input := &dynamodb.UpdateItemInput{
+		Key:                       key,
+		TableName:                 getFullTableName[V](),
+		ConditionExpression:       parts.Condition,
+		ExpressionAttributeNames:  parts.Names,
+		ExpressionAttributeValues: parts.Values,
+		UpdateExpression:          parts.Update,
+		ReturnValues:              o.ReturnValues,
+
+		ReturnValuesOnConditionCheckFailure: returnValuesOnConditionCheckFailure(o.ReturnValuesOnConditionCheckFailure, check),
+	}
+
+	output, err := db.UpdateItem(ctx, input)
+
+	if err != nil {
+		return nil, newOperationError("UpdateItem", input.TableName, key, conditionFailed[V](err, check))
+	}
+
+	return unmarshalAttributes[V](output.Attributes)
 
 }
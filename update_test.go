@@ -6,6 +6,7 @@ import (
 
 	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/expression"
 	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
 	"github.com/google/go-cmp/cmp"
 	"github.com/google/go-cmp/cmp/cmpopts"
 	"github.com/stretchr/testify/assert"
@@ -18,6 +19,7 @@ func testtestItemUpdateItem(t *testing.T) {
 		db   *dynamodb.Client
 		idx  PrimaryIndex
 		expr expression.Expression
+		opts []UpdateOptionFunc
 	}
 	tests := map[string]struct {
 		args args
@@ -45,7 +47,7 @@ func testtestItemUpdateItem(t *testing.T) {
 				assert.NoError(t, err)
 
 				// put item
-				err = PutItem(args.ctx, args.db, o, expression.Expression{})
+				_, err = PutItem(args.ctx, args.db, o, expression.Expression{})
 				assert.NoError(t, err)
 
 				// set args
@@ -58,11 +60,8 @@ func testtestItemUpdateItem(t *testing.T) {
 				args.idx = testItemPrimaryIndex{HashKey: hashkey}
 				want = &o
 
-				keyskipper := func(name string) bool {
-					return name == testItemColumns.HashKey
-				}
-
-				upd := AttributeSetAll(o, keyskipper)
+				upd, err := AttributeSetAll(o, SkipKeysOf[testItemPrimaryIndex]())
+				assert.NoError(t, err)
 
 				args.expr, err = expression.NewBuilder().WithUpdate(upd).Build()
 				assert.NoError(t, err)
@@ -72,13 +71,73 @@ func testtestItemUpdateItem(t *testing.T) {
 				cmpopts.IgnoreUnexported(testItem{}),
 			},
 		},
+		"success with updated old": {
+			args: args{
+				ctx:  context.Background(),
+				opts: []UpdateOptionFunc{WithUpdateReturnValues(types.ReturnValueUpdatedOld)},
+			},
+			setup: func(t *testing.T, args *args) (want *testItem) {
+				var err error
+
+				// init db
+				args.db, err = ddbMain.conn()
+				assert.NoError(t, err)
+
+				// randomize
+				o := testItem{}
+				err = RandomizeDDBStruct(&o)
+				assert.NoError(t, err)
+
+				// put item
+				_, err = PutItem(args.ctx, args.db, o, expression.Expression{})
+				assert.NoError(t, err)
+
+				// set args
+				args.idx = testItemPrimaryIndex{HashKey: o.HashKey}
+				upd := expression.Set(expression.Name(testItemColumns.Str), expression.Value(o.Str+"-updated"))
+				args.expr, err = expression.NewBuilder().WithUpdate(upd).Build()
+				assert.NoError(t, err)
+
+				// only the updated attribute is returned
+				return &testItem{Str: o.Str}
+			},
+			opts: []cmp.Option{
+				cmpopts.IgnoreUnexported(testItem{}),
+			},
+		},
+		"success with none": {
+			args: args{
+				ctx:  context.Background(),
+				opts: []UpdateOptionFunc{WithUpdateReturnValues(types.ReturnValueNone)},
+			},
+			setup: func(t *testing.T, args *args) (want *testItem) {
+				var err error
+
+				// init db
+				args.db, err = ddbMain.conn()
+				assert.NoError(t, err)
+
+				// randomize
+				o := testItem{}
+				err = RandomizeDDBStruct(&o)
+				assert.NoError(t, err)
+
+				// set args
+				args.idx = testItemPrimaryIndex{HashKey: o.HashKey}
+				upd := expression.Set(expression.Name(testItemColumns.Str), expression.Value(o.Str))
+				args.expr, err = expression.NewBuilder().WithUpdate(upd).Build()
+				assert.NoError(t, err)
+
+				return nil
+			},
+		},
 	}
 	for name, tt := range tests {
 		tt := tt
 		t.Run(name, func(t *testing.T) {
 			t.Parallel()
 			tt.want = tt.setup(t, &tt.args)
-			got, err := UpdateItem[testItem](tt.args.ctx, tt.args.db, tt.args.idx, tt.args.expr)
+			got, err := UpdateItem[testItem](tt.args.ctx, tt.args.db, tt.args.idx, tt.args.expr, tt.args.opts...)
 			if (err != nil) != tt.wantErr {
 				t.Errorf("wantErr is %t, but err is %v", tt.wantErr, err)
 			}
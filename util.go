@@ -11,6 +11,8 @@ import (
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/expression"
 	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/cockroachdb/errors"
 )
 
 const structTag = "dynamodbav"
@@ -22,117 +24,256 @@ var UniqueLimitQueryOptFunc = func(qo *QueryOptions) {
 }
 
 // ProjectionAll constructs a ProjectionBuilder that returns all values of a struct, with the ability to adjust the fields specified by optfns.
+// The fields of embedded structs are included, and the fields of nested structs are projected by document paths such as "address.city".
+// It returns an error if T is not a struct or there is no attribute to project.
 //
 // optFns: A function that specifies the fields to be expanded. Exclude when returning true.
-func ProjectionAll[T ItemType](skipper ...func(name string) bool) expression.ProjectionBuilder {
-	// Create the entity to search for tags
-	str := *new(T)
+// It is called with the document path of each attribute.
+func ProjectionAll[T ItemType](skipper ...func(name string) bool) (expression.ProjectionBuilder, error) {
+	// Get the attributes of the struct
+	s, err := schemaOf[T]()
+	if err != nil {
+		return expression.ProjectionBuilder{}, err
+	}
 
-	// Get the type information of the struct
-	rtStr := reflect.TypeOf(str)
+	names := projectionNames(s.fields, "", skipper)
+	if len(names) == 0 {
+		return expression.ProjectionBuilder{}, errors.Newf("%T has no attribute to project", *new(T))
+	}
+	// Convert NameBuilder slice to ProjectionBuilder
+	res := expression.NamesList(names[0], names[1:]...)
 
+	return res, nil
+}
+
+func projectionNames(fields []attributeField, prefix string, skipper []func(name string) bool) []expression.NameBuilder {
 	var names []expression.NameBuilder
-	// Loop through all fields of the struct
-	for i := 0; i < rtStr.NumField(); i++ {
-		// Get field information
-		f := rtStr.Field(i)
-		// Get tag information
-		name := f.Tag.Get(structTag)
-		// If the tag is set and not "-", add it
-		if name != "" && name != ignoreStructTag {
-			if !isSkip(skipper, name) {
-				// Convert the value of the tag to NameBuilder
-				names = append(names, expression.Name(name))
-			}
-		} else {
+	for _, f := range fields {
+		path := prefix + f.name
+		if isSkip(skipper, path) {
 			continue
 		}
+		if len(f.children) > 0 {
+			names = append(names, projectionNames(f.children, path+".", skipper)...)
+			continue
+		}
+		// Convert the path to NameBuilder
+		names = append(names, expression.Name(path))
 	}
-	// Convert NameBuilder slice to ProjectionBuilder
-	res := expression.NamesList(names[0], names[1:]...)
-
-	return res
+	return names
 }
 
 // AttributeSetAll constructs an UpdateBuilder that updates all values of a struct, with the ability to adjust the fields specified by optfns.
+// The field tagged with `dorm:"version"` is skipped, since UpdateItem increments it.
+// The key fields tagged with `dorm:"hash"` or `dorm:"range"` are skipped, since they can't be updated.
+// For the items without key tags, pass SkipKeysOf as a skipper.
+// The fields tagged with `dorm:"updated_at"` and `dorm:"created_at"` are set to the current time,
+// but created_at is kept if the item already has it.
+//
+// The fields of embedded structs are included, and nested structs are set as whole maps.
+// Zero values are set as they are. Use AttributeSetAllWithOptions to skip or remove them, or to set nested fields.
+// It returns an error if str is not a struct or a nil pointer.
 //
 // optFns: A function that specifies the fields to be expanded. Exclude when returning true.
-func AttributeSetAll[T ItemType](str T, skipper ...func(name string) bool) expression.UpdateBuilder {
-	// Get the type information of the struct
-	rtStr := reflect.TypeOf(str)
-	res := expression.UpdateBuilder{}
-	// Loop through all fields of the struct
-	for i := 0; i < rtStr.NumField(); i++ {
-		// Get field information
-		f := rtStr.Field(i)
-		// Get tag information
-		name := f.Tag.Get(structTag)
-		// If the tag is set and not "-", add it
-		if name != "" && name != ignoreStructTag {
-			if !isSkip(skipper, name) {
-				// Get the value of the specified field in the struct
-				val := reflect.ValueOf(str).Field(i)
-				// Add the tag as key and the val as interface{} to the UpdateBuilder
-				res = res.Set(expression.Name(name), expression.Value(val.Interface()))
-			}
-		} else {
-			continue
-		}
+func AttributeSetAll[T ItemType](str T, skipper ...func(name string) bool) (expression.UpdateBuilder, error) {
+	return AttributeSetAllWithOptions(str, WithAttributeSetSkipper(skipper...))
+}
+
+// AttributeSetOptions options for AttributeSetAllWithOptions function
+type AttributeSetOptions struct {
+	// OmitZero skips the fields with zero values, nil pointers, and empty slices and maps.
+	OmitZero bool
+	// OmitEmpty skips the empty fields tagged with `dynamodbav:",omitempty"`, as attributevalue.MarshalMap does.
+	OmitEmpty bool
+	// RemoveNil removes the attributes of the nil pointer fields with REMOVE instead of setting them to NULL.
+	// It takes precedence over OmitZero and OmitEmpty.
+	RemoveNil bool
+	// Nested sets the fields of nested structs by document paths such as "address.city", instead of replacing
+	// the whole maps. The maps must exist in the stored item, or UpdateItem fails with ErrValidation.
+	Nested bool
+	// Skippers are the functions that specify the fields to be excluded. Exclude when returning true.
+	// They are called with the document path of each attribute.
+	Skippers []func(name string) bool
+}
+
+// AttributeSetOptionFunc AttributeSetAllWithOptions option function
+type AttributeSetOptionFunc func(*AttributeSetOptions)
+
+// WithAttributeSetOmitZero sets the OmitZero for AttributeSetOptions.
+func WithAttributeSetOmitZero() AttributeSetOptionFunc {
+	return func(opts *AttributeSetOptions) {
+		opts.OmitZero = true
 	}
+}
 
-	return res
+// WithAttributeSetOmitEmpty sets the OmitEmpty for AttributeSetOptions.
+func WithAttributeSetOmitEmpty() AttributeSetOptionFunc {
+	return func(opts *AttributeSetOptions) {
+		opts.OmitEmpty = true
+	}
 }
 
-func isSkip(f []func(string) bool, name string) bool {
-	for _, fn := range f {
-		if fn(name) {
-			return true
-		}
+// WithAttributeSetRemoveNil sets the RemoveNil for AttributeSetOptions.
+func WithAttributeSetRemoveNil() AttributeSetOptionFunc {
+	return func(opts *AttributeSetOptions) {
+		opts.RemoveNil = true
 	}
-	return false
 }
 
-func splitThread[ARG any](
-	ctx context.Context,
-	db *dynamodb.Client,
-	expr expression.Expression,
-	size int,
-	concurrency int,
-	fun func(context.Context, *dynamodb.Client, expression.Expression, []ARG) error,
-	args []ARG,
-) error {
-	threadnum := (len(args) / size) + 1
-	eg, ctx := errgroup.WithContext(ctx)
-	if concurrency > 0 {
-		eg.SetLimit(concurrency)
+// WithAttributeSetNested sets the Nested for AttributeSetOptions.
+func WithAttributeSetNested() AttributeSetOptionFunc {
+	return func(opts *AttributeSetOptions) {
+		opts.Nested = true
 	}
+}
 
-	for i := 0; i < threadnum; i++ {
-		start := i * size
-		end := int(math.Min(float64((i+1)*size), float64(len(args))))
-		if start >= end {
+// WithAttributeSetSkipper adds the skippers to AttributeSetOptions.
+func WithAttributeSetSkipper(skipper ...func(name string) bool) AttributeSetOptionFunc {
+	return func(opts *AttributeSetOptions) {
+		opts.Skippers = append(opts.Skippers, skipper...)
+	}
+}
+
+// AttributeSetAllWithOptions constructs an UpdateBuilder that updates all values of a struct as AttributeSetAll does,
+// and handles zero values as specified by opts. For example, a PATCH request can be applied with WithAttributeSetOmitZero.
+func AttributeSetAllWithOptions[T ItemType](str T, opts ...AttributeSetOptionFunc) (expression.UpdateBuilder, error) {
+	o := AttributeSetOptions{}
+	for _, f := range opts {
+		f(&o)
+	}
+
+	// Get the attributes of the struct
+	s, err := schemaOf[T]()
+	if err != nil {
+		return expression.UpdateBuilder{}, err
+	}
+	val, ok := structValue(reflect.ValueOf(str))
+	if !ok {
+		return expression.UpdateBuilder{}, errors.Newf("%T is nil", str)
+	}
+
+	// Timestamps are marshaled as the fields of T, so that the encoding follows their tags such as unixtime
+	stamps := map[string]types.AttributeValue{}
+	t := now()
+	for _, f := range []*attributeField{s.timestamps.createdAt, s.timestamps.updatedAt} {
+		if f == nil {
 			continue
 		}
-		subArgs := args[start:end]
-		eg.Go(func() error {
-			return fun(ctx, db, expr, subArgs)
-		})
+		av, err := timestampValue[T](f, t)
+		if err != nil {
+			return expression.UpdateBuilder{}, err
+		}
+		stamps[f.name] = av
 	}
 
-	if err := eg.Wait(); err != nil {
-		return err
+	return o.set(expression.UpdateBuilder{}, s.fields, val, "", stamps), nil
+}
+
+// set adds the fields of the struct v to res. The roles of the fields are only set at the top level.
+// stamps are the marshaled timestamps by the attribute names.
+func (o AttributeSetOptions) set(res expression.UpdateBuilder, fields []attributeField, v reflect.Value, prefix string, stamps map[string]types.AttributeValue) expression.UpdateBuilder {
+	for _, f := range fields {
+		path := prefix + f.name
+		if isSkip(o.Skippers, path) {
+			continue
+		}
+		if f.role == roleVersion || f.role.isKey() {
+			continue
+		}
+		name := expression.Name(path)
+
+		// Key templates are composed from their fields
+		if f.template != nil {
+			res = res.Set(name, expression.Value(f.template.compose(v, false)))
+			continue
+		}
+
+		// Timestamps are set to the current time, and created_at is kept if it exists
+		if f.role.isTimestamp() {
+			val := expression.Value(stamps[f.name])
+			if f.role == roleCreatedAt {
+				res = res.Set(name, expression.IfNotExists(name, val))
+				continue
+			}
+			res = res.Set(name, val)
+			continue
+		}
+
+		// Get the value of the specified field in the struct. It is zero if an embedded pointer is nil.
+		val, ok := fieldValue(v, f.index)
+		if !ok {
+			val = reflect.Zero(f.Type)
+		}
+		// The discriminator is always the name of the entity type
+		if f.role == roleEntity {
+			if value, _ := tagOptionValue(f.Tag.Get(dormStructTag), entityTagOption); value != "" {
+				res = res.Set(name, expression.Value(value))
+				continue
+			}
+		}
+
+		// The TTL is set in epoch seconds, and the zero time removes it as marshalItem does
+		if f.role == roleTTL {
+			if ttl, err := newTTLField(&f); err == nil && ttl.convert {
+				switch sec, ok := ttl.epoch(val); {
+				case ok:
+					res = res.Set(name, expression.Value(sec))
+				case !o.OmitZero && !(o.OmitEmpty && hasTagOption(f.Tag.Get(structTag), "omitempty")):
+					res = res.Remove(name)
+				}
+				continue
+			}
+		}
+		if o.Nested && len(f.children) > 0 {
+			if sv, ok := structValue(val); ok {
+				res = o.set(res, f.children, sv, path+".", stamps)
+				continue
+			}
+		}
+
+		switch {
+		case o.RemoveNil && val.Kind() == reflect.Pointer && val.IsNil():
+			res = res.Remove(name)
+			continue
+		case o.OmitZero && isEmptyValue(val):
+			continue
+		case o.OmitEmpty && hasTagOption(f.Tag.Get(structTag), "omitempty") && isEmptyValue(val):
+			continue
+		}
+
+		// Add the path as key and the val as interface{} to the UpdateBuilder
+		res = res.Set(name, expression.Value(val.Interface()))
 	}
 
-	return nil
+	return res
+}
+
+// isEmptyValue reports whether v is a zero value, a nil pointer, or an empty slice or map.
+// Empty slices and maps are included, since an empty set is not a valid attribute value.
+func isEmptyValue(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Map, reflect.Slice:
+		return v.Len() == 0
+	}
+	return v.IsZero()
 }
 
-func splitThreadWithReturnValue[V ItemType, ARG any](
+func isSkip(f []func(string) bool, name string) bool {
+	for _, fn := range f {
+		if fn(name) {
+			return true
+		}
+	}
+	return false
+}
+
+func splitThreadWithReturnValue[V any, ARG any](
 	ctx context.Context,
-	db *dynamodb.Client,
+	db DynamoDBAPI,
 	expr expression.Expression,
 	size int,
 	concurrency int,
-	fun func(context.Context, *dynamodb.Client, expression.Expression, []ARG) ([]V, error),
+	fun func(context.Context, DynamoDBAPI, expression.Expression, []ARG) ([]V, error),
 	args []ARG,
 ) ([]V, error) {
 	threadnum := (len(args) / size) + 1
@@ -168,3 +309,86 @@ func splitThreadWithReturnValue[V ItemType, ARG any](
 
 	return res, nil
 }
+
+// batchWriteItem writes the requests to a single table, resubmitting unprocessed requests with backoff.
+// It returns the requests that were still unprocessed after maxAttempts calls.
+func batchWriteItem(ctx context.Context, db DynamoDBAPI, tableName string, reqs []types.WriteRequest, maxAttempts int) ([]types.WriteRequest, error) {
+	if maxAttempts <= 0 {
+		maxAttempts = defaultBatchMaxAttempts
+	}
+
+	for attempt := 1; ; attempt++ {
+		output, err := db.BatchWriteItem(ctx, &dynamodb.BatchWriteItemInput{
+			RequestItems: map[string][]types.WriteRequest{
+				tableName: reqs,
+			},
+		})
+
+		if err != nil {
+			return nil, newOperationError("BatchWriteItem", &tableName, nil, err)
+		}
+
+		reqs = output.UnprocessedItems[tableName]
+		if len(reqs) == 0 {
+			return nil, nil
+		}
+
+		if attempt >= maxAttempts {
+			return reqs, nil
+		}
+
+		if err := sleepWithContext(ctx, backoffDelay(attempt)); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// batchGetItem reads the keys from a single table, resubmitting unprocessed keys with backoff.
+// It returns the items that were read and the keys that were still unprocessed after maxAttempts calls.
+func batchGetItem(ctx context.Context, db DynamoDBAPI, tableName string, req types.KeysAndAttributes, maxAttempts int) ([]map[string]types.AttributeValue, []map[string]types.AttributeValue, error) {
+	if maxAttempts <= 0 {
+		maxAttempts = defaultBatchMaxAttempts
+	}
+
+	var items []map[string]types.AttributeValue
+
+	for attempt := 1; ; attempt++ {
+		output, err := db.BatchGetItem(ctx, &dynamodb.BatchGetItemInput{
+			RequestItems: map[string]types.KeysAndAttributes{
+				tableName: req,
+			},
+		})
+
+		if err != nil {
+			return nil, nil, newOperationError("BatchGetItem", &tableName, nil, err)
+		}
+
+		items = append(items, output.Responses[tableName]...)
+
+		unprocessed, ok := output.UnprocessedKeys[tableName]
+		if !ok || len(unprocessed.Keys) == 0 {
+			return items, nil, nil
+		}
+
+		if attempt >= maxAttempts {
+			return items, unprocessed.Keys, nil
+		}
+
+		if err := sleepWithContext(ctx, backoffDelay(attempt)); err != nil {
+			return nil, nil, err
+		}
+
+		req.Keys = unprocessed.Keys
+	}
+}
+
+// pickAttributes returns the attributes of item that are named in names.
+func pickAttributes(item map[string]types.AttributeValue, names map[string]types.AttributeValue) map[string]types.AttributeValue {
+	res := make(map[string]types.AttributeValue, len(names))
+	for k := range names {
+		if v, ok := item[k]; ok {
+			res[k] = v
+		}
+	}
+	return res
+}
@@ -47,6 +47,17 @@ func TestAttributeSetAll(t *testing.T) {
 	t.Run("success", testAttributeSetAllSuccess)
 	t.Run("success with untaged fields", testAttributeSetAllSuccessUntagged)
 	t.Run("success with not dynamodbav fields", testAttributeSetAllSuccessNotDDBAV)
+	t.Run("success with key tags", testAttributeSetAllSuccessKeyTagged)
+	t.Run("success with SkipKeysOf", testAttributeSetAllSuccessSkipKeysOf)
+}
+
+func TestAttributeSetAllWithOptions(t *testing.T) {
+	t.Run("success", testAttributeSetAllWithOptionsSuccess)
+}
+
+func TestNestedStructs(t *testing.T) {
+	t.Run("ProjectionAll", testProjectionAllNested)
+	t.Run("AttributeSetAll", testAttributeSetAllNested)
 }
 
 func testProjectionAllSuccess(t *testing.T) {
@@ -73,7 +84,11 @@ func testProjectionAllSuccess(t *testing.T) {
 
 	wantErr := false
 
-	if diff := cmp.Diff(want, ProjectionAll[utilTestItem](testarg.optFns...), opts...); len(diff) > 0 && !wantErr {
+	got, err := ProjectionAll[utilTestItem](testarg.optFns...)
+	if (err != nil) != wantErr {
+		t.Fatalf("ProjectionAll() error = %v, wantErr %v", err, wantErr)
+	}
+	if diff := cmp.Diff(want, got, opts...); len(diff) > 0 && !wantErr {
 		t.Errorf("Compare value is mismatch (-want +got):%s\n", diff)
 	}
 
@@ -102,7 +117,11 @@ func testProjectionAllSuccessUntagged(t *testing.T) {
 
 	wantErr := false
 
-	if diff := cmp.Diff(want, ProjectionAll[utilTestItemUntagged](testarg.optFns...), opts...); len(diff) > 0 && !wantErr {
+	got, err := ProjectionAll[utilTestItemUntagged](testarg.optFns...)
+	if (err != nil) != wantErr {
+		t.Fatalf("ProjectionAll() error = %v, wantErr %v", err, wantErr)
+	}
+	if diff := cmp.Diff(want, got, opts...); len(diff) > 0 && !wantErr {
 		t.Errorf("Compare value is mismatch (-want +got):%s\n", diff)
 	}
 
@@ -131,7 +150,11 @@ func testProjectionAllSuccessNotDDBAV(t *testing.T) {
 
 	wantErr := false
 
-	if diff := cmp.Diff(want, ProjectionAll[utilTestItemNotDynamodbav](testarg.optFns...), opts...); len(diff) > 0 && !wantErr {
+	got, err := ProjectionAll[utilTestItemNotDynamodbav](testarg.optFns...)
+	if (err != nil) != wantErr {
+		t.Fatalf("ProjectionAll() error = %v, wantErr %v", err, wantErr)
+	}
+	if diff := cmp.Diff(want, got, opts...); len(diff) > 0 && !wantErr {
 		t.Errorf("Compare value is mismatch (-want +got):%s\n", diff)
 	}
 
@@ -163,7 +186,11 @@ func testAttributeSetAllSuccess(t *testing.T) {
 
 	wantErr := false
 
-	if diff := cmp.Diff(want, AttributeSetAll(testarg.str, testarg.optFns...), opts...); len(diff) > 0 && !wantErr {
+	got, err := AttributeSetAll(testarg.str, testarg.optFns...)
+	if (err != nil) != wantErr {
+		t.Fatalf("AttributeSetAll() error = %v, wantErr %v", err, wantErr)
+	}
+	if diff := cmp.Diff(want, got, opts...); len(diff) > 0 && !wantErr {
 		t.Errorf("Compare value is mismatch (-want +got):%s\n", diff)
 	}
 
@@ -195,7 +222,11 @@ func testAttributeSetAllSuccessUntagged(t *testing.T) {
 
 	wantErr := false
 
-	if diff := cmp.Diff(want, AttributeSetAll(testarg.str, testarg.optFns...), opts...); len(diff) > 0 && !wantErr {
+	got, err := AttributeSetAll(testarg.str, testarg.optFns...)
+	if (err != nil) != wantErr {
+		t.Fatalf("AttributeSetAll() error = %v, wantErr %v", err, wantErr)
+	}
+	if diff := cmp.Diff(want, got, opts...); len(diff) > 0 && !wantErr {
 		t.Errorf("Compare value is mismatch (-want +got):%s\n", diff)
 	}
 
@@ -228,11 +259,268 @@ func testAttributeSetAllSuccessNotDDBAV(t *testing.T) {
 
 	wantErr := false
 
-	if diff := cmp.Diff(want, AttributeSetAll(testarg.str, testarg.optFns...), opts...); len(diff) > 0 && !wantErr {
+	got, err := AttributeSetAll(testarg.str, testarg.optFns...)
+	if (err != nil) != wantErr {
+		t.Fatalf("AttributeSetAll() error = %v, wantErr %v", err, wantErr)
+	}
+	if diff := cmp.Diff(want, got, opts...); len(diff) > 0 && !wantErr {
+		t.Errorf("Compare value is mismatch (-want +got):%s\n", diff)
+	}
+}
+
+func testAttributeSetAllSuccessKeyTagged(t *testing.T) {
+	t.Parallel()
+
+	type args struct {
+		str    utilTestItemKeyTagged
+		optFns []func(name string) bool
+	}
+
+	testarg := args{
+		str: utilTestItemKeyTagged{
+			HashKey:  "hash",
+			RangeKey: "range",
+			String:   "string",
+			Bool:     true,
+		},
+		optFns: []func(string) bool{},
+	}
+
+	want := expression.UpdateBuilder{}.Set(expression.Name("string"), expression.Value("string")).
+		Set(expression.Name("bool"), expression.Value(true))
+	opts := []cmp.Option{
+		cmp.Comparer(cmpUpdateExpr),
+	}
+
+	wantErr := false
+
+	got, err := AttributeSetAll(testarg.str, testarg.optFns...)
+	if (err != nil) != wantErr {
+		t.Fatalf("AttributeSetAll() error = %v, wantErr %v", err, wantErr)
+	}
+	if diff := cmp.Diff(want, got, opts...); len(diff) > 0 && !wantErr {
+		t.Errorf("Compare value is mismatch (-want +got):%s\n", diff)
+	}
+}
+
+func testAttributeSetAllSuccessSkipKeysOf(t *testing.T) {
+	t.Parallel()
+
+	type args struct {
+		str    utilTestItem
+		optFns []func(name string) bool
+	}
+
+	testarg := args{
+		str: utilTestItem{
+			String: "string",
+			Bool:   true,
+			Int:    1,
+		},
+		optFns: []func(string) bool{SkipKeysOf[utilTestPrimaryIndex]()},
+	}
+
+	want := expression.UpdateBuilder{}.Set(expression.Name("bool"), expression.Value(true)).
+		Set(expression.Name("int"), expression.Value(1))
+	opts := []cmp.Option{
+		cmp.Comparer(cmpUpdateExpr),
+	}
+
+	wantErr := false
+
+	got, err := AttributeSetAll(testarg.str, testarg.optFns...)
+	if (err != nil) != wantErr {
+		t.Fatalf("AttributeSetAll() error = %v, wantErr %v", err, wantErr)
+	}
+	if diff := cmp.Diff(want, got, opts...); len(diff) > 0 && !wantErr {
 		t.Errorf("Compare value is mismatch (-want +got):%s\n", diff)
 	}
 }
 
+func testAttributeSetAllWithOptionsSuccess(t *testing.T) {
+	t.Parallel()
+
+	str := "string"
+	item := utilTestItemOptional{
+		String:    "",
+		Int:       1,
+		Ptr:       nil,
+		PtrSet:    &str,
+		OmitEmpty: "",
+		Slice:     []string{},
+	}
+
+	tests := map[string]struct {
+		opts []AttributeSetOptionFunc
+		want expression.UpdateBuilder
+	}{
+		"no options": {
+			want: expression.UpdateBuilder{}.Set(expression.Name("string"), expression.Value("")).
+				Set(expression.Name("int"), expression.Value(1)).
+				Set(expression.Name("ptr"), expression.Value((*string)(nil))).
+				Set(expression.Name("ptr_set"), expression.Value(&str)).
+				Set(expression.Name("omit_empty"), expression.Value("")).
+				Set(expression.Name("slice"), expression.Value([]string{})),
+		},
+		"omit zero": {
+			opts: []AttributeSetOptionFunc{WithAttributeSetOmitZero()},
+			want: expression.UpdateBuilder{}.Set(expression.Name("int"), expression.Value(1)).
+				Set(expression.Name("ptr_set"), expression.Value(&str)),
+		},
+		"omit empty": {
+			opts: []AttributeSetOptionFunc{WithAttributeSetOmitEmpty()},
+			want: expression.UpdateBuilder{}.Set(expression.Name("string"), expression.Value("")).
+				Set(expression.Name("int"), expression.Value(1)).
+				Set(expression.Name("ptr"), expression.Value((*string)(nil))).
+				Set(expression.Name("ptr_set"), expression.Value(&str)).
+				Set(expression.Name("slice"), expression.Value([]string{})),
+		},
+		"remove nil": {
+			opts: []AttributeSetOptionFunc{WithAttributeSetRemoveNil(), WithAttributeSetOmitZero()},
+			want: expression.UpdateBuilder{}.Set(expression.Name("int"), expression.Value(1)).
+				Remove(expression.Name("ptr")).
+				Set(expression.Name("ptr_set"), expression.Value(&str)),
+		},
+		"skipper": {
+			opts: []AttributeSetOptionFunc{WithAttributeSetOmitZero(), WithAttributeSetSkipper(func(name string) bool { return name == "int" })},
+			want: expression.UpdateBuilder{}.Set(expression.Name("ptr_set"), expression.Value(&str)),
+		},
+	}
+	for name, tt := range tests {
+		tt := tt
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			opts := []cmp.Option{
+				cmp.Comparer(cmpUpdateExpr),
+			}
+			got, err := AttributeSetAllWithOptions(item, tt.opts...)
+			if err != nil {
+				t.Fatalf("AttributeSetAllWithOptions() error = %v", err)
+			}
+			if diff := cmp.Diff(tt.want, got, opts...); len(diff) > 0 {
+				t.Errorf("Compare value is mismatch (-want +got):%s\n", diff)
+			}
+		})
+	}
+}
+
+func testProjectionAllNested(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		proj    func() (expression.ProjectionBuilder, error)
+		want    []string
+		wantErr bool
+	}{
+		"embedded and nested": {
+			proj: func() (expression.ProjectionBuilder, error) { return ProjectionAll[utilTestItemNested]() },
+			want: []string{"string", "embedded", "address.city", "address.zip_code", "ptr_address.city", "ptr_address.zip_code"},
+		},
+		"pointer": {
+			proj: func() (expression.ProjectionBuilder, error) { return ProjectionAll[*utilTestItemNested]() },
+			want: []string{"string", "embedded", "address.city", "address.zip_code", "ptr_address.city", "ptr_address.zip_code"},
+		},
+		"skip document path": {
+			proj: func() (expression.ProjectionBuilder, error) {
+				return ProjectionAll[utilTestItemNested](func(name string) bool { return name == "address.zip_code" || name == "ptr_address" })
+			},
+			want: []string{"string", "embedded", "address.city"},
+		},
+		"no attributes": {
+			proj:    func() (expression.ProjectionBuilder, error) { return ProjectionAll[utilTestItemNoAttributes]() },
+			wantErr: true,
+		},
+	}
+	for name, tt := range tests {
+		tt := tt
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			got, err := tt.proj()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ProjectionAll() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+
+			names := make([]expression.NameBuilder, 0, len(tt.want))
+			for _, n := range tt.want {
+				names = append(names, expression.Name(n))
+			}
+			want := expression.NamesList(names[0], names[1:]...)
+			if diff := cmp.Diff(want, got, cmp.Comparer(cmpExprProj)); len(diff) > 0 {
+				t.Errorf("Compare value is mismatch (-want +got):%s\n", diff)
+			}
+		})
+	}
+}
+
+func testAttributeSetAllNested(t *testing.T) {
+	t.Parallel()
+
+	item := utilTestItemNested{
+		String:           "string",
+		utilTestEmbedded: utilTestEmbedded{Embedded: "embedded"},
+		Address:          utilTestAddress{City: "city", ZipCode: "zip"},
+	}
+
+	tests := map[string]struct {
+		set     func() (expression.UpdateBuilder, error)
+		want    expression.UpdateBuilder
+		wantErr bool
+	}{
+		"whole maps": {
+			set: func() (expression.UpdateBuilder, error) { return AttributeSetAll(item) },
+			want: expression.UpdateBuilder{}.Set(expression.Name("string"), expression.Value("string")).
+				Set(expression.Name("embedded"), expression.Value("embedded")).
+				Set(expression.Name("address"), expression.Value(item.Address)).
+				Set(expression.Name("ptr_address"), expression.Value((*utilTestAddress)(nil))),
+		},
+		"nested": {
+			set: func() (expression.UpdateBuilder, error) {
+				return AttributeSetAllWithOptions(item, WithAttributeSetNested(), WithAttributeSetRemoveNil())
+			},
+			want: expression.UpdateBuilder{}.Set(expression.Name("string"), expression.Value("string")).
+				Set(expression.Name("embedded"), expression.Value("embedded")).
+				Set(expression.Name("address.city"), expression.Value("city")).
+				Set(expression.Name("address.zip_code"), expression.Value("zip")).
+				Remove(expression.Name("ptr_address")),
+		},
+		"pointer": {
+			set: func() (expression.UpdateBuilder, error) {
+				return AttributeSetAllWithOptions(&item, WithAttributeSetNested(), WithAttributeSetOmitZero())
+			},
+			want: expression.UpdateBuilder{}.Set(expression.Name("string"), expression.Value("string")).
+				Set(expression.Name("embedded"), expression.Value("embedded")).
+				Set(expression.Name("address.city"), expression.Value("city")).
+				Set(expression.Name("address.zip_code"), expression.Value("zip")),
+		},
+		"nil pointer": {
+			set:     func() (expression.UpdateBuilder, error) { return AttributeSetAll((*utilTestItemNested)(nil)) },
+			wantErr: true,
+		},
+	}
+	for name, tt := range tests {
+		tt := tt
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			got, err := tt.set()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("AttributeSetAll() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if diff := cmp.Diff(tt.want, got, cmp.Comparer(cmpUpdateExpr)); len(diff) > 0 {
+				t.Errorf("Compare value is mismatch (-want +got):%s\n", diff)
+			}
+		})
+	}
+}
+
 type utilTestItem struct {
 	Item
 	String string `dynamodbav:"string"`
@@ -263,3 +551,56 @@ func (i utilTestItemUntagged) TableName() string {
 func (i utilTestItemNotDynamodbav) TableName() string {
 	return "test"
 }
+
+type utilTestItemKeyTagged struct {
+	Item
+	HashKey  string `dynamodbav:"hash_key" dorm:"hash"`
+	RangeKey string `dynamodbav:"range_key" dorm:"range"`
+	String   string `dynamodbav:"string"`
+	Bool     bool   `dynamodbav:"bool"`
+}
+
+func (i utilTestItemKeyTagged) TableName() string { return "test" }
+
+type utilTestPrimaryIndex struct {
+	PrimaryIndex `dynamodbav:"-"`
+	String       string `dynamodbav:"string"`
+}
+
+type utilTestItemOptional struct {
+	Item
+	String    string   `dynamodbav:"string"`
+	Int       int      `dynamodbav:"int"`
+	Ptr       *string  `dynamodbav:"ptr"`
+	PtrSet    *string  `dynamodbav:"ptr_set"`
+	OmitEmpty string   `dynamodbav:"omit_empty,omitempty"`
+	Slice     []string `dynamodbav:"slice"`
+}
+
+func (i utilTestItemOptional) TableName() string { return "test" }
+
+type utilTestEmbedded struct {
+	Embedded string `dynamodbav:"embedded"`
+}
+
+type utilTestAddress struct {
+	City    string `dynamodbav:"city"`
+	ZipCode string `dynamodbav:"zip_code"`
+}
+
+type utilTestItemNested struct {
+	Item
+	String string `dynamodbav:"string"`
+	utilTestEmbedded
+	Address    utilTestAddress  `dynamodbav:"address"`
+	PtrAddress *utilTestAddress `dynamodbav:"ptr_address"`
+}
+
+func (i utilTestItemNested) TableName() string { return "test" }
+
+type utilTestItemNoAttributes struct {
+	Item
+	Untagged string
+}
+
+func (i utilTestItemNoAttributes) TableName() string { return "test" }
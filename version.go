@@ -0,0 +1,181 @@
+package dorm
+
+import (
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/expression"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/cockroachdb/errors"
+)
+
+// dormStructTag is the struct tag for the options of dorm, such as `dorm:"version"`.
+const dormStructTag = "dorm"
+
+const versionTagOption = "version"
+
+// versionPrefix is the placeholder prefix of the expressions added for optimistic locking.
+const versionPrefix = "v"
+
+// versionField is the field of an ItemType tagged with `dorm:"version"`.
+//
+// The version is incremented by every write, and writes given the expected version fail with ErrVersionConflict
+// if the stored version is different.
+type versionField struct {
+	// name is the attribute name.
+	name  string
+	index []int
+}
+
+// versionFieldOf returns the version field of V, or nil if it doesn't have one.
+func versionFieldOf[V ItemType]() (*versionField, error) {
+	s, err := schemaOf[V]()
+	if err != nil {
+		return nil, err
+	}
+	return s.version, nil
+}
+
+// get returns the version of item, which may be a pointer to a struct.
+func (f *versionField) get(item reflect.Value) int64 {
+	sv, ok := structValue(item)
+	if !ok {
+		return 0
+	}
+	v, ok := fieldValue(sv, f.index)
+	if !ok {
+		return 0
+	}
+	if v.CanInt() {
+		return v.Int()
+	}
+	return int64(v.Uint())
+}
+
+// condition is attribute_not_exists(version) OR version = expected.
+func (f *versionField) condition(expected int64) expression.ConditionBuilder {
+	name := expression.Name(f.name)
+	return expression.AttributeNotExists(name).Or(name.Equal(expression.Value(expected)))
+}
+
+// increment adds 1 to the version.
+func (f *versionField) increment() expression.UpdateBuilder {
+	return expression.Add(expression.Name(f.name), expression.Value(1))
+}
+
+// versionCheck is the expected version of a write.
+type versionCheck struct {
+	field    *versionField
+	expected int64
+}
+
+// conflict reports whether the item returned by a failed condition has a different version than expected.
+func (c *versionCheck) conflict(item map[string]types.AttributeValue) bool {
+	if c == nil {
+		return false
+	}
+	av, ok := item[c.field.name].(*types.AttributeValueMemberN)
+	if !ok {
+		return false
+	}
+	v, err := strconv.ParseInt(av.Value, 10, 64)
+	return err != nil || v != c.expected
+}
+
+// hasTagOption reports whether the comma-separated tag contains opt.
+func hasTagOption(tag, opt string) bool {
+	for _, o := range strings.Split(tag, ",") {
+		if o == opt {
+			return true
+		}
+	}
+	return false
+}
+
+// tagOptionValue returns the value of the first option of the comma-separated tag that starts with prefix, such as `name=value`.
+func tagOptionValue(tag, prefix string) (string, bool) {
+	for _, o := range strings.Split(tag, ",") {
+		if v, ok := strings.CutPrefix(o, prefix); ok {
+			return v, true
+		}
+	}
+	return "", false
+}
+
+// apply adds the version condition to parts if expected is not nil, and the increment if increment is true.
+// It returns the check to detect a version conflict when the condition fails.
+func (f *versionField) apply(parts exprParts, expected *int64, increment bool) (exprParts, *versionCheck, error) {
+	b := expression.NewBuilder()
+	var check *versionCheck
+	if expected != nil {
+		b = b.WithCondition(f.condition(*expected))
+		check = &versionCheck{field: f, expected: *expected}
+	}
+	if increment {
+		b = b.WithUpdate(f.increment())
+	}
+	if expected == nil && !increment {
+		return parts, nil, nil
+	}
+
+	parts, err := parts.merge(versionPrefix, b)
+	if err != nil {
+		return parts, nil, err
+	}
+	return parts, check, nil
+}
+
+// returnValuesOnConditionCheckFailure returns ALL_OLD when a version is checked and rv is not set,
+// so that a version conflict can be told from other failures of the condition.
+func returnValuesOnConditionCheckFailure(rv types.ReturnValuesOnConditionCheckFailure, check *versionCheck) types.ReturnValuesOnConditionCheckFailure {
+	if check != nil && rv == "" {
+		return types.ReturnValuesOnConditionCheckFailureAllOld
+	}
+	return rv
+}
+
+// versioned adds the version condition and increment of V to parts. It does nothing if V has no version field.
+func versioned[V ItemType](parts exprParts, expected *int64, increment bool) (exprParts, *versionCheck, error) {
+	f, err := versionFieldOf[V]()
+	if err != nil || f == nil {
+		return parts, nil, err
+	}
+	return f.apply(parts, expected, increment)
+}
+
+// unversioned returns an error if V has a version field. BatchWriteItem can't check the stored version,
+// so op would overwrite it without a check and without an increment.
+func unversioned[V ItemType](op string) error {
+	f, err := versionFieldOf[V]()
+	if err != nil || f == nil {
+		return err
+	}
+	return errors.Newf("%s can't check the version of %s, use PutItem, DeleteItem or transactions instead",
+		op, reflect.TypeOf((*V)(nil)).Elem())
+}
+
+// requireExpectedVersion returns an error if V has a version field and expected is nil,
+// so that op never changes an item without checking its version. option is the option that sets expected.
+func requireExpectedVersion[V ItemType](op, option string, expected *int64) error {
+	f, err := versionFieldOf[V]()
+	if err != nil || f == nil || expected != nil {
+		return err
+	}
+	return errors.Newf("%s of %s requires the expected version, set it with %s",
+		op, reflect.TypeOf((*V)(nil)).Elem(), option)
+}
+
+// versionedPut adds the condition that the stored version equals the version of item to parts,
+// and sets the incremented version to av. It does nothing if V has no version field.
+func versionedPut[V ItemType](item V, av map[string]types.AttributeValue, parts exprParts) (exprParts, *versionCheck, error) {
+	f, err := versionFieldOf[V]()
+	if err != nil || f == nil {
+		return parts, nil, err
+	}
+
+	expected := f.get(reflect.ValueOf(item))
+	av[f.name] = &types.AttributeValueMemberN{Value: strconv.FormatInt(expected+1, 10)}
+
+	return f.apply(parts, &expected, false)
+}
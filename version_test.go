@@ -0,0 +1,233 @@
+package dorm
+
+import (
+	"context"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/expression"
+	"github.com/cockroachdb/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+func testtestVersionedItemOptimisticLocking(t *testing.T) {
+	t.Parallel()
+
+	updateStr := func(t *testing.T) expression.Expression {
+		expr, err := expression.NewBuilder().
+			WithUpdate(expression.Set(expression.Name("str"), expression.Value("updated"))).
+			Build()
+		assert.NoError(t, err)
+		return expr
+	}
+
+	tests := map[string]struct {
+		// stored is whether the item is put before write.
+		stored bool
+		write  func(t *testing.T, ctx context.Context, db DynamoDBAPI, o testVersionedItem) error
+
+		wantErr []error
+		// wantVersion is the stored version after write. If it is 0, the item must not exist.
+		wantVersion int64
+	}{
+		"put new item": {
+			write: func(t *testing.T, ctx context.Context, db DynamoDBAPI, o testVersionedItem) error {
+				_, err := PutItem(ctx, db, o, expression.Expression{})
+				return err
+			},
+			wantVersion: 1,
+		},
+		"put with current version": {
+			stored: true,
+			write: func(t *testing.T, ctx context.Context, db DynamoDBAPI, o testVersionedItem) error {
+				o.Version = 1
+				_, err := PutItem(ctx, db, o, expression.Expression{})
+				return err
+			},
+			wantVersion: 2,
+		},
+		"put with stale version": {
+			stored: true,
+			write: func(t *testing.T, ctx context.Context, db DynamoDBAPI, o testVersionedItem) error {
+				_, err := PutItem(ctx, db, o, expression.Expression{})
+				return err
+			},
+			wantErr:     []error{ErrVersionConflict, ErrConditionFailed},
+			wantVersion: 1,
+		},
+		"update without expected version is rejected": {
+			stored: true,
+			write: func(t *testing.T, ctx context.Context, db DynamoDBAPI, o testVersionedItem) error {
+				_, err := UpdateItem[testVersionedItem](ctx, db, testVersionedItemPrimaryIndex{HashKey: o.HashKey}, updateStr(t))
+				assert.Error(t, err)
+				err = TransactWriteItems(ctx, db, NewTransactWriteBuilder().Add(
+					TransactUpdate[testVersionedItem](testVersionedItemPrimaryIndex{HashKey: o.HashKey}, updateStr(t)),
+				))
+				assert.Error(t, err)
+				return nil
+			},
+			wantVersion: 1,
+		},
+		"update new item with expected version": {
+			write: func(t *testing.T, ctx context.Context, db DynamoDBAPI, o testVersionedItem) error {
+				got, err := UpdateItem[testVersionedItem](ctx, db, testVersionedItemPrimaryIndex{HashKey: o.HashKey}, updateStr(t), WithUpdateExpectedVersion(0))
+				assert.NoError(t, err)
+				assert.Equal(t, int64(1), got.Version)
+				assert.Equal(t, "updated", got.Str)
+				return err
+			},
+			wantVersion: 1,
+		},
+		"update with current version": {
+			stored: true,
+			write: func(t *testing.T, ctx context.Context, db DynamoDBAPI, o testVersionedItem) error {
+				upd, err := AttributeSetAll(o)
+				assert.NoError(t, err)
+				expr, err := expression.NewBuilder().WithUpdate(upd).Build()
+				assert.NoError(t, err)
+				_, err = UpdateItem[testVersionedItem](ctx, db, testVersionedItemPrimaryIndex{HashKey: o.HashKey}, expr, WithUpdateExpectedVersion(1))
+				return err
+			},
+			wantVersion: 2,
+		},
+		"update with stale version": {
+			stored: true,
+			write: func(t *testing.T, ctx context.Context, db DynamoDBAPI, o testVersionedItem) error {
+				_, err := UpdateItem[testVersionedItem](ctx, db, testVersionedItemPrimaryIndex{HashKey: o.HashKey}, updateStr(t), WithUpdateExpectedVersion(0))
+				return err
+			},
+			wantErr:     []error{ErrVersionConflict, ErrConditionFailed},
+			wantVersion: 1,
+		},
+		"update with failed condition": {
+			stored: true,
+			write: func(t *testing.T, ctx context.Context, db DynamoDBAPI, o testVersionedItem) error {
+				expr, err := expression.NewBuilder().
+					WithUpdate(expression.Set(expression.Name("str"), expression.Value("updated"))).
+					WithCondition(expression.Name("str").NotEqual(expression.Value(o.Str))).
+					Build()
+				assert.NoError(t, err)
+				_, err = UpdateItem[testVersionedItem](ctx, db, testVersionedItemPrimaryIndex{HashKey: o.HashKey}, expr, WithUpdateExpectedVersion(1))
+				assert.False(t, errors.Is(err, ErrVersionConflict))
+				return err
+			},
+			wantErr:     []error{ErrConditionFailed},
+			wantVersion: 1,
+		},
+		"delete without expected version is rejected": {
+			stored: true,
+			write: func(t *testing.T, ctx context.Context, db DynamoDBAPI, o testVersionedItem) error {
+				_, err := DeleteItem[testVersionedItem](ctx, db, testVersionedItemPrimaryIndex{HashKey: o.HashKey}, expression.Expression{})
+				assert.Error(t, err)
+				err = TransactWriteItems(ctx, db, NewTransactWriteBuilder().Add(
+					TransactDelete[testVersionedItem](testVersionedItemPrimaryIndex{HashKey: o.HashKey}, expression.Expression{}),
+				))
+				assert.Error(t, err)
+				return nil
+			},
+			wantVersion: 1,
+		},
+		"delete with current version": {
+			stored: true,
+			write: func(t *testing.T, ctx context.Context, db DynamoDBAPI, o testVersionedItem) error {
+				_, err := DeleteItem[testVersionedItem](ctx, db, testVersionedItemPrimaryIndex{HashKey: o.HashKey}, expression.Expression{}, WithDeleteExpectedVersion(1))
+				return err
+			},
+		},
+		"delete with stale version": {
+			stored: true,
+			write: func(t *testing.T, ctx context.Context, db DynamoDBAPI, o testVersionedItem) error {
+				_, err := DeleteItem[testVersionedItem](ctx, db, testVersionedItemPrimaryIndex{HashKey: o.HashKey}, expression.Expression{}, WithDeleteExpectedVersion(2))
+				return err
+			},
+			wantErr:     []error{ErrVersionConflict, ErrConditionFailed},
+			wantVersion: 1,
+		},
+		"transaction with current version": {
+			stored: true,
+			write: func(t *testing.T, ctx context.Context, db DynamoDBAPI, o testVersionedItem) error {
+				return TransactWriteItems(ctx, db, NewTransactWriteBuilder().Add(
+					TransactUpdate[testVersionedItem](testVersionedItemPrimaryIndex{HashKey: o.HashKey}, updateStr(t), WithTransactExpectedVersion(1)),
+				))
+			},
+			wantVersion: 2,
+		},
+		"transaction with stale version": {
+			stored: true,
+			write: func(t *testing.T, ctx context.Context, db DynamoDBAPI, o testVersionedItem) error {
+				err := TransactWriteItems(ctx, db, NewTransactWriteBuilder().Add(
+					TransactUpdate[testVersionedItem](testVersionedItemPrimaryIndex{HashKey: o.HashKey}, updateStr(t), WithTransactExpectedVersion(0)),
+				))
+
+				var cerr *TransactionCanceledError
+				assert.True(t, errors.As(err, &cerr))
+				assert.True(t, cerr.Reasons[0].VersionConflict)
+				assert.Equal(t, int64(1), cerr.Reasons[0].Item.(*testVersionedItem).Version)
+				return err
+			},
+			wantErr:     []error{ErrVersionConflict, ErrConditionFailed, ErrTransactionCanceled},
+			wantVersion: 1,
+		},
+		"transaction put with stale version": {
+			stored: true,
+			write: func(t *testing.T, ctx context.Context, db DynamoDBAPI, o testVersionedItem) error {
+				return TransactWriteItems(ctx, db, NewTransactWriteBuilder().Add(TransactPut(o, expression.Expression{})))
+			},
+			wantErr:     []error{ErrVersionConflict},
+			wantVersion: 1,
+		},
+		"batch put is rejected": {
+			stored: true,
+			write: func(t *testing.T, ctx context.Context, db DynamoDBAPI, o testVersionedItem) error {
+				o.Str = "stale"
+				assert.Error(t, BatchPutItem(ctx, db, []testVersionedItem{o}))
+				return nil
+			},
+			wantVersion: 1,
+		},
+		"batch delete is rejected": {
+			stored: true,
+			write: func(t *testing.T, ctx context.Context, db DynamoDBAPI, o testVersionedItem) error {
+				assert.Error(t, BatchDeleteItem[testVersionedItem](ctx, db, []PrimaryIndex{testVersionedItemPrimaryIndex{HashKey: o.HashKey}}))
+				return nil
+			},
+			wantVersion: 1,
+		},
+	}
+	for name, tt := range tests {
+		tt := tt
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			ctx := context.Background()
+
+			db, err := ddbMain.conn()
+			assert.NoError(t, err)
+
+			// randomize
+			o := testVersionedItem{}
+			err = RandomizeDDBStruct(&o)
+			assert.NoError(t, err)
+			o.Version = 0
+
+			if tt.stored {
+				_, err = PutItem(ctx, db, o, expression.Expression{})
+				assert.NoError(t, err)
+			}
+
+			err = tt.write(t, ctx, db, o)
+			if len(tt.wantErr) == 0 {
+				assert.NoError(t, err)
+			}
+			for _, want := range tt.wantErr {
+				assert.ErrorIs(t, err, want)
+			}
+
+			got, err := GetItem[testVersionedItem](ctx, db, testVersionedItemPrimaryIndex{HashKey: o.HashKey}, expression.Expression{})
+			if tt.wantVersion == 0 {
+				assert.True(t, errors.Is(err, ErrItemNotFound))
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.wantVersion, got.Version)
+		})
+	}
+}